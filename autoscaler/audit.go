@@ -0,0 +1,242 @@
+package main
+
+import (
+  "os"
+  "sync"
+  "time"
+  "strconv"
+  "encoding/json"
+  "github.com/prometheus/client_golang/prometheus"
+  "github.com/alexnjh/epsilon/autoscaler/interfaces"
+)
+
+// VoteRecord is the recommendation of a plugin as written to the decision log
+type VoteRecord struct{
+  Plugin string `json:"plugin"`
+  Decision interfaces.ComputeResult `json:"decision,omitempty"`
+  DesiredReplicas int32 `json:"desiredReplicas"`
+  Confidence float64 `json:"confidence"`
+  Weight float64 `json:"weight"`
+  Error string `json:"error,omitempty"`
+}
+
+// DecisionRecord contains everything the autoscaler decided for a queue during an interval
+type DecisionRecord struct{
+  Timestamp time.Time `json:"timestamp"`
+  Queue string `json:"queue"`
+  // Kind and name of the scheduler workload (eg. Deployment/scheduler)
+  Target string `json:"target,omitempty"`
+  // Replicas before the decision is applied
+  Replicas int32 `json:"replicas"`
+  // Weighted average of the replicas desired by the winning plugins
+  DesiredReplicas int32 `json:"desiredReplicas"`
+  // Replicas after applying the scaling policy
+  TargetReplicas int32 `json:"targetReplicas"`
+  Decision interfaces.ComputeResult `json:"decision"`
+  DryRun bool `json:"dryRun"`
+  // True if the workload is updated to the target replicas
+  Applied bool `json:"applied"`
+  Error string `json:"error,omitempty"`
+  // Why the decision is made without the plugins (eg. the queue is idle)
+  Reason string `json:"reason,omitempty"`
+  Votes []VoteRecord `json:"votes"`
+}
+
+// Creates a record of the votes of the plugins
+func newVoteRecords(votes []Vote, current int32) []VoteRecord{
+
+  records := make([]VoteRecord, len(votes))
+
+  for i, v := range(votes){
+    records[i] = VoteRecord{
+      Plugin: v.Plugin,
+      DesiredReplicas: v.Recommendation.DesiredReplicas,
+      Confidence: v.Recommendation.Confidence,
+      Weight: v.Weight,
+    }
+    if v.Err != nil {
+      records[i].Error = v.Err.Error()
+    }else{
+      records[i].Decision = v.Recommendation.Result(current)
+    }
+  }
+
+  return records
+}
+
+// DecisionLogger appends decision records to a file in the JSON lines format
+type DecisionLogger struct{
+  mu sync.Mutex
+  file *os.File
+}
+
+// Creates a new DecisionLogger writing to the file at path, the file is created if it does not exist
+func NewDecisionLogger(path string) (*DecisionLogger, error){
+
+  file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+  if err != nil {
+    return nil, err
+  }
+
+  return &DecisionLogger{file: file}, nil
+}
+
+// Write a decision record as a single line
+func (l *DecisionLogger) Log(record DecisionRecord) error{
+
+  b, err := json.Marshal(record)
+  if err != nil {
+    return err
+  }
+
+  l.mu.Lock()
+  defer l.mu.Unlock()
+
+  _, err = l.file.Write(append(b, '\n'))
+  return err
+}
+
+// Close the decision log file
+func (l *DecisionLogger) Close() error{
+  return l.file.Close()
+}
+
+var (
+  decisionCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+    Name: "autoscaler_decisions_total",
+    Help: "Number of decisions made by the autoscaler",
+  }, []string{"queue", "decision", "dry_run"})
+
+  scaleFailureCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+    Name: "autoscaler_scale_failures_total",
+    Help: "Number of scaling operations that failed",
+  }, []string{"queue"})
+
+  targetReplicasGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: "autoscaler_target_replicas",
+    Help: "Number of scheduler replicas decided by the autoscaler after applying the scaling policy",
+  }, []string{"queue"})
+
+  pluginDesiredReplicasGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: "autoscaler_plugin_desired_replicas",
+    Help: "Number of scheduler replicas desired by each plugin",
+  }, []string{"queue", "plugin"})
+
+  pluginConfidenceGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: "autoscaler_plugin_confidence",
+    Help: "Confidence of each plugin about its recommendation",
+  }, []string{"queue", "plugin"})
+
+  pluginErrorCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+    Name: "autoscaler_plugin_errors_total",
+    Help: "Number of times a plugin failed to make a recommendation",
+  }, []string{"queue", "plugin"})
+
+  pluginVoteGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: "autoscaler_plugin_vote",
+    Help: "Last vote of each plugin (1 scale up, 0 do not scale, -1 scale down)",
+  }, []string{"queue", "plugin"})
+
+  decisionGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: "autoscaler_decision",
+    Help: "Last consolidated decision of the autoscaler (1 scale up, 0 do not scale, -1 scale down)",
+  }, []string{"queue"})
+
+  replicasGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: "autoscaler_replicas",
+    Help: "Number of scheduler replicas before the decision is applied",
+  }, []string{"queue"})
+
+  pendingPodsGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: "autoscaler_queue_pending_pods",
+    Help: "Number of pods waiting in the queue",
+  }, []string{"queue"})
+
+  consumersGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: "autoscaler_queue_consumers",
+    Help: "Number of schedulers consuming from the queue",
+  }, []string{"queue"})
+
+  arrivalRateGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: "autoscaler_queue_arrival_rate",
+    Help: "Number of pods arriving per minute",
+  }, []string{"queue"})
+
+  serviceRateGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: "autoscaler_queue_service_rate",
+    Help: "Number of pods a scheduler replica is able to schedule per minute",
+  }, []string{"queue"})
+
+  unschedulablePodsGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: "autoscaler_queue_unschedulable_pods",
+    Help: "Number of pods of the queue waiting to be retried because no node is able to run them",
+  }, []string{"queue"})
+
+  joiningNodesGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+    Name: "autoscaler_joining_nodes",
+    Help: "Number of nodes that recently joined the cluster and are not ready yet",
+  })
+)
+
+// Register the autoscaler metrics in the Prometheus collector
+func registerMetrics(){
+  prometheus.MustRegister(decisionCounter)
+  prometheus.MustRegister(scaleFailureCounter)
+  prometheus.MustRegister(targetReplicasGauge)
+  prometheus.MustRegister(pluginDesiredReplicasGauge)
+  prometheus.MustRegister(pluginConfidenceGauge)
+  prometheus.MustRegister(pluginErrorCounter)
+  prometheus.MustRegister(pluginVoteGauge)
+  prometheus.MustRegister(decisionGauge)
+  prometheus.MustRegister(replicasGauge)
+  prometheus.MustRegister(pendingPodsGauge)
+  prometheus.MustRegister(consumersGauge)
+  prometheus.MustRegister(arrivalRateGauge)
+  prometheus.MustRegister(serviceRateGauge)
+  prometheus.MustRegister(unschedulablePodsGauge)
+  prometheus.MustRegister(joiningNodesGauge)
+}
+
+// Update the queue metrics with the snapshot given to the plugins
+func recordSnapshotMetrics(snapshot *interfaces.MetricsSnapshot){
+  pendingPodsGauge.WithLabelValues(snapshot.QueueName).Set(snapshot.QueueDepth)
+  consumersGauge.WithLabelValues(snapshot.QueueName).Set(snapshot.Consumers)
+  arrivalRateGauge.WithLabelValues(snapshot.QueueName).Set(snapshot.ArrivalRate)
+  serviceRateGauge.WithLabelValues(snapshot.QueueName).Set(snapshot.ServiceRate)
+  unschedulablePodsGauge.WithLabelValues(snapshot.QueueName).Set(snapshot.UnschedulablePods)
+  joiningNodesGauge.Set(float64(snapshot.NodeChurn.JoiningNodes))
+}
+
+// Convert a decision into a gauge value
+func decisionValue(decision interfaces.ComputeResult) float64{
+  switch decision {
+  case interfaces.ScaleUp:
+    return 1
+  case interfaces.ScaleDown:
+    return -1
+  }
+  return 0
+}
+
+// Update the autoscaler metrics with a decision record
+func recordMetrics(record DecisionRecord){
+
+  decisionCounter.WithLabelValues(record.Queue, string(record.Decision), strconv.FormatBool(record.DryRun)).Inc()
+  targetReplicasGauge.WithLabelValues(record.Queue).Set(float64(record.TargetReplicas))
+  replicasGauge.WithLabelValues(record.Queue).Set(float64(record.Replicas))
+  decisionGauge.WithLabelValues(record.Queue).Set(decisionValue(record.Decision))
+
+  if len(record.Error) != 0 {
+    scaleFailureCounter.WithLabelValues(record.Queue).Inc()
+  }
+
+  for _, v := range(record.Votes){
+    if len(v.Error) != 0 {
+      pluginErrorCounter.WithLabelValues(record.Queue, v.Plugin).Inc()
+      continue
+    }
+    pluginDesiredReplicasGauge.WithLabelValues(record.Queue, v.Plugin).Set(float64(v.DesiredReplicas))
+    pluginConfidenceGauge.WithLabelValues(record.Queue, v.Plugin).Set(v.Confidence)
+    pluginVoteGauge.WithLabelValues(record.Queue, v.Plugin).Set(decisionValue(v.Decision))
+  }
+}
@@ -0,0 +1,438 @@
+package main
+
+import (
+  "os"
+  "fmt"
+  "time"
+  "strings"
+  "strconv"
+  configparser "github.com/bigkevmcd/go-configparser"
+)
+
+const (
+  // Config file section containing the autoscaler settings
+  AutoscalerSection = "Autoscaler"
+
+  // Default scaling policy values used when the user does not specify them
+  DefaultMinReplicas = 1
+  DefaultMaxReplicas = 10
+  DefaultStepSize = 1
+  DefaultPluginWeight = 1.0
+
+  // Default season and horizon of the forecast plugin
+  DefaultForecastSeason = 24*time.Hour
+  DefaultForecastHorizon = 10*time.Minute
+
+  // Default number of pods a scheduler replica is able to schedule per minute (25ms per pod),
+  // used when the service rate cannot be measured from recently scheduled pods
+  DefaultServiceRate = 2400.0
+)
+
+// Plugins enabled by default and their default thresholds
+var DefaultPlugins = []PluginConfig{
+  {Name: "rabbitmq", Threshold: 0.5, Weight: DefaultPluginWeight},
+  {Name: "schedprob", Threshold: 0.5, Weight: DefaultPluginWeight},
+  // The threshold of the forecast plugin is the target utilisation of the scheduler replicas
+  {Name: "forecast", Threshold: 0.8, Weight: DefaultPluginWeight},
+  // The threshold of the queue theory plugin is the waiting time SLO in seconds
+  {Name: "queuetheory", Threshold: 1, Weight: DefaultPluginWeight},
+  // The threshold of the node churn plugin is the time in seconds to schedule the pods released by new nodes
+  {Name: "nodechurn", Threshold: 60, Weight: DefaultPluginWeight},
+}
+
+// PluginConfig contains the user defined settings of a single autoscaler plugin
+type PluginConfig struct{
+  // Name of the plugin
+  Name string
+  // Threshold passed to the plugin when it is created
+  Threshold float64
+  // Weight of the plugin's vote when the decisions are consolidated
+  Weight float64
+}
+
+// ScalingPolicy contains the limits the autoscaler must respect when changing the number of replicas
+type ScalingPolicy struct{
+  // Minimum number of scheduler replicas
+  MinReplicas int32
+  // Maximum number of scheduler replicas
+  MaxReplicas int32
+  // Number of replicas added or removed per scaling operation
+  StepSize int32
+  // Minimum time between two scale up operations
+  ScaleUpCooldown time.Duration
+  // Minimum time between two scale down operations
+  ScaleDownCooldown time.Duration
+  // Scale down only to the highest replica count recommended within this window
+  StabilizationWindow time.Duration
+}
+
+// ForecastConfig contains the settings of the forecast plugin
+type ForecastConfig struct{
+  // Length of a season of the pod arrivals (eg. 24 hours for a daily cron job), 0 disables seasonality
+  Season time.Duration
+  // How far ahead to forecast the pod arrivals
+  Horizon time.Duration
+}
+
+// AuditConfig contains the settings used to evaluate the decisions of the autoscaler
+type AuditConfig struct{
+  // Only record the decisions without changing the replicas
+  DryRun bool
+  // Path of the file the decisions are appended to in the JSON lines format, empty to disable
+  DecisionLog string
+}
+
+// Returns the value of a setting and whether it was set by the user
+type lookupFunc func(key string) (string, bool)
+
+// Look up settings from the autoscaler section of the config file
+func configLookup(config *configparser.ConfigParser) lookupFunc{
+  return func(key string) (string, bool){
+    val, err := config.Get(AutoscalerSection, key)
+    if err != nil || len(val) == 0 {
+      return "", false
+    }
+    return val, true
+  }
+}
+
+// Look up settings from the environment variables (eg. min_replicas -> MIN_REPLICAS)
+func envLookup() lookupFunc{
+  return func(key string) (string, bool){
+    val := os.Getenv(strings.ToUpper(key))
+    if len(val) == 0 {
+      return "", false
+    }
+    return val, true
+  }
+}
+
+// Get the scaling policy, settings that are not set will use the default values
+func getScalingPolicy(lookup lookupFunc) (ScalingPolicy, error){
+
+  policy := ScalingPolicy{
+    MinReplicas: DefaultMinReplicas,
+    MaxReplicas: DefaultMaxReplicas,
+    StepSize: DefaultStepSize,
+  }
+
+  var err error
+
+  if policy.MinReplicas, err = getInt32(lookup, "min_replicas", policy.MinReplicas); err != nil {
+    return policy, err
+  }
+  if policy.MaxReplicas, err = getInt32(lookup, "max_replicas", policy.MaxReplicas); err != nil {
+    return policy, err
+  }
+  if policy.StepSize, err = getInt32(lookup, "step_size", policy.StepSize); err != nil {
+    return policy, err
+  }
+  if policy.ScaleUpCooldown, err = getSeconds(lookup, "scale_up_cooldown"); err != nil {
+    return policy, err
+  }
+  if policy.ScaleDownCooldown, err = getSeconds(lookup, "scale_down_cooldown"); err != nil {
+    return policy, err
+  }
+  if policy.StabilizationWindow, err = getSeconds(lookup, "stabilization_window"); err != nil {
+    return policy, err
+  }
+
+  if policy.MinReplicas < 1 {
+    return policy, fmt.Errorf("min_replicas must be at least 1, got %d", policy.MinReplicas)
+  }
+  if policy.MaxReplicas < policy.MinReplicas {
+    return policy, fmt.Errorf("max_replicas (%d) is smaller than min_replicas (%d)", policy.MaxReplicas, policy.MinReplicas)
+  }
+  if policy.StepSize < 1 {
+    return policy, fmt.Errorf("step_size must be at least 1, got %d", policy.StepSize)
+  }
+
+  return policy, nil
+}
+
+// Get the number of pods a scheduler replica is able to schedule per minute
+func getServiceRate(lookup lookupFunc) (float64, error){
+  rate, err := getFloat(lookup, "service_rate", DefaultServiceRate)
+  if err != nil {
+    return rate, err
+  }
+  if rate <= 0 {
+    return rate, fmt.Errorf("service_rate must be positive, got %f", rate)
+  }
+  return rate, nil
+}
+
+// Get the settings of the forecast plugin
+func getForecastConfig(lookup lookupFunc) (ForecastConfig, error){
+
+  cfg := ForecastConfig{
+    Season: DefaultForecastSeason,
+    Horizon: DefaultForecastHorizon,
+  }
+
+  var err error
+
+  if _, ok := lookup("forecast_season"); ok {
+    if cfg.Season, err = getSeconds(lookup, "forecast_season"); err != nil {
+      return cfg, err
+    }
+  }
+  if _, ok := lookup("forecast_horizon"); ok {
+    if cfg.Horizon, err = getSeconds(lookup, "forecast_horizon"); err != nil {
+      return cfg, err
+    }
+  }
+
+  return cfg, nil
+}
+
+// Get the window the changes to the nodes are counted in
+func getNodeChurnWindow(lookup lookupFunc) (time.Duration, error){
+
+  if _, ok := lookup("node_churn_window"); !ok {
+    return DefaultNodeChurnWindow, nil
+  }
+
+  window, err := getSeconds(lookup, "node_churn_window")
+  if err != nil {
+    return window, err
+  }
+  if window <= 0 {
+    return window, fmt.Errorf("node_churn_window must be positive")
+  }
+
+  return window, nil
+}
+
+// Get the settings used to evaluate the decisions of the autoscaler
+func getAuditConfig(lookup lookupFunc) (AuditConfig, error){
+
+  var cfg AuditConfig
+
+  if val, ok := lookup("dry_run"); ok {
+    b, err := strconv.ParseBool(strings.TrimSpace(val))
+    if err != nil {
+      return cfg, fmt.Errorf("Invalid value for dry_run: %v", err)
+    }
+    cfg.DryRun = b
+  }
+
+  if val, ok := lookup("decision_log"); ok {
+    cfg.DecisionLog = strings.TrimSpace(val)
+  }
+
+  return cfg, nil
+}
+
+// Get the queues to autoscale as a comma separated list, defaults to the default queue
+func getQueues(lookup lookupFunc, defaultQueue string) []string{
+
+  val, ok := lookup("queues")
+  if !ok {
+    return []string{defaultQueue}
+  }
+
+  queues := make([]string, 0)
+  for _, name := range(strings.Split(val, ",")){
+    if name = strings.TrimSpace(name); len(name) != 0 {
+      queues = append(queues, name)
+    }
+  }
+
+  return queues
+}
+
+// Get the scheduler workload of every queue. The target_resource, target_selector, scale_to_zero and
+// idle_window settings apply to all queues and can be overridden for a single queue by prefixing them
+// with the queue name (eg. epsilon_distributed_target_resource for the epsilon.distributed queue)
+func getScaleTargets(lookup lookupFunc, queues []string) ([]ScaleTarget, error){
+
+  resource := DefaultTargetResource
+  if val, ok := lookup("target_resource"); ok {
+    resource = val
+  }
+
+  selector := DefaultTargetSelector
+  if val, ok := lookup("target_selector"); ok {
+    selector = val
+  }
+
+  targets := make([]ScaleTarget, 0, len(queues))
+
+  for _, queue := range(queues){
+
+    r, sel := resource, selector
+
+    if val, ok := lookup(queueKey(queue)+"_target_resource"); ok {
+      r = val
+    }
+    if val, ok := lookup(queueKey(queue)+"_target_selector"); ok {
+      sel = val
+    }
+
+    target, err := newScaleTarget(queue, r, sel)
+    if err != nil {
+      return nil, err
+    }
+
+    if target.ScaleToZero, err = getQueueBool(lookup, queue, "scale_to_zero"); err != nil {
+      return nil, err
+    }
+
+    for _, key := range([]string{"idle_window", queueKey(queue)+"_idle_window"}){
+      if _, ok := lookup(key); ok {
+        if target.IdleWindow, err = getSeconds(lookup, key); err != nil {
+          return nil, err
+        }
+      }
+    }
+
+    if target.IdleWindow <= 0 {
+      return nil, fmt.Errorf("Idle window of queue %s must be positive", queue)
+    }
+
+    targets = append(targets, target)
+  }
+
+  return targets, nil
+}
+
+// Get a boolean setting of a queue, the setting of the queue takes precedence over the setting of all queues
+func getQueueBool(lookup lookupFunc, queue string, key string) (bool, error){
+
+  result := false
+
+  for _, k := range([]string{key, queueKey(queue)+"_"+key}){
+    if val, ok := lookup(k); ok {
+      b, err := strconv.ParseBool(strings.TrimSpace(val))
+      if err != nil {
+        return false, fmt.Errorf("Invalid value for %s: %v", k, err)
+      }
+      result = b
+    }
+  }
+
+  return result, nil
+}
+
+// Get how often queues scaled to zero are checked for new messages
+func getWakeInterval(lookup lookupFunc) (time.Duration, error){
+
+  if _, ok := lookup("wake_interval"); !ok {
+    return DefaultWakeInterval, nil
+  }
+
+  interval, err := getSeconds(lookup, "wake_interval")
+  if err != nil {
+    return interval, err
+  }
+  if interval <= 0 {
+    return interval, fmt.Errorf("wake_interval must be positive")
+  }
+
+  return interval, nil
+}
+
+// Prefix of the settings of a single queue, characters that cannot be used in a environment variable
+// name are replaced with an underscore
+func queueKey(queue string) string{
+  return strings.Map(func(r rune) rune{
+    if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+      return r
+    }
+    return '_'
+  }, queue)
+}
+
+// Convert a duration into a number of update intervals, rounded up
+func toIntervals(d time.Duration, interval time.Duration) int{
+  if interval <= 0 {
+    return 0
+  }
+  return int((d+interval-1)/interval)
+}
+
+// Get the list of enabled plugins in the order specified by the user.
+// The thresholds and weights of each plugin are read from <plugin>_threshold and <plugin>_weight
+func getPluginConfigs(lookup lookupFunc) ([]PluginConfig, error){
+
+  defaults := make(map[string]PluginConfig)
+  names := make([]string, 0, len(DefaultPlugins))
+
+  for _, p := range(DefaultPlugins){
+    defaults[p.Name] = p
+    names = append(names, p.Name)
+  }
+
+  if val, ok := lookup("plugins"); ok {
+    names = names[:0]
+    for _, name := range(strings.Split(val, ",")){
+      if name = strings.TrimSpace(name); len(name) != 0 {
+        names = append(names, name)
+      }
+    }
+  }
+
+  result := make([]PluginConfig, 0, len(names))
+
+  for _, name := range(names){
+
+    p, ok := defaults[name]
+    if !ok {
+      return nil, fmt.Errorf("Unknown autoscaler plugin %s", name)
+    }
+
+    var err error
+
+    if p.Threshold, err = getFloat(lookup, name+"_threshold", p.Threshold); err != nil {
+      return nil, err
+    }
+    if p.Weight, err = getFloat(lookup, name+"_weight", p.Weight); err != nil {
+      return nil, err
+    }
+    if p.Weight < 0 {
+      return nil, fmt.Errorf("%s_weight must not be negative, got %f", name, p.Weight)
+    }
+
+    result = append(result, p)
+  }
+
+  return result, nil
+}
+
+func getInt32(lookup lookupFunc, key string, def int32) (int32, error){
+  val, ok := lookup(key)
+  if !ok {
+    return def, nil
+  }
+  i, err := strconv.ParseInt(strings.TrimSpace(val), 10, 32)
+  if err != nil {
+    return def, fmt.Errorf("Invalid value for %s: %v", key, err)
+  }
+  return int32(i), nil
+}
+
+func getFloat(lookup lookupFunc, key string, def float64) (float64, error){
+  val, ok := lookup(key)
+  if !ok {
+    return def, nil
+  }
+  f, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+  if err != nil {
+    return def, fmt.Errorf("Invalid value for %s: %v", key, err)
+  }
+  return f, nil
+}
+
+// Durations are specified in seconds to be consistent with the update interval
+func getSeconds(lookup lookupFunc, key string) (time.Duration, error){
+  val, ok := lookup(key)
+  if !ok {
+    return 0, nil
+  }
+  i, err := strconv.Atoi(strings.TrimSpace(val))
+  if err != nil || i < 0 {
+    return 0, fmt.Errorf("Invalid value for %s: %s", key, val)
+  }
+  return time.Duration(i)*time.Second, nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+  "math"
+  "time"
+  "github.com/alexnjh/epsilon/autoscaler/interfaces"
+)
+
+// Vote is the recommendation returned by a single plugin together with the weight of the plugin
+type Vote struct{
+  Plugin string
+  Recommendation interfaces.Recommendation
+  Weight float64
+  // Error returned by the plugin, a plugin that fails abstains from voting
+  Err error
+}
+
+// Replica count recommended at a point in time
+type recommendation struct{
+  timestamp time.Time
+  replicas int32
+}
+
+// ScaleState keeps track of the previous scaling operations of a queue so that the
+// cooldown and stabilization windows of the scaling policy can be enforced
+type ScaleState struct{
+  lastScaleUp time.Time
+  lastScaleDown time.Time
+  recommendations []recommendation
+}
+
+// Consolidate all the recommendations made by the plugins and decide on the next course of action.
+// Every plugin votes to scale up, down or not at all relative to the current replicas with a weight
+// of its configured weight multiplied by its confidence. The larger side wins and a tie results in
+// DoNotScale. The desired replicas is the weighted average of the replicas desired by the winning side.
+func makeDecision(votes []Vote, current int32) (interfaces.ComputeResult, int32){
+
+  var weights = make(map[interfaces.ComputeResult]float64)
+  var sums = make(map[interfaces.ComputeResult]float64)
+
+  for _, v := range(votes){
+    if v.Err != nil {
+      continue
+    }
+    w := v.Weight*v.Recommendation.Confidence
+    r := v.Recommendation.Result(current)
+    weights[r] += w
+    sums[r] += w*float64(v.Recommendation.DesiredReplicas)
+  }
+
+  up, down := weights[interfaces.ScaleUp], weights[interfaces.ScaleDown]
+
+  if up > down {
+    return interfaces.ScaleUp, int32(math.Ceil(sums[interfaces.ScaleUp]/up))
+  }else if down > up {
+    return interfaces.ScaleDown, int32(math.Floor(sums[interfaces.ScaleDown]/down))
+  }
+
+  return interfaces.DoNotScale, current
+}
+
+// Clamp the replica count to the bounds of the scaling policy
+func (p ScalingPolicy) bound(replicas int32) int32{
+  if replicas < p.MinReplicas {
+    return p.MinReplicas
+  }
+  if replicas > p.MaxReplicas {
+    return p.MaxReplicas
+  }
+  return replicas
+}
+
+// Compute the number of replicas a queue should have based on the current number of replicas
+// and the consolidated desired replicas. The replicas change by at most the step size and a
+// replica count outside of the policy bounds is always corrected, other changes are subjected
+// to the cooldown and stabilization windows.
+func (p ScalingPolicy) nextReplicas(state *ScaleState, current, desired int32, now time.Time) int32{
+
+  target := desired
+
+  if target > current+p.StepSize {
+    target = current+p.StepSize
+  }else if target < current-p.StepSize {
+    target = current-p.StepSize
+  }
+
+  target = p.bound(target)
+
+  // Remember the recommendation and forget those that are outside of the stabilization window
+  state.recommendations = append(state.recommendations, recommendation{now, target})
+
+  i := 0
+  for _, r := range(state.recommendations){
+    if now.Sub(r.timestamp) <= p.StabilizationWindow {
+      state.recommendations[i] = r
+      i++
+    }
+  }
+  state.recommendations = state.recommendations[:i]
+
+  if current != p.bound(current) {
+    return p.bound(current)
+  }
+
+  if target > current {
+    if !state.lastScaleUp.IsZero() && now.Sub(state.lastScaleUp) < p.ScaleUpCooldown {
+      return current
+    }
+    return target
+  }
+
+  if target < current {
+
+    // Only scale down to the highest replica count recommended within the stabilization window
+    for _, r := range(state.recommendations){
+      if r.replicas > target {
+        target = r.replicas
+      }
+    }
+
+    if target >= current {
+      return current
+    }
+
+    if !state.lastScaleDown.IsZero() && now.Sub(state.lastScaleDown) < p.ScaleDownCooldown {
+      return current
+    }
+  }
+
+  return target
+}
+
+// Record a successful scaling operation so that the cooldown windows start
+func (state *ScaleState) markScaled(from, to int32, now time.Time){
+  if to > from {
+    state.lastScaleUp = now
+  }else if to < from {
+    state.lastScaleDown = now
+  }
+}
@@ -0,0 +1,408 @@
+package main
+
+import (
+  "errors"
+  "testing"
+  "time"
+  "github.com/alexnjh/epsilon/autoscaler/interfaces"
+)
+
+func TestMakeDecision(t *testing.T) {
+
+  tests := []struct{
+    name string
+    votes []Vote
+    want interfaces.ComputeResult
+    wantReplicas int32
+  }{
+    {
+      name: "no votes",
+      votes: nil,
+      want: interfaces.DoNotScale,
+      wantReplicas: 3,
+    },
+    {
+      name: "unweighted majority scale up",
+      votes: []Vote{
+        vote("a", 4, 1, 1),
+        vote("b", 4, 1, 1),
+        vote("c", 2, 1, 1),
+      },
+      want: interfaces.ScaleUp,
+      wantReplicas: 4,
+    },
+    {
+      name: "do not scale votes are abstentions",
+      votes: []Vote{
+        vote("a", 3, 1, 1),
+        vote("b", 3, 1, 1),
+        vote("c", 2, 1, 1),
+      },
+      want: interfaces.ScaleDown,
+      wantReplicas: 2,
+    },
+    {
+      name: "tie results in do not scale",
+      votes: []Vote{
+        vote("a", 4, 1, 1),
+        vote("b", 2, 1, 1),
+      },
+      want: interfaces.DoNotScale,
+      wantReplicas: 3,
+    },
+    {
+      name: "heavier plugin outvotes majority",
+      votes: []Vote{
+        vote("a", 4, 1, 3),
+        vote("b", 2, 1, 1),
+        vote("c", 2, 1, 1),
+      },
+      want: interfaces.ScaleUp,
+      wantReplicas: 4,
+    },
+    {
+      name: "zero weight plugin is ignored",
+      votes: []Vote{
+        vote("a", 4, 1, 0),
+        vote("b", 3, 1, 1),
+      },
+      want: interfaces.DoNotScale,
+      wantReplicas: 3,
+    },
+    {
+      name: "confidence scales the weight",
+      votes: []Vote{
+        vote("a", 4, 0.25, 2),
+        vote("b", 2, 1, 1),
+      },
+      want: interfaces.ScaleDown,
+      wantReplicas: 2,
+    },
+    {
+      name: "failed plugin abstains",
+      votes: []Vote{
+        {Plugin: "a", Recommendation: interfaces.Recommendation{DesiredReplicas: 10, Confidence: 1}, Weight: 5, Err: errors.New("failed")},
+        vote("b", 2, 1, 1),
+      },
+      want: interfaces.ScaleDown,
+      wantReplicas: 2,
+    },
+    {
+      name: "desired replicas is the weighted average of the winning side",
+      votes: []Vote{
+        vote("a", 4, 1, 1),
+        vote("b", 7, 1, 2),
+        vote("c", 1, 1, 1),
+      },
+      want: interfaces.ScaleUp,
+      wantReplicas: 6,
+    },
+    {
+      name: "desired replicas is rounded away from current",
+      votes: []Vote{
+        vote("a", 4, 1, 3),
+        vote("b", 5, 1, 1),
+      },
+      want: interfaces.ScaleUp,
+      wantReplicas: 5,
+    },
+  }
+
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      got, replicas := makeDecision(tt.votes, 3)
+      if got != tt.want || replicas != tt.wantReplicas {
+        t.Errorf("makeDecision() = %v, %v, want %v, %v", got, replicas, tt.want, tt.wantReplicas)
+      }
+    })
+  }
+}
+
+func vote(name string, desired int32, confidence, weight float64) Vote {
+  return Vote{
+    Plugin: name,
+    Recommendation: interfaces.Recommendation{DesiredReplicas: desired, Confidence: confidence},
+    Weight: weight,
+  }
+}
+
+func TestNextReplicas(t *testing.T) {
+
+  now := time.Now()
+
+  policy := ScalingPolicy{
+    MinReplicas: 1,
+    MaxReplicas: 5,
+    StepSize: 2,
+    ScaleUpCooldown: time.Minute,
+    ScaleDownCooldown: 5*time.Minute,
+    StabilizationWindow: 10*time.Minute,
+  }
+
+  tests := []struct{
+    name string
+    state ScaleState
+    current int32
+    desired int32
+    want int32
+  }{
+    {
+      name: "scale up is bounded by step size",
+      current: 1,
+      desired: 4,
+      want: 3,
+    },
+    {
+      name: "scale up by less than step size",
+      current: 1,
+      desired: 2,
+      want: 2,
+    },
+    {
+      name: "scale up is bounded by max replicas",
+      current: 4,
+      desired: 6,
+      want: 5,
+    },
+    {
+      name: "scale down is bounded by min replicas",
+      current: 2,
+      desired: 0,
+      want: 1,
+    },
+    {
+      name: "do not scale",
+      current: 3,
+      desired: 3,
+      want: 3,
+    },
+    {
+      name: "replicas above max are corrected",
+      current: 8,
+      desired: 8,
+      want: 5,
+    },
+    {
+      name: "replicas above max are corrected during cooldown",
+      state: ScaleState{lastScaleDown: now.Add(-time.Second)},
+      current: 8,
+      desired: 9,
+      want: 5,
+    },
+    {
+      name: "scale up during cooldown",
+      state: ScaleState{lastScaleUp: now.Add(-30*time.Second)},
+      current: 1,
+      desired: 3,
+      want: 1,
+    },
+    {
+      name: "scale up after cooldown",
+      state: ScaleState{lastScaleUp: now.Add(-2*time.Minute)},
+      current: 1,
+      desired: 3,
+      want: 3,
+    },
+    {
+      name: "scale down during cooldown",
+      state: ScaleState{lastScaleDown: now.Add(-time.Minute)},
+      current: 5,
+      desired: 3,
+      want: 5,
+    },
+    {
+      name: "scale down is stabilized by recent recommendation",
+      state: ScaleState{recommendations: []recommendation{{now.Add(-time.Minute), 5}}},
+      current: 5,
+      desired: 3,
+      want: 5,
+    },
+    {
+      name: "scale down to highest recent recommendation",
+      state: ScaleState{recommendations: []recommendation{{now.Add(-time.Minute), 4}}},
+      current: 5,
+      desired: 3,
+      want: 4,
+    },
+    {
+      name: "expired recommendations are ignored",
+      state: ScaleState{recommendations: []recommendation{{now.Add(-time.Hour), 5}}},
+      current: 5,
+      desired: 3,
+      want: 3,
+    },
+  }
+
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      state := tt.state
+      if got := policy.nextReplicas(&state, tt.current, tt.desired, now); got != tt.want {
+        t.Errorf("nextReplicas() = %v, want %v", got, tt.want)
+      }
+    })
+  }
+}
+
+func TestScaleDownStabilization(t *testing.T) {
+
+  policy := ScalingPolicy{
+    MinReplicas: 1,
+    MaxReplicas: 10,
+    StepSize: 1,
+    StabilizationWindow: 3*time.Minute,
+  }
+
+  state := &ScaleState{}
+  start := time.Now()
+  current := int32(4)
+
+  // The recommendation to keep 4 replicas prevents a scale down until it leaves the window
+  decisions := []struct{
+    desired int32
+    want int32
+  }{
+    {4, 4},
+    {3, 4},
+    {3, 4},
+    {3, 4},
+    {3, 3},
+  }
+
+  for i, d := range decisions {
+    now := start.Add(time.Duration(i)*time.Minute)
+    got := policy.nextReplicas(state, current, d.desired, now)
+    if got != d.want {
+      t.Fatalf("interval %d: nextReplicas() = %v, want %v", i, got, d.want)
+    }
+    state.markScaled(current, got, now)
+    current = got
+  }
+}
+
+func TestGetScalingPolicy(t *testing.T) {
+
+  tests := []struct{
+    name string
+    values map[string]string
+    want ScalingPolicy
+    wantErr bool
+  }{
+    {
+      name: "defaults",
+      values: map[string]string{},
+      want: ScalingPolicy{MinReplicas: DefaultMinReplicas, MaxReplicas: DefaultMaxReplicas, StepSize: DefaultStepSize},
+    },
+    {
+      name: "all values set",
+      values: map[string]string{
+        "min_replicas": "2",
+        "max_replicas": "20",
+        "step_size": "3",
+        "scale_up_cooldown": "60",
+        "scale_down_cooldown": "300",
+        "stabilization_window": "600",
+      },
+      want: ScalingPolicy{
+        MinReplicas: 2,
+        MaxReplicas: 20,
+        StepSize: 3,
+        ScaleUpCooldown: time.Minute,
+        ScaleDownCooldown: 5*time.Minute,
+        StabilizationWindow: 10*time.Minute,
+      },
+    },
+    {
+      name: "max smaller than min",
+      values: map[string]string{"min_replicas": "5", "max_replicas": "2"},
+      wantErr: true,
+    },
+    {
+      name: "invalid duration",
+      values: map[string]string{"scale_up_cooldown": "1m"},
+      wantErr: true,
+    },
+  }
+
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      got, err := getScalingPolicy(mapLookup(tt.values))
+      if (err != nil) != tt.wantErr {
+        t.Fatalf("getScalingPolicy() error = %v, wantErr %v", err, tt.wantErr)
+      }
+      if !tt.wantErr && got != tt.want {
+        t.Errorf("getScalingPolicy() = %+v, want %+v", got, tt.want)
+      }
+    })
+  }
+}
+
+func TestGetPluginConfigs(t *testing.T) {
+
+  got, err := getPluginConfigs(mapLookup(map[string]string{
+    "plugins": "queuetheory, rabbitmq",
+    "queuetheory_threshold": "0.2",
+    "rabbitmq_weight": "2.5",
+  }))
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  want := []PluginConfig{
+    {Name: "queuetheory", Threshold: 0.2, Weight: 1},
+    {Name: "rabbitmq", Threshold: 0.5, Weight: 2.5},
+  }
+
+  if len(got) != len(want) {
+    t.Fatalf("getPluginConfigs() = %+v, want %+v", got, want)
+  }
+  for i := range want {
+    if got[i] != want[i] {
+      t.Errorf("getPluginConfigs()[%d] = %+v, want %+v", i, got[i], want[i])
+    }
+  }
+
+  if _, err := getPluginConfigs(mapLookup(map[string]string{"plugins": "unknown"})); err == nil {
+    t.Errorf("expected error for unknown plugin")
+  }
+}
+
+func TestGetScaleTargets(t *testing.T) {
+
+  lookup := mapLookup(map[string]string{
+    "queues": "epsilon.distributed, epsilon.short",
+    "epsilon_short_target_resource": "statefulsets.apps",
+    "epsilon_short_target_selector": "app=short-scheduler",
+    "epsilon_short_scale_to_zero": "true",
+    "epsilon_short_idle_window": "600",
+  })
+
+  targets, err := getScaleTargets(lookup, getQueues(lookup, "epsilon.distributed"))
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  if len(targets) != 2 {
+    t.Fatalf("getScaleTargets() = %+v, want 2 targets", targets)
+  }
+  if tg := targets[0]; tg.Queue != "epsilon.distributed" || tg.Resource.String() != "deployments.apps" || tg.Selector.String() != QueueLabel+"=epsilon.distributed" || tg.ScaleToZero || tg.IdleWindow != DefaultIdleWindow {
+    t.Errorf("targets[0] = %+v", tg)
+  }
+  if tg := targets[1]; tg.Queue != "epsilon.short" || tg.Resource.String() != "statefulsets.apps" || tg.Selector.String() != "app=short-scheduler" || !tg.ScaleToZero || tg.IdleWindow != 10*time.Minute {
+    t.Errorf("targets[1] = %+v", tg)
+  }
+
+  if q := getQueues(mapLookup(nil), "epsilon.distributed"); len(q) != 1 || q[0] != "epsilon.distributed" {
+    t.Errorf("getQueues() = %v, want the default queue", q)
+  }
+
+  if _, err := getScaleTargets(mapLookup(map[string]string{"target_selector": "="}), []string{"epsilon.distributed"}); err == nil {
+    t.Errorf("expected error for invalid selector")
+  }
+}
+
+func mapLookup(values map[string]string) lookupFunc {
+  return func(key string) (string, bool) {
+    val, ok := values[key]
+    return val, ok
+  }
+}
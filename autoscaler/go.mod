@@ -8,9 +8,7 @@ require (
 	github.com/michaelklishin/rabbit-hole/v2 v2.5.0
 	github.com/prometheus/client_golang v1.8.0
 	github.com/prometheus/common v0.15.0
-	github.com/sajari/regression v1.0.1
 	github.com/sirupsen/logrus v1.7.0
-	gonum.org/v1/gonum v0.8.1 // indirect
 	k8s.io/api v0.19.4
 	k8s.io/apimachinery v0.19.4
 	k8s.io/client-go v0.19.0
@@ -4,7 +4,11 @@ import (
   "os"
 
   "k8s.io/client-go/rest"
+  "k8s.io/client-go/scale"
+  "k8s.io/client-go/dynamic"
+  "k8s.io/client-go/restmapper"
   "k8s.io/client-go/kubernetes"
+  "k8s.io/client-go/discovery/cached/memory"
   "k8s.io/client-go/tools/clientcmd"
   log "github.com/sirupsen/logrus"
   configparser "github.com/bigkevmcd/go-configparser"
@@ -20,8 +24,8 @@ func getConfig(path string) (*configparser.ConfigParser, error){
   return p,nil
 }
 
-// Retrieve the Kubernetes cluster client either from outside the cluster or inside the cluster
-func getKubernetesClient() (kubernetes.Interface){
+// Retrieve the Kubernetes cluster config either from outside the cluster or inside the cluster
+func getKubernetesConfig() (*rest.Config){
 	// construct the path to resolve to `~/.kube/config`
   config, err := rest.InClusterConfig()
   if err != nil {
@@ -35,6 +39,12 @@ func getKubernetesClient() (kubernetes.Interface){
     }
   }
 
+  return config
+}
+
+// Retrieve the Kubernetes cluster client
+func getKubernetesClient(config *rest.Config) (kubernetes.Interface){
+
   // generate the client based off of the config
   client, err := kubernetes.NewForConfig(config)
   if err != nil {
@@ -44,3 +54,22 @@ func getKubernetesClient() (kubernetes.Interface){
 	log.Info("Successfully constructed k8s client")
 	return client
 }
+
+// Create the client used to scale the scheduler workloads through the scale subresource.
+// The resources of the workloads are resolved using the discovery API so custom resources are supported.
+func getTargetClient(config *rest.Config, client kubernetes.Interface, namespace string) (*TargetClient){
+
+  dynamicClient, err := dynamic.NewForConfig(config)
+  if err != nil {
+    log.Fatalf("Failed to create dynamic client: %v", err)
+  }
+
+  mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(client.Discovery()))
+
+  scales, err := scale.NewForConfig(config, mapper, dynamic.LegacyAPIPathResolverFunc, scale.NewDiscoveryScaleKindResolver(client.Discovery()))
+  if err != nil {
+    log.Fatalf("Failed to create scale client: %v", err)
+  }
+
+  return NewTargetClient(dynamicClient, scales, mapper, namespace)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+  "time"
+  rabbithole "github.com/michaelklishin/rabbit-hole/v2"
+)
+
+// Default time between checks of the queues scaled to zero for new messages
+const DefaultWakeInterval = 5*time.Second
+
+type queueActivity struct{
+  lastActive time.Time
+  // Number of messages published to the queue when it was last observed
+  published int64
+}
+
+// IdleTracker records when messages were last seen in each queue
+type IdleTracker struct{
+  queues map[string]*queueActivity
+}
+
+// Creates a new IdleTracker
+func NewIdleTracker() *IdleTracker{
+  return &IdleTracker{
+    queues: make(map[string]*queueActivity),
+  }
+}
+
+// Observe the statistics of a queue and return how long the queue has been idle. A queue is active
+// while it has messages or when messages are published to it since it was last observed. A queue
+// observed for the first time is assumed to be active.
+func (t *IdleTracker) Observe(queue rabbithole.QueueInfo, now time.Time) time.Duration{
+
+  published := queue.MessageStats.Publish
+
+  a, ok := t.queues[queue.Name]
+  if !ok {
+    t.queues[queue.Name] = &queueActivity{lastActive: now, published: published}
+    return 0
+  }
+
+  if queue.Messages > 0 || published != a.published {
+    a.lastActive = now
+  }
+
+  a.published = published
+
+  return now.Sub(a.lastActive)
+}
+
+// Check if a queue has messages waiting for a scheduler
+func hasMessages(queue rabbithole.QueueInfo) bool{
+  return queue.Messages > 0 || queue.MessagesReady > 0
+}
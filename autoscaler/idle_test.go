@@ -0,0 +1,41 @@
+package main
+
+import (
+  "time"
+  "testing"
+  rabbithole "github.com/michaelklishin/rabbit-hole/v2"
+)
+
+func TestIdleTracker(t *testing.T) {
+
+  now := time.Now()
+  tracker := NewIdleTracker()
+
+  queue := rabbithole.QueueInfo{Name: "epsilon.shortjob"}
+  queue.MessageStats.Publish = 10
+
+  if idle := tracker.Observe(queue, now); idle != 0 {
+    t.Errorf("Observe() = %s on first observation, want 0", idle)
+  }
+
+  if idle := tracker.Observe(queue, now.Add(time.Minute)); idle != time.Minute {
+    t.Errorf("Observe() = %s, want 1m", idle)
+  }
+
+  // Messages published and consumed between two observations
+  queue.MessageStats.Publish = 11
+  if idle := tracker.Observe(queue, now.Add(2*time.Minute)); idle != 0 {
+    t.Errorf("Observe() = %s after a publish, want 0", idle)
+  }
+
+  // Messages waiting in the queue
+  queue.Messages = 1
+  if idle := tracker.Observe(queue, now.Add(5*time.Minute)); idle != 0 {
+    t.Errorf("Observe() = %s with messages, want 0", idle)
+  }
+
+  queue.Messages = 0
+  if idle := tracker.Observe(queue, now.Add(65*time.Minute)); idle != time.Hour {
+    t.Errorf("Observe() = %s, want 1h", idle)
+  }
+}
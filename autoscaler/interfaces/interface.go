@@ -2,22 +2,119 @@
 package interfaces
 
 import (
-
+  "time"
 )
 
 // ComputeResult indicate the different decision decided by the autoscaler plugins.
 type ComputeResult string
 
 const (
-  // Scale up the number of scheduler replicas by 1
+  // Scale up the number of scheduler replicas
 	ScaleUp ComputeResult = "ScaleUp"
   // Do not scale up/down scheduler replicas at all
 	DoNotScale ComputeResult = "DoNotScale"
-  // Scale down the number of scheduler replicas by 1
+  // Scale down the number of scheduler replicas
 	ScaleDown ComputeResult = "ScaleDown"
 )
 
-// Autoscaler plugin implementation defination
+// Sample contains the metrics of a queue recorded during a previous interval
+type Sample struct{
+  // Time the sample is recorded
+  Timestamp time.Time
+  // Number of pods waiting in the queue
+  QueueDepth float64
+  // Number of pods published to the queue per minute
+  ArrivalRate float64
+  // Number of pods received by the coordinator per minute, for all the queues
+  RequestRate float64
+  // Number of scheduler replicas
+  Replicas int32
+}
+
+// NodeChurn contains the changes to the nodes of the cluster observed within a window
+type NodeChurn struct{
+  // Length of the window the changes are counted in
+  Window time.Duration
+  // Nodes added to the cluster (eg. by a cluster autoscaler)
+  Joined int
+  // Nodes removed from the cluster
+  Removed int
+  // Nodes marked as unschedulable
+  Cordoned int
+  // Nodes marked as schedulable again
+  Uncordoned int
+  // Nodes that became ready
+  BecameReady int
+  // Nodes that stopped being ready
+  BecameNotReady int
+  // Nodes that recently joined and are not ready yet, they are expected to come online soon
+  JoiningNodes int
+}
+
+// MetricsSnapshot contains the cluster and queue metrics gathered by the autoscaler
+// during an interval. The same snapshot is given to every plugin.
+type MetricsSnapshot struct{
+  // Time the snapshot is taken
+  Timestamp time.Time
+  // Name of the queue the scheduler replicas are consuming from
+  QueueName string
+  // Number of pods waiting in the queue
+  QueueDepth float64
+  // Number of schedulers consuming from the queue
+  Consumers float64
+  // Consumer utilisation of the queue reported by RabbitMQ (0 to 1)
+  ConsumerUtilisation float64
+  // Number of pods published to the queue per minute
+  ArrivalRate float64
+  // Number of pods received by the coordinator per minute, for all the queues
+  RequestRate float64
+  // Number of pods a single scheduler replica is able to schedule per minute
+  ServiceRate float64
+  // Number of recently scheduled pods the service rate is measured from (0 if the default is used)
+  ServiceRateSamples int
+  // Number of nodes in the cluster
+  Nodes float64
+  // Number of nodes a pod without special requirements can be scheduled on
+  // (ready, schedulable and without NoSchedule or NoExecute taints)
+  FeasibleNodes float64
+  // Changes to the nodes of the cluster within the churn window
+  NodeChurn NodeChurn
+  // Number of pods of the queue that could not be scheduled and are waiting to be retried,
+  // they are sent to the queue again once nodes become available
+  UnschedulablePods float64
+  // Current number of scheduler replicas
+  Replicas int32
+  // Previous samples of the queue, oldest first
+  History []Sample
+}
+
+// Recommendation is the output of an autoscaler plugin
+type Recommendation struct{
+  // Number of scheduler replicas the plugin would like to have
+  DesiredReplicas int32
+  // How confident the plugin is about the recommendation (0 to 1)
+  Confidence float64
+}
+
+// Result converts the recommendation into a scaling decision relative to the current replicas
+func (r Recommendation) Result(current int32) ComputeResult{
+  if r.DesiredReplicas > current {
+    return ScaleUp
+  }else if r.DesiredReplicas < current {
+    return ScaleDown
+  }
+  return DoNotScale
+}
+
+// Autoscaler plugin implementation defination.
+// Plugins should not fetch their own data and should return an error instead of exiting
+// when the snapshot does not contain enough information to make a recommendation.
 type AutoScalerPlugin interface{
-  Compute(float64,float64,float64) ComputeResult
+  Compute(snapshot *MetricsSnapshot) (Recommendation, error)
+}
+
+// Plugins may implement StatusReporter to expose their internal state (eg. a model being trained)
+// in the status endpoint of the autoscaler. The returned values must be JSON serializable.
+type StatusReporter interface{
+  Status() map[string]interface{}
 }
@@ -5,23 +5,17 @@ import (
   "fmt"
   "time"
   "strconv"
-  "errors"
-  "context"
-  "k8s.io/client-go/kubernetes"
-  "k8s.io/client-go/util/retry"
-  "k8s.io/apimachinery/pkg/labels"
+  "net/http"
   rabbithole "github.com/michaelklishin/rabbit-hole/v2"
   kubeinformers "k8s.io/client-go/informers"
-  corev1 "k8s.io/api/core/v1"
-  appsv1 "k8s.io/api/apps/v1"
-  metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
   log "github.com/sirupsen/logrus"
-  applisters "k8s.io/client-go/listers/apps/v1"
+  "github.com/prometheus/client_golang/prometheus/promhttp"
   configparser "github.com/bigkevmcd/go-configparser"
   rabbitplugin "github.com/alexnjh/epsilon/autoscaler/plugins/rabbitmq"
   queueplugin "github.com/alexnjh/epsilon/autoscaler/plugins/queue_theory"
-  linearplugin "github.com/alexnjh/epsilon/autoscaler/plugins/linear_regression"
+  forecastplugin "github.com/alexnjh/epsilon/autoscaler/plugins/forecast"
   schedplugin "github.com/alexnjh/epsilon/autoscaler/plugins/scheduler_prob"
+  churnplugin "github.com/alexnjh/epsilon/autoscaler/plugins/node_churn"
   "github.com/alexnjh/epsilon/autoscaler/interfaces"
 )
 
@@ -60,6 +54,7 @@ func main() {
   }
 
   var mqHost, mqManagePort, mqUser, mqPass, namespace, defaultQueue, pcURL, updateInterval string
+  var lookup lookupFunc
 
   if err != nil {
 
@@ -85,6 +80,7 @@ func main() {
   	   log.Fatalf("Config not found, Environment variables missing")
     }
 
+    lookup = envLookup()
 
   }else{
 
@@ -112,6 +108,10 @@ func main() {
     if err != nil {
       log.Fatalf(err.Error())
     }
+    defaultQueue, err = config.Get("DEFAULTS", "default_queue")
+    if err != nil {
+      log.Fatalf(err.Error())
+    }
     pcURL, err = config.Get("CoordinatorService", "metrics_absolute_url")
     if err != nil {
       log.Fatalf(err.Error())
@@ -120,6 +120,8 @@ func main() {
     if err != nil {
       log.Fatalf(err.Error())
     }
+
+    lookup = configLookup(config)
   }
 
   interval, err := strconv.Atoi(updateInterval)
@@ -127,17 +129,89 @@ func main() {
 	   log.Fatalf(err.Error())
   }
 
-  queueList := map[string]bool {
-    fmt.Sprintf(defaultQueue): true,
+  policy, err := getScalingPolicy(lookup)
+  if err != nil {
+    log.Fatalf(err.Error())
+  }
+
+  pluginConfigs, err := getPluginConfigs(lookup)
+  if err != nil {
+    log.Fatalf(err.Error())
+  }
+
+  serviceRate, err := getServiceRate(lookup)
+  if err != nil {
+    log.Fatalf(err.Error())
+  }
+
+  forecastCfg, err := getForecastConfig(lookup)
+  if err != nil {
+    log.Fatalf(err.Error())
+  }
+
+  churnWindow, err := getNodeChurnWindow(lookup)
+  if err != nil {
+    log.Fatalf(err.Error())
+  }
+
+  wakeInterval, err := getWakeInterval(lookup)
+  if err != nil {
+    log.Fatalf(err.Error())
+  }
+
+  targets, err := getScaleTargets(lookup, getQueues(lookup, defaultQueue))
+  if err != nil {
+    log.Fatalf(err.Error())
+  }
+
+  auditCfg, err := getAuditConfig(lookup)
+  if err != nil {
+    log.Fatalf(err.Error())
+  }
+
+  var decisionLogger *DecisionLogger
+  if len(auditCfg.DecisionLog) != 0 {
+    decisionLogger, err = NewDecisionLogger(auditCfg.DecisionLog)
+    if err != nil {
+      log.Fatalf(err.Error())
+    }
+    defer decisionLogger.Close()
+  }
+
+  if auditCfg.DryRun {
+    log.Infof("Running in dry run mode, scheduler replicas will not be changed")
+  }
+
+  // Start metric server
+  registerMetrics()
+
+  log.Infof("Scaling policy: %+v", policy)
+
+  // Scheduler workload of every queue that is autoscaled
+  targetList := make(map[string]ScaleTarget)
+
+  for _, target := range(targets){
+    targetList[target.Queue] = target
+    log.Infof("Autoscaling %s with selector %s for queue %s", target.Resource, target.Selector, target.Queue)
+    if target.ScaleToZero {
+      log.Infof("%s is scaled to zero after being idle for %s", target.Queue, target.IdleWindow)
+    }
   }
 
+  status := NewStatusTracker(targets, policy, auditCfg.DryRun)
+  go metricsServer(status)
+
   // Create informers to be inform of updates to the cluster state
-  kubeClient := getKubernetesClient()
+  kubeConfig := getKubernetesConfig()
+  kubeClient := getKubernetesClient(kubeConfig)
+  targetClient := getTargetClient(kubeConfig, kubeClient, namespace)
   kubeInformerFactory := kubeinformers.NewSharedInformerFactory(kubeClient, time.Second*30)
   nodeInformer := kubeInformerFactory.Core().V1().Nodes().Informer()
   nodeLister := kubeInformerFactory.Core().V1().Nodes().Lister()
-  deployInformer := kubeInformerFactory.Apps().V1().Deployments().Informer()
-  deployLister := kubeInformerFactory.Apps().V1().Deployments().Lister()
+  nodeTracker := NewNodeTracker(churnWindow)
+  nodeInformer.AddEventHandler(nodeTracker.Handlers())
+  podInformer := kubeInformerFactory.Core().V1().Pods().Informer()
+  podLister := kubeInformerFactory.Core().V1().Pods().Lister()
 
   // use a channel to synchronize the finalization for a graceful shutdown
 	stopCh := make(chan struct{})
@@ -148,7 +222,7 @@ func main() {
 
   log.Infof("Waiting for cache to be populated")
   for {
-    if nodeInformer.HasSynced() && deployInformer.HasSynced(){
+    if nodeInformer.HasSynced() && podInformer.HasSynced(){
         log.Infof("Cache is populated\n\n")
         break
     }
@@ -169,29 +243,26 @@ func main() {
   log.Infof("Management version: %s",res.ManagementVersion)
   log.Infof("Erlang version: %s",res.ErlangVersion)
 
-  pluginList := make(map[string]interfaces.AutoScalerPlugin)
-
-  qs, err := rmqc.ListQueues()
+  // Plugins of every queue, created when the queue is first seen
+  queuePlugins := make(map[string][]configuredPlugin)
 
-  if err != nil{
-    log.Fatalf(err.Error())
-  }
+  scaler := NewScaler(kubeClient,targetClient,policy,auditCfg.DryRun,decisionLogger)
+  collector := NewMetricsCollector(nodeLister,nodeTracker,podLister,defaultQueue,fmt.Sprintf("http://%s",pcURL),serviceRate)
 
-  for _ , queue := range(qs){
-    if queue.Name == defaultQueue{
 
-      // Initialize Plugins
-      pluginList["rabbitmq"]=rabbitplugin.NewRabbitMQPlugin("rabbitmq",queue.Vhost,queue.Name,0.5,rmqc)
-      pluginList["schedprob"]=schedplugin.NewSchedProbPlugin("schedprob",queue.Name,0.5)
-      pluginList["reggression"]=linearplugin.NewLinearRegressionPlugin("reggression",queue.Name,5)
-      pluginList["queuetheory"]=queueplugin.NewQueueTheoryPlugin("queuetheory",0.5,fmt.Sprintf("http://%s",pcURL))
+  // Queues that are checked for new messages every wake interval
+  scaledToZero := make(map[string]bool)
+  idleTracker := NewIdleTracker()
 
-      break
+  // Queues scaled to zero are checked more often than the plugins are computed
+  sleep := time.Duration(interval)*time.Second
+  for _, target := range(targets){
+    if target.ScaleToZero && wakeInterval < sleep {
+      sleep = wakeInterval
     }
   }
 
-  temp := make([]interfaces.ComputeResult,len(pluginList))
-
+  var lastEvaluation time.Time
 
   // Main process loop
   for {
@@ -202,157 +273,155 @@ func main() {
       log.Fatalf(err.Error())
     }
 
+    now := time.Now()
+    evaluate := now.Sub(lastEvaluation) >= time.Duration(interval)*time.Second
+    if evaluate {
+      lastEvaluation = now
+    }
+
     for _ , queue := range(qs){
-      if queueList[queue.Name] {
+      if target, ok := targetList[queue.Name]; ok {
+
+          idle := idleTracker.Observe(queue,now)
+
+          // Wake the scheduler as soon as a message is published to a queue scaled to zero
+          if scaledToZero[queue.Name] {
+            if hasMessages(queue) {
+              log.Infof("Message published to %s, waking scheduler",queue.Name)
+              record := scaler.Wake(target)
+              status.UpdateDecision(record)
+              if len(record.Error) == 0 {
+                delete(scaledToZero,queue.Name)
+              }
+            }
+            status.UpdateIdle(queue.Name,idle,scaledToZero[queue.Name])
+            continue
+          }
+
+          if !evaluate {
+            continue
+          }
 
         log.Infof("Queue Name: %s\n------------------------------",queue.Name)
 
-          nodeList, err := nodeLister.List(labels.NewSelector())
+          workload, err := targetClient.Get(target)
 
           if err != nil{
-            log.Fatalf(err.Error())
+            log.Errorf("Unable to get scheduler workload for %s: %s",queue.Name,err.Error())
+            continue
           }
 
-          noOfPendingPods := float64(queue.MessagesReady)
-          noOfNodes := float64(len(nodeList))
-          noOfSched := float64(queue.Consumers)
-
-          var i = 0
-          for key , plugin := range(pluginList){
-            temp[i] = plugin.Compute(noOfPendingPods,noOfNodes,noOfSched)
-            log.Infof("%s Decision:  %s",key,temp[i])
-            i++
+          if target.ScaleToZero {
+
+            if workload.Replicas == 0 {
+              scaledToZero[queue.Name] = true
+              status.UpdateIdle(queue.Name,idle,true)
+              log.Infof("%s is scaled to zero, waiting for messages",queue.Name)
+              continue
+            }
+
+            if !hasMessages(queue) && idle >= target.IdleWindow {
+              log.Infof("%s has been idle for %s, scaling to zero",queue.Name,idle)
+              record := scaler.ScaleToZero(target,idle)
+              status.UpdateDecision(record)
+              if record.Applied {
+                scaledToZero[queue.Name] = true
+              }
+              status.UpdateIdle(queue.Name,idle,record.Applied)
+              // A dry run only records the scale to zero, the plugins still evaluate the queue
+              if !record.DryRun {
+                continue
+              }
+            }
           }
 
-          result := makeDecision(temp)
-          UpdateDeployment(kubeClient,deployLister,namespace,queue.Name,result)
-      }
-    }
-
-    log.Infof("Sleeping for %d seconds before testing again...",interval)
-    time.Sleep(time.Duration(interval)*time.Second)
-
-  }
-}
-
-
-// Update the replica count of the scheduler services
-func UpdateDeployment(client kubernetes.Interface, lister applisters.DeploymentLister, namespace string, queueName string, decision interfaces.ComputeResult){
-
-  if decision == interfaces.DoNotScale {
-    return
-  }
-
-  labelmap := map[string]string{
-    "epsilon.queue" : queueName,
-  }
+          snapshot, err := collector.Collect(queue,workload.Replicas)
 
-  var deployment []*appsv1.Deployment
+          if err != nil{
+            log.Errorf("Unable to collect metrics for %s: %s",queue.Name,err.Error())
+            continue
+          }
 
-  retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+          recordSnapshotMetrics(snapshot)
 
-    if len(namespace) == 0{
-      deployment, _ = lister.List(labels.SelectorFromSet(labelmap))
-    }else{
-      deployment, _ = lister.Deployments(namespace).List(labels.SelectorFromSet(labelmap))
-    }
+          pluginList, ok := queuePlugins[queue.Name]
+          if !ok {
+            // Initialize Plugins
+            for _, cfg := range(pluginConfigs){
+              pluginList = append(pluginList, configuredPlugin{cfg, newPlugin(cfg, queue, time.Duration(interval)*time.Second, forecastCfg)})
+              log.Infof("Enabled plugin %s for %s (threshold: %v, weight: %v)", cfg.Name, queue.Name, cfg.Threshold, cfg.Weight)
+            }
+            queuePlugins[queue.Name] = pluginList
+          }
 
-    if len(deployment) > 1 {
-      return errors.New("More than one deployment found for queue name")
-    }
+          votes := make([]Vote,len(pluginList))
+
+          for i , plugin := range(pluginList){
+            rec, err := plugin.Compute(snapshot)
+            votes[i] = Vote{
+              Plugin: plugin.Name,
+              Recommendation: rec,
+              Weight: plugin.Weight,
+              Err: err,
+            }
+            if err != nil {
+              log.Errorf("%s Error:  %s",plugin.Name,err.Error())
+            }else{
+              log.Infof("%s Decision:  %s (desired replicas: %d, confidence: %.2f)",plugin.Name,rec.Result(snapshot.Replicas),rec.DesiredReplicas,rec.Confidence)
+            }
+          }
 
-    if len(deployment) == 0 {
-      return errors.New("No deployment found for queue name")
+          result, desired := makeDecision(votes,snapshot.Replicas)
+          log.Infof("Consolidated Decision:  %s (desired replicas: %d)",result,desired)
+          record := scaler.Scale(target,result,desired,votes)
+          status.Update(snapshot,pluginList,record)
+          status.UpdateIdle(queue.Name,idle,false)
+      }
     }
 
-    obj := deployment[0]
-
-    if decision == interfaces.ScaleUp {
-      *obj.Spec.Replicas +=1
-    }else if decision == interfaces.ScaleDown && *obj.Spec.Replicas > 1{
-      *obj.Spec.Replicas -=1
-    }else{
-      return nil
+    if evaluate {
+      log.Infof("Sleeping for %d seconds before testing again...",interval)
     }
+    time.Sleep(sleep)
 
-    _, updateErr := client.AppsV1().Deployments(obj.Namespace).Update(context.TODO(), obj, metav1.UpdateOptions{});
-    return updateErr
-  })
-  if retryErr != nil {
-    panic(fmt.Errorf("Update failed: %v", retryErr))
   }
-
 }
 
-// Consolidate all the decisions made by the plugins and decide on the next course of action.
-func makeDecision(result []interfaces.ComputeResult) interfaces.ComputeResult{
-  count := make(map[interfaces.ComputeResult]int)
-
-  for _, r := range(result){
-    count[r] +=1
-  }
 
+// An enabled plugin together with its user defined settings
+type configuredPlugin struct{
+  PluginConfig
+  interfaces.AutoScalerPlugin
+}
 
-  largest := interfaces.ScaleUp
-
-  if(count[interfaces.ScaleUp] > count[interfaces.ScaleDown]){
-    largest = interfaces.ScaleUp
-  }else{
-    largest = interfaces.ScaleDown
-  }
-
-  if(count[interfaces.ScaleUp] == count[interfaces.ScaleDown]){
-    largest = interfaces.DoNotScale
+// Create the plugin specified in the plugin config
+func newPlugin(cfg PluginConfig, queue rabbithole.QueueInfo, interval time.Duration, forecastCfg ForecastConfig) interfaces.AutoScalerPlugin{
+  switch cfg.Name {
+  case "rabbitmq":
+    return rabbitplugin.NewRabbitMQPlugin(cfg.Name,queue.Name,cfg.Threshold)
+  case "schedprob":
+    return schedplugin.NewSchedProbPlugin(cfg.Name,queue.Name,cfg.Threshold)
+  case "forecast":
+    return forecastplugin.NewForecastPlugin(cfg.Name,queue.Name,cfg.Threshold,toIntervals(forecastCfg.Season,interval),toIntervals(forecastCfg.Horizon,interval))
+  case "queuetheory":
+    return queueplugin.NewQueueTheoryPlugin(cfg.Name,time.Duration(cfg.Threshold*float64(time.Second)))
+  case "nodechurn":
+    return churnplugin.NewNodeChurnPlugin(cfg.Name,queue.Name,time.Duration(cfg.Threshold*float64(time.Second)))
   }
-
-  return largest
-
+  log.Fatalf("Unknown autoscaler plugin %s", cfg.Name)
+  return nil
 }
 
-// Update kube-api server of the autoscaler's scale down operations
-func addScaleDownEvent(client kubernetes.Interface, obj *appsv1.Deployment){
-  client.CoreV1().Events(obj.Namespace).Create(context.TODO(), &corev1.Event{
-    Count:          1,
-    Message:        "Scheduler replica reduced by 1",
-    Reason:         "ScaleDown",
-    LastTimestamp:  metav1.Now(),
-    FirstTimestamp: metav1.Now(),
-    Type:           "Information",
-    Source: corev1.EventSource{
-      Component: "autoscaler",
-    },
-    InvolvedObject: corev1.ObjectReference{
-      Kind:      "Deployment",
-      Name:      obj.Name,
-      Namespace: obj.Namespace,
-      UID:       obj.UID,
-    },
-    ObjectMeta: metav1.ObjectMeta{
-      GenerateName: obj.Name + "-",
-    },
-  },metav1.CreateOptions{})
-}
+/*
 
-// Update kube-api server of the autoscaler's scale up operations
-func addScaleUpEvent(client kubernetes.Interface, obj *appsv1.Deployment){
-  client.CoreV1().Events(obj.Namespace).Create(context.TODO(), &corev1.Event{
-    Count:          1,
-    Message:        "Scheduler replica increased by 1",
-    Reason:         "ScaleUp",
-    LastTimestamp:  metav1.Now(),
-    FirstTimestamp: metav1.Now(),
-    Type:           "Information",
-    Source: corev1.EventSource{
-      Component: "autoscaler",
-    },
-    InvolvedObject: corev1.ObjectReference{
-      Kind:      "Deployment",
-      Name:      obj.Name,
-      Namespace: obj.Namespace,
-      UID:       obj.UID,
-    },
-    ObjectMeta: metav1.ObjectMeta{
-      GenerateName: obj.Name + "-",
-    },
-  },metav1.CreateOptions{})
+Creates a prometheus based metrics server exporting autoscaler metrics and
+the current state of every queue and plugin as JSON at /status
+
+*/
+func metricsServer(status *StatusTracker){
+  // The Handler function provides a default handler to expose metrics
+  // via an HTTP server. "/metrics" is the usual endpoint for that.
+  http.Handle("/metrics", promhttp.Handler())
+  http.Handle("/status", status)
+  log.Fatal(http.ListenAndServe(":8080", nil))
 }
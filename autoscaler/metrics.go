@@ -0,0 +1,279 @@
+package main
+
+import (
+  "fmt"
+  "time"
+  "bufio"
+  "strings"
+  "strconv"
+  "net/http"
+  "k8s.io/apimachinery/pkg/labels"
+  rabbithole "github.com/michaelklishin/rabbit-hole/v2"
+  log "github.com/sirupsen/logrus"
+  corev1 "k8s.io/api/core/v1"
+  corelisters "k8s.io/client-go/listers/core/v1"
+  "github.com/alexnjh/epsilon/autoscaler/interfaces"
+)
+
+const (
+  // Number of previous samples kept for every queue
+  MaxHistory = 60
+
+  // Only pods scheduled within this window are used to measure the service rate
+  ServiceRateWindow = 10*time.Minute
+
+  // Annotation added by the schedulers containing the time taken to schedule the pod
+  SchedulingTimeAnnotation = "epsilon.scheduling.time"
+
+  // Label of a pod containing the queue the pod is sent to
+  QueueLabel = "epsilon.queue"
+)
+
+// MetricsCollector gathers the metrics required by the plugins into a snapshot
+type MetricsCollector struct{
+  nodeLister corelisters.NodeLister
+  // Optional, records the changes to the nodes
+  nodeTracker *NodeTracker
+  podLister corelisters.PodLister
+  // Queue used by pods without a queue label
+  defaultQueue string
+  // Absolute url of the coordinator metrics endpoint
+  coordinatorURL string
+  // Number of pods a scheduler replica is able to schedule per minute if it cannot be measured
+  serviceRate float64
+  history map[string][]interfaces.Sample
+}
+
+// Creates a new MetricsCollector
+func NewMetricsCollector(
+  nodeLister corelisters.NodeLister,
+  nodeTracker *NodeTracker,
+  podLister corelisters.PodLister,
+  defaultQueue string,
+  coordinatorURL string,
+  serviceRate float64) *MetricsCollector{
+
+  return &MetricsCollector{
+    nodeLister: nodeLister,
+    nodeTracker: nodeTracker,
+    podLister: podLister,
+    defaultQueue: defaultQueue,
+    coordinatorURL: coordinatorURL,
+    serviceRate: serviceRate,
+    history: make(map[string][]interfaces.Sample),
+  }
+}
+
+// Collect the metrics of a queue whose scheduler workload has the given replicas and record them
+// in the history of the queue
+func (c *MetricsCollector) Collect(queue rabbithole.QueueInfo, replicas int32) (*interfaces.MetricsSnapshot, error){
+
+  nodeList, err := c.nodeLister.List(labels.NewSelector())
+  if err != nil {
+    return nil, err
+  }
+
+  history := c.history[queue.Name]
+
+  snapshot := &interfaces.MetricsSnapshot{
+    Timestamp: time.Now(),
+    QueueName: queue.Name,
+    QueueDepth: float64(queue.MessagesReady),
+    Consumers: float64(queue.Consumers),
+    ConsumerUtilisation: queue.ConsumerUtilisation,
+    Nodes: float64(len(nodeList)),
+    FeasibleNodes: float64(countFeasibleNodes(nodeList)),
+    Replicas: replicas,
+    History: append([]interfaces.Sample(nil), history...),
+  }
+
+  snapshot.ServiceRate, snapshot.ServiceRateSamples = c.measureServiceRate(queue.Name, snapshot.Timestamp)
+  snapshot.UnschedulablePods = float64(c.countUnschedulablePods(queue.Name))
+
+  if c.nodeTracker != nil {
+    snapshot.NodeChurn = c.nodeTracker.Churn()
+  }
+
+  // RabbitMQ reports the publish rate of the queue per second
+  snapshot.ArrivalRate = float64(queue.MessageStats.PublishDetails.Rate)*60
+
+  metricMap, err := promToMap(c.coordinatorURL)
+  if err == nil {
+    snapshot.RequestRate, err = strconv.ParseFloat(metricMap["pod_request_total_in_1min"], 64)
+  }
+
+  // Use the previous request rate if the coordinator is unavailable
+  if err != nil {
+    log.Errorf("Unable to get request rate from coordinator: %s", err.Error())
+    if len(history) > 0 {
+      snapshot.RequestRate = history[len(history)-1].RequestRate
+    }
+  }
+
+  history = append(history, interfaces.Sample{
+    Timestamp: snapshot.Timestamp,
+    QueueDepth: snapshot.QueueDepth,
+    ArrivalRate: snapshot.ArrivalRate,
+    RequestRate: snapshot.RequestRate,
+    Replicas: snapshot.Replicas,
+  })
+
+  if len(history) > MaxHistory {
+    history = history[len(history)-MaxHistory:]
+  }
+
+  c.history[queue.Name] = history
+
+  return snapshot, nil
+}
+
+// Measure the number of pods a scheduler replica of a queue is able to schedule per minute from
+// the scheduling time recorded on pods scheduled recently. The default service rate is returned if
+// no pods are scheduled within the window.
+func (c *MetricsCollector) measureServiceRate(queueName string, now time.Time) (float64, int){
+
+  pods, err := c.podLister.List(labels.Everything())
+  if err != nil {
+    log.Errorf("Unable to list pods: %s", err.Error())
+    return c.serviceRate, 0
+  }
+
+  var total time.Duration
+  var count int
+
+  for _, pod := range(pods){
+
+    if c.queueOf(pod) != queueName {
+      continue
+    }
+
+    scheduledTime, ok := getScheduledTime(pod)
+    if !ok || now.Sub(scheduledTime) > ServiceRateWindow {
+      continue
+    }
+
+    d, err := time.ParseDuration(pod.Annotations[SchedulingTimeAnnotation])
+    if err != nil || d <= 0 {
+      continue
+    }
+
+    total += d
+    count++
+  }
+
+  if count == 0 {
+    return c.serviceRate, 0
+  }
+
+  return float64(time.Minute)/(float64(total)/float64(count)), count
+}
+
+// Count the pods of a queue that could not be scheduled because no node is able to run them
+func (c *MetricsCollector) countUnschedulablePods(queueName string) int{
+
+  pods, err := c.podLister.List(labels.Everything())
+  if err != nil {
+    log.Errorf("Unable to list pods: %s", err.Error())
+    return 0
+  }
+
+  count := 0
+
+  for _, pod := range(pods){
+    if c.queueOf(pod) == queueName && len(pod.Spec.NodeName) == 0 && isUnschedulable(pod) {
+      count++
+    }
+  }
+
+  return count
+}
+
+// Get the queue a pod is sent to
+func (c *MetricsCollector) queueOf(pod *corev1.Pod) string{
+  if queue := pod.Labels[QueueLabel]; len(queue) != 0 {
+    return queue
+  }
+  return c.defaultQueue
+}
+
+// Check if the scheduler failed to find a node for a pod
+func isUnschedulable(pod *corev1.Pod) bool{
+  for _, cond := range(pod.Status.Conditions){
+    if cond.Type == corev1.PodScheduled {
+      return cond.Status == corev1.ConditionFalse && cond.Reason == corev1.PodReasonUnschedulable
+    }
+  }
+  return false
+}
+
+// Count the nodes that are ready, schedulable and without NoSchedule or NoExecute taints
+func countFeasibleNodes(nodes []*corev1.Node) int{
+
+  count := 0
+
+  for _, node := range(nodes){
+    if isFeasible(node) {
+      count++
+    }
+  }
+
+  return count
+}
+
+func isFeasible(node *corev1.Node) bool{
+
+  if node.Spec.Unschedulable {
+    return false
+  }
+
+  for _, taint := range(node.Spec.Taints){
+    if taint.Effect == corev1.TaintEffectNoSchedule || taint.Effect == corev1.TaintEffectNoExecute {
+      return false
+    }
+  }
+
+  return isReady(node)
+}
+
+// Get the time a pod is bound to a node
+func getScheduledTime(pod *corev1.Pod) (time.Time, bool){
+  for _, cond := range(pod.Status.Conditions){
+    if cond.Type == corev1.PodScheduled && cond.Status == corev1.ConditionTrue {
+      return cond.LastTransitionTime.Time, true
+    }
+  }
+  return time.Time{}, false
+}
+
+// Convert prometheus formatted metrics into a map
+func promToMap(url string) (map[string]string, error){
+
+  metricMap := make(map[string]string)
+
+  resp, err := http.Get(url)
+  if err != nil {
+    return nil, err
+  }
+  defer resp.Body.Close()
+
+  if resp.StatusCode != http.StatusOK {
+    return nil, fmt.Errorf("Unexpected status code %d from %s", resp.StatusCode, url)
+  }
+
+  scanner := bufio.NewScanner(resp.Body)
+  for scanner.Scan() {
+    if len(scanner.Text()) > 0{
+      if scanner.Text()[0] != '#'{
+        s := strings.Split(scanner.Text(), " ")
+        if (len(s) == 2){
+          metricMap[s[0]]=s[1]
+        }
+      }
+    }
+  }
+  if err := scanner.Err(); err != nil {
+    return nil, err
+  }
+
+  return metricMap, nil
+}
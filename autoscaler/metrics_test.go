@@ -0,0 +1,157 @@
+package main
+
+import (
+  "fmt"
+  "time"
+  "testing"
+  "net/http"
+  "net/http/httptest"
+  "k8s.io/client-go/tools/cache"
+  corev1 "k8s.io/api/core/v1"
+  metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+  corelisters "k8s.io/client-go/listers/core/v1"
+  rabbithole "github.com/michaelklishin/rabbit-hole/v2"
+)
+
+func TestCollectRates(t *testing.T) {
+
+  coordinator := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    fmt.Fprintln(w, "# HELP pod_request_total_in_1min Number of pods received in the last minute")
+    fmt.Fprintln(w, "pod_request_total_in_1min 300")
+  }))
+  defer coordinator.Close()
+
+  empty := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+  c := NewMetricsCollector(corelisters.NewNodeLister(empty), nil, corelisters.NewPodLister(empty), "epsilon.distributed", coordinator.URL, DefaultServiceRate)
+
+  queue := rabbithole.QueueInfo{Name: "epsilon.shortjob"}
+  queue.MessageStats.PublishDetails.Rate = 2
+
+  snapshot, err := c.Collect(queue, 1)
+  if err != nil {
+    t.Fatalf("Collect() error = %v", err)
+  }
+
+  // The arrival rate of a queue is its own publish rate, the coordinator counts the pods of every queue
+  if snapshot.ArrivalRate != 120 {
+    t.Errorf("Collect() arrival rate = %v, want 120", snapshot.ArrivalRate)
+  }
+  if snapshot.RequestRate != 300 {
+    t.Errorf("Collect() request rate = %v, want 300", snapshot.RequestRate)
+  }
+
+  // The previous request rate is used when the coordinator is unavailable
+  coordinator.Close()
+  queue.MessageStats.PublishDetails.Rate = 1
+
+  snapshot, err = c.Collect(queue, 1)
+  if err != nil {
+    t.Fatalf("Collect() error = %v", err)
+  }
+  if snapshot.ArrivalRate != 60 || snapshot.RequestRate != 300 {
+    t.Errorf("Collect() = %v arrival rate, %v request rate, want 60, 300", snapshot.ArrivalRate, snapshot.RequestRate)
+  }
+}
+
+func TestMeasureServiceRate(t *testing.T) {
+
+  now := time.Now()
+
+  pods := []*corev1.Pod{
+    scheduledPod("a", "", "20ms", now.Add(-time.Minute)),
+    scheduledPod("b", "", "30ms", now.Add(-2*time.Minute)),
+    // Scheduled by the scheduler of another queue
+    scheduledPod("c", "epsilon.shortjob", "1ms", now.Add(-time.Minute)),
+    // Scheduled outside of the window
+    scheduledPod("d", "", "1s", now.Add(-time.Hour)),
+    // Not scheduled by epsilon
+    scheduledPod("e", "", "", now.Add(-time.Minute)),
+  }
+
+  indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+  for _, p := range pods {
+    indexer.Add(p)
+  }
+
+  c := NewMetricsCollector(nil, nil, corelisters.NewPodLister(indexer), "epsilon.distributed", "", DefaultServiceRate)
+
+  rate, samples := c.measureServiceRate("epsilon.distributed", now)
+  if samples != 2 || rate != 2400 {
+    t.Errorf("measureServiceRate() = %v, %d, want 2400, 2", rate, samples)
+  }
+
+  rate, samples = c.measureServiceRate("epsilon.unused", now)
+  if samples != 0 || rate != DefaultServiceRate {
+    t.Errorf("measureServiceRate() = %v, %d, want %v, 0", rate, samples, DefaultServiceRate)
+  }
+}
+
+func TestCountUnschedulablePods(t *testing.T) {
+
+  unschedulable := func(name, queue string, reason string) *corev1.Pod {
+    return &corev1.Pod{
+      ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: map[string]string{QueueLabel: queue}},
+      Status: corev1.PodStatus{
+        Conditions: []corev1.PodCondition{{Type: corev1.PodScheduled, Status: corev1.ConditionFalse, Reason: reason}},
+      },
+    }
+  }
+
+  indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+  indexer.Add(unschedulable("a", "epsilon.distributed", corev1.PodReasonUnschedulable))
+  indexer.Add(unschedulable("b", "", corev1.PodReasonUnschedulable))
+  indexer.Add(unschedulable("c", "epsilon.shortjob", corev1.PodReasonUnschedulable))
+  indexer.Add(unschedulable("d", "epsilon.distributed", "SchedulerError"))
+  indexer.Add(scheduledPod("e", "", "20ms", time.Now()))
+
+  c := NewMetricsCollector(nil, nil, corelisters.NewPodLister(indexer), "epsilon.distributed", "", DefaultServiceRate)
+
+  if n := c.countUnschedulablePods("epsilon.distributed"); n != 2 {
+    t.Errorf("countUnschedulablePods() = %d, want 2", n)
+  }
+}
+
+func scheduledPod(name, queue, schedulingTime string, scheduled time.Time) *corev1.Pod {
+
+  pod := &corev1.Pod{
+    ObjectMeta: metav1.ObjectMeta{
+      Name: name,
+      Namespace: "default",
+      Labels: map[string]string{},
+      Annotations: map[string]string{},
+    },
+    Status: corev1.PodStatus{
+      Conditions: []corev1.PodCondition{
+        {Type: corev1.PodScheduled, Status: corev1.ConditionTrue, LastTransitionTime: metav1.NewTime(scheduled)},
+      },
+    },
+  }
+
+  if len(queue) != 0 {
+    pod.Labels[QueueLabel] = queue
+  }
+  if len(schedulingTime) != 0 {
+    pod.Annotations[SchedulingTimeAnnotation] = schedulingTime
+  }
+
+  return pod
+}
+
+func TestCountFeasibleNodes(t *testing.T) {
+
+  ready := corev1.NodeStatus{Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}}}
+  notReady := corev1.NodeStatus{Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionFalse}}}
+
+  nodes := []*corev1.Node{
+    {Status: ready},
+    {Status: notReady},
+    {Status: corev1.NodeStatus{}},
+    {Spec: corev1.NodeSpec{Unschedulable: true}, Status: ready},
+    {Spec: corev1.NodeSpec{Taints: []corev1.Taint{{Key: "a", Effect: corev1.TaintEffectNoSchedule}}}, Status: ready},
+    {Spec: corev1.NodeSpec{Taints: []corev1.Taint{{Key: "a", Effect: corev1.TaintEffectPreferNoSchedule}}}, Status: ready},
+  }
+
+  if got := countFeasibleNodes(nodes); got != 2 {
+    t.Errorf("countFeasibleNodes() = %d, want 2", got)
+  }
+}
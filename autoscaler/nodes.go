@@ -0,0 +1,200 @@
+package main
+
+import (
+  "sync"
+  "time"
+  "k8s.io/client-go/tools/cache"
+  corev1 "k8s.io/api/core/v1"
+  log "github.com/sirupsen/logrus"
+  "github.com/alexnjh/epsilon/autoscaler/interfaces"
+)
+
+// Default window the node changes are counted in
+const DefaultNodeChurnWindow = 10*time.Minute
+
+type nodeEventType int
+
+const (
+  nodeJoined nodeEventType = iota
+  nodeRemoved
+  nodeCordoned
+  nodeUncordoned
+  nodeBecameReady
+  nodeBecameNotReady
+)
+
+type nodeEvent struct{
+  timestamp time.Time
+  eventType nodeEventType
+}
+
+// NodeTracker watches the node informer and records the changes to the nodes of the cluster so the
+// plugins can react to nodes coming online before they are schedulable
+type NodeTracker struct{
+  mu sync.Mutex
+  window time.Duration
+  events []nodeEvent
+  // Creation time of the nodes that joined within the window and are not ready yet
+  joining map[string]time.Time
+  now func() time.Time
+}
+
+// Creates a new NodeTracker counting the changes within the window
+func NewNodeTracker(window time.Duration) *NodeTracker{
+  return &NodeTracker{
+    window: window,
+    joining: make(map[string]time.Time),
+    now: time.Now,
+  }
+}
+
+// Event handlers to be added to the node informer
+func (t *NodeTracker) Handlers() cache.ResourceEventHandlerFuncs{
+  return cache.ResourceEventHandlerFuncs{
+    AddFunc: func(obj interface{}){
+      if node, ok := obj.(*corev1.Node); ok {
+        t.onAdd(node)
+      }
+    },
+    UpdateFunc: func(oldObj, newObj interface{}){
+      oldNode, ok1 := oldObj.(*corev1.Node)
+      newNode, ok2 := newObj.(*corev1.Node)
+      if ok1 && ok2 {
+        t.onUpdate(oldNode, newNode)
+      }
+    },
+    DeleteFunc: func(obj interface{}){
+      if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+        obj = tombstone.Obj
+      }
+      if node, ok := obj.(*corev1.Node); ok {
+        t.onDelete(node)
+      }
+    },
+  }
+}
+
+func (t *NodeTracker) onAdd(node *corev1.Node){
+
+  t.mu.Lock()
+  defer t.mu.Unlock()
+
+  now := t.now()
+
+  // Nodes listed when the informer starts are only counted if they joined within the window
+  if now.Sub(node.CreationTimestamp.Time) > t.window {
+    return
+  }
+
+  log.Infof("Node %s joined the cluster", node.Name)
+  t.record(now, nodeJoined)
+
+  if !isReady(node) {
+    t.joining[node.Name] = node.CreationTimestamp.Time
+  }
+}
+
+func (t *NodeTracker) onUpdate(oldNode, newNode *corev1.Node){
+
+  t.mu.Lock()
+  defer t.mu.Unlock()
+
+  now := t.now()
+
+  if wasReady, ready := isReady(oldNode), isReady(newNode); wasReady != ready {
+    if ready {
+      log.Infof("Node %s became ready", newNode.Name)
+      t.record(now, nodeBecameReady)
+      delete(t.joining, newNode.Name)
+    }else{
+      log.Infof("Node %s is no longer ready", newNode.Name)
+      t.record(now, nodeBecameNotReady)
+    }
+  }
+
+  if oldNode.Spec.Unschedulable != newNode.Spec.Unschedulable {
+    if newNode.Spec.Unschedulable {
+      log.Infof("Node %s is cordoned", newNode.Name)
+      t.record(now, nodeCordoned)
+    }else{
+      log.Infof("Node %s is uncordoned", newNode.Name)
+      t.record(now, nodeUncordoned)
+    }
+  }
+}
+
+func (t *NodeTracker) onDelete(node *corev1.Node){
+
+  t.mu.Lock()
+  defer t.mu.Unlock()
+
+  log.Infof("Node %s left the cluster", node.Name)
+  t.record(t.now(), nodeRemoved)
+  delete(t.joining, node.Name)
+}
+
+// Must be called with the lock held
+func (t *NodeTracker) record(now time.Time, eventType nodeEventType){
+  t.events = append(t.events, nodeEvent{timestamp: now, eventType: eventType})
+  t.prune(now)
+}
+
+// Remove the events and joining nodes older than the window, must be called with the lock held
+func (t *NodeTracker) prune(now time.Time){
+
+  i := 0
+  for i < len(t.events) && now.Sub(t.events[i].timestamp) > t.window {
+    i++
+  }
+  t.events = t.events[i:]
+
+  // Nodes that are not ready after the window are not expected to come online soon
+  for name, created := range(t.joining){
+    if now.Sub(created) > t.window {
+      delete(t.joining, name)
+    }
+  }
+}
+
+// Get the changes to the nodes within the window
+func (t *NodeTracker) Churn() interfaces.NodeChurn{
+
+  t.mu.Lock()
+  defer t.mu.Unlock()
+
+  t.prune(t.now())
+
+  churn := interfaces.NodeChurn{
+    Window: t.window,
+    JoiningNodes: len(t.joining),
+  }
+
+  for _, e := range(t.events){
+    switch e.eventType {
+    case nodeJoined:
+      churn.Joined++
+    case nodeRemoved:
+      churn.Removed++
+    case nodeCordoned:
+      churn.Cordoned++
+    case nodeUncordoned:
+      churn.Uncordoned++
+    case nodeBecameReady:
+      churn.BecameReady++
+    case nodeBecameNotReady:
+      churn.BecameNotReady++
+    }
+  }
+
+  return churn
+}
+
+// Check if the ready condition of a node is true
+func isReady(node *corev1.Node) bool{
+  for _, cond := range(node.Status.Conditions){
+    if cond.Type == corev1.NodeReady {
+      return cond.Status == corev1.ConditionTrue
+    }
+  }
+  return false
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+  "time"
+  "testing"
+  corev1 "k8s.io/api/core/v1"
+  metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+  "github.com/alexnjh/epsilon/autoscaler/interfaces"
+)
+
+func churnNode(name string, created time.Time, ready bool, unschedulable bool) *corev1.Node {
+
+  status := corev1.ConditionFalse
+  if ready {
+    status = corev1.ConditionTrue
+  }
+
+  return &corev1.Node{
+    ObjectMeta: metav1.ObjectMeta{Name: name, CreationTimestamp: metav1.NewTime(created)},
+    Spec: corev1.NodeSpec{Unschedulable: unschedulable},
+    Status: corev1.NodeStatus{
+      Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: status}},
+    },
+  }
+}
+
+func TestNodeTracker(t *testing.T) {
+
+  now := time.Now()
+  tracker := NewNodeTracker(10*time.Minute)
+  tracker.now = func() time.Time { return now }
+
+  handlers := tracker.Handlers()
+
+  // Nodes listed when the informer starts are not counted as joined
+  old := churnNode("old", now.Add(-time.Hour), true, false)
+  handlers.OnAdd(old)
+
+  // A node pool coming online
+  handlers.OnAdd(churnNode("new-1", now, false, false))
+  handlers.OnAdd(churnNode("new-2", now, false, false))
+  handlers.OnUpdate(churnNode("new-1", now, false, false), churnNode("new-1", now, true, false))
+
+  // Cordon the old node
+  handlers.OnUpdate(old, churnNode("old", now.Add(-time.Hour), true, true))
+
+  want := interfaces.NodeChurn{Window: 10*time.Minute, Joined: 2, Cordoned: 1, BecameReady: 1, JoiningNodes: 1}
+  if got := tracker.Churn(); got != want {
+    t.Errorf("Churn() = %+v, want %+v", got, want)
+  }
+
+  handlers.OnDelete(churnNode("new-2", now, false, false))
+
+  want.Removed, want.JoiningNodes = 1, 0
+  if got := tracker.Churn(); got != want {
+    t.Errorf("Churn() = %+v, want %+v", got, want)
+  }
+
+  // Changes outside of the window are forgotten
+  now = now.Add(11*time.Minute)
+  handlers.OnAdd(churnNode("stuck", now, false, false))
+  now = now.Add(11*time.Minute)
+
+  want = interfaces.NodeChurn{Window: 10*time.Minute}
+  if got := tracker.Churn(); got != want {
+    t.Errorf("Churn() = %+v, want %+v", got, want)
+  }
+}
@@ -0,0 +1,129 @@
+package forecast
+
+import (
+  "math"
+)
+
+// HoltWinters is an additive Holt-Winters (triple exponential smoothing) model that is updated
+// one observation at a time. Until a full season is observed or when the season length is 0
+// the model only tracks the level and trend (Holt's linear method).
+type HoltWinters struct{
+  // Smoothing factor of the level (0 to 1)
+  Alpha float64
+  // Smoothing factor of the trend (0 to 1)
+  Beta float64
+  // Smoothing factor of the seasonal component (0 to 1)
+  Gamma float64
+  // Number of observations in a season, 0 disables seasonality
+  SeasonLength int
+
+  level float64
+  trend float64
+  seasonal []float64
+  // Observations of the first season used to initialize the seasonal component
+  buffer []float64
+  // Number of observations
+  n int
+}
+
+// Creates a new HoltWinters model
+func NewHoltWinters(alpha, beta, gamma float64, seasonLength int) *HoltWinters{
+  return &HoltWinters{
+    Alpha: alpha,
+    Beta: beta,
+    Gamma: gamma,
+    SeasonLength: seasonLength,
+  }
+}
+
+// Update the model with the next observation
+func (m *HoltWinters) Update(x float64){
+
+  m.n++
+
+  if m.n == 1 {
+    m.level = x
+    m.trend = 0
+    if m.SeasonLength > 0 {
+      m.buffer = append(m.buffer, x)
+    }
+    return
+  }
+
+  if m.seasonal == nil {
+
+    prevLevel := m.level
+    m.level = m.Alpha*x+(1-m.Alpha)*(m.level+m.trend)
+    m.trend = m.Beta*(m.level-prevLevel)+(1-m.Beta)*m.trend
+
+    if m.SeasonLength > 0 {
+
+      m.buffer = append(m.buffer, x)
+
+      // Initialize the seasonal component as the deviation of the first season from its mean
+      if len(m.buffer) == m.SeasonLength {
+
+        var mean float64
+        for _, v := range(m.buffer){
+          mean += v
+        }
+        mean /= float64(m.SeasonLength)
+
+        m.seasonal = make([]float64, m.SeasonLength)
+        for i, v := range(m.buffer){
+          m.seasonal[i] = v-mean
+        }
+
+        m.level = mean
+        m.trend = 0
+        m.buffer = nil
+      }
+    }
+    return
+  }
+
+  i := m.seasonIndex(m.n)
+  s := m.seasonal[i]
+
+  prevLevel := m.level
+  m.level = m.Alpha*(x-s)+(1-m.Alpha)*(m.level+m.trend)
+  m.trend = m.Beta*(m.level-prevLevel)+(1-m.Beta)*m.trend
+  m.seasonal[i] = m.Gamma*(x-m.level)+(1-m.Gamma)*s
+}
+
+// Forecast the observation h steps ahead, forecasts are never negative
+func (m *HoltWinters) Forecast(h int) float64{
+
+  f := m.level+float64(h)*m.trend
+
+  if m.seasonal != nil {
+    f += m.seasonal[m.seasonIndex(m.n+h)]
+  }
+
+  return math.Max(0, f)
+}
+
+// Level returns the current level of the model
+func (m *HoltWinters) Level() float64{
+  return m.level
+}
+
+// Trend returns the current trend of the model per observation
+func (m *HoltWinters) Trend() float64{
+  return m.trend
+}
+
+// Observations returns the number of observations the model is updated with
+func (m *HoltWinters) Observations() int{
+  return m.n
+}
+
+// Seasonal returns true once the seasonal component is initialized
+func (m *HoltWinters) Seasonal() bool{
+  return m.seasonal != nil
+}
+
+// Index in the season of the n-th observation (1 based)
+func (m *HoltWinters) seasonIndex(n int) int{
+  return (n-1)%m.SeasonLength
+}
@@ -0,0 +1,119 @@
+// ForecastPlugin forecasts the rate of pods received by the coordinator in the next intervals with a
+// Holt-Winters model and scales the schedulers ahead of predictable bursts such as cron triggered
+// batch jobs.
+package forecast
+
+import(
+  "math"
+  "time"
+  "errors"
+  "github.com/alexnjh/epsilon/autoscaler/interfaces"
+)
+
+const (
+  // Smoothing factors of the Holt-Winters model
+  DefaultAlpha = 0.5
+  DefaultBeta = 0.1
+  DefaultGamma = 0.3
+
+  // Number of observations required before a non seasonal model is fully confident
+  MinimumObservations = 10
+)
+
+type ForecastPlugin struct{
+  Name string
+  QueueName string
+  // Fraction of the service rate of a replica that should be used by the forecasted arrivals (0 to 1)
+  TargetUtilisation float64
+  // Number of intervals ahead to forecast, should cover the time taken for a new replica to be ready
+  Horizon int
+  model *HoltWinters
+  lastUpdate time.Time
+  // Highest arrival rate forecasted within the horizon during the last compute
+  lastPeak float64
+}
+
+// Creates a new ForecastPlugin, the season length and horizon are in number of intervals
+func NewForecastPlugin(name,queueName string,targetUtilisation float64,seasonLength,horizon int) *ForecastPlugin{
+
+  if horizon < 1 {
+    horizon = 1
+  }
+
+  return &ForecastPlugin{
+    Name: name,
+    QueueName: queueName,
+    TargetUtilisation: targetUtilisation,
+    Horizon: horizon,
+    model: NewHoltWinters(DefaultAlpha, DefaultBeta, DefaultGamma, seasonLength),
+  }
+}
+
+// Compute processes the data and return a Recommendation
+func (plugin *ForecastPlugin) Compute(snapshot *interfaces.MetricsSnapshot) (interfaces.Recommendation, error){
+
+  result := interfaces.Recommendation{DesiredReplicas: snapshot.Replicas, Confidence: 0}
+
+  if snapshot.ServiceRate <= 0 {
+    return result, errors.New("Service rate of the schedulers is unknown")
+  }
+
+  if plugin.TargetUtilisation <= 0 || plugin.TargetUtilisation > 1 {
+    return result, errors.New("Target utilisation must be between 0 and 1")
+  }
+
+  // Samples recorded before the plugin is created are used to warm up the model
+  for _, s := range(snapshot.History){
+    if s.Timestamp.After(plugin.lastUpdate) {
+      plugin.model.Update(s.RequestRate)
+      plugin.lastUpdate = s.Timestamp
+    }
+  }
+
+  if snapshot.Timestamp.After(plugin.lastUpdate) {
+    plugin.model.Update(snapshot.RequestRate)
+    plugin.lastUpdate = snapshot.Timestamp
+  }
+
+  peak := snapshot.RequestRate
+  for h := 1; h <= plugin.Horizon; h++ {
+    peak = math.Max(peak, plugin.model.Forecast(h))
+  }
+
+  plugin.lastPeak = peak
+
+  result.DesiredReplicas = int32(math.Max(1, math.Ceil(peak/(snapshot.ServiceRate*plugin.TargetUtilisation))))
+  result.Confidence = plugin.confidence()
+
+  return result, nil
+}
+
+// Status returns the state of the Holt-Winters model
+func (plugin *ForecastPlugin) Status() map[string]interface{}{
+  return map[string]interface{}{
+    "observations": plugin.model.Observations(),
+    "seasonal": plugin.model.Seasonal(),
+    "level": plugin.model.Level(),
+    "trend": plugin.model.Trend(),
+    "forecastPeak": plugin.lastPeak,
+    "confidence": plugin.confidence(),
+  }
+}
+
+// The confidence grows as the model observes more data, a seasonal model is fully confident
+// after observing two seasons
+func (plugin *ForecastPlugin) confidence() float64{
+
+  n := float64(plugin.model.Observations())
+  l := float64(plugin.model.SeasonLength)
+
+  if l == 0 {
+    return math.Min(1, n/MinimumObservations)
+  }
+
+  if !plugin.model.Seasonal() {
+    return 0.25
+  }
+
+  return 0.5+0.5*math.Min(1, (n-l)/l)
+}
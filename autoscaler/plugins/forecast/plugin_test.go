@@ -0,0 +1,150 @@
+package forecast
+
+import (
+  "math"
+  "time"
+  "testing"
+  "github.com/alexnjh/epsilon/autoscaler/interfaces"
+)
+
+func TestHoltWintersTrend(t *testing.T) {
+
+  m := NewHoltWinters(0.5, 0.5, 0, 0)
+
+  for i := 0; i < 50; i++ {
+    m.Update(float64(10+2*i))
+  }
+
+  // The last observation is 108 so the next ones are 110 and 112
+  for h, want := range map[int]float64{1: 110, 2: 112} {
+    if got := m.Forecast(h); math.Abs(got-want) > 0.5 {
+      t.Errorf("Forecast(%d) = %v, want %v", h, got, want)
+    }
+  }
+}
+
+func TestHoltWintersSeasonality(t *testing.T) {
+
+  season := 24
+  m := NewHoltWinters(DefaultAlpha, DefaultBeta, DefaultGamma, season)
+
+  series := func(i int) float64 {
+    return 100+50*math.Sin(2*math.Pi*float64(i)/float64(season))
+  }
+
+  for i := 0; i < 5*season; i++ {
+    m.Update(series(i))
+  }
+
+  if !m.Seasonal() {
+    t.Fatalf("seasonal component is not initialized")
+  }
+
+  for h := 1; h <= season; h++ {
+    want := series(5*season-1+h)
+    if got := m.Forecast(h); math.Abs(got-want) > 5 {
+      t.Errorf("Forecast(%d) = %v, want %v", h, got, want)
+    }
+  }
+}
+
+func TestHoltWintersNeverNegative(t *testing.T) {
+
+  m := NewHoltWinters(0.9, 0.9, 0, 0)
+
+  for _, x := range []float64{100, 50, 10, 0} {
+    m.Update(x)
+  }
+
+  if got := m.Forecast(10); got != 0 {
+    t.Errorf("Forecast(10) = %v, want 0", got)
+  }
+}
+
+// A batch job arrives at the same time every season, the plugin should scale up before it arrives
+func TestComputePreScalesBeforeBurst(t *testing.T) {
+
+  season := 12
+  burstAt := 8
+  serviceRate := 100.0
+
+  plugin := NewForecastPlugin("forecast", "epsilon.distributed", 1, season, 2)
+  start := time.Now()
+
+  arrivals := func(i int) float64 {
+    if i%season == burstAt {
+      return 1000
+    }
+    return 50
+  }
+
+  compute := func(i int) interfaces.Recommendation {
+    rec, err := plugin.Compute(&interfaces.MetricsSnapshot{
+      Timestamp: start.Add(time.Duration(i)*time.Minute),
+      RequestRate: arrivals(i),
+      ServiceRate: serviceRate,
+      Replicas: 1,
+    })
+    if err != nil {
+      t.Fatal(err)
+    }
+    return rec
+  }
+
+  var rec interfaces.Recommendation
+
+  // Observe four seasons and stop right before the next burst
+  for i := 0; i < 4*season+burstAt-1; i++ {
+    rec = compute(i)
+  }
+
+  if rec.DesiredReplicas < 8 {
+    t.Errorf("DesiredReplicas before burst = %d, want at least 8", rec.DesiredReplicas)
+  }
+  if rec.Confidence != 1 {
+    t.Errorf("Confidence = %v, want 1", rec.Confidence)
+  }
+
+  // Once the burst leaves the horizon the plugin should recommend fewer replicas
+  for i := 4*season+burstAt-1; i < 4*season+burstAt+2; i++ {
+    rec = compute(i)
+  }
+
+  if rec.DesiredReplicas > 2 {
+    t.Errorf("DesiredReplicas after burst = %d, want at most 2", rec.DesiredReplicas)
+  }
+}
+
+func TestComputeWarmsUpFromHistory(t *testing.T) {
+
+  start := time.Now()
+
+  history := make([]interfaces.Sample, 0, MinimumObservations)
+  for i := 0; i < MinimumObservations-1; i++ {
+    history = append(history, interfaces.Sample{Timestamp: start.Add(time.Duration(i)*time.Minute), RequestRate: 300})
+  }
+
+  plugin := NewForecastPlugin("forecast", "epsilon.distributed", 0.5, 0, 1)
+
+  rec, err := plugin.Compute(&interfaces.MetricsSnapshot{
+    Timestamp: start.Add(time.Duration(MinimumObservations)*time.Minute),
+    RequestRate: 300,
+    ServiceRate: 100,
+    Replicas: 1,
+    History: history,
+  })
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  if rec.DesiredReplicas != 6 || rec.Confidence != 1 {
+    t.Errorf("Compute() = %+v, want 6 replicas with confidence 1", rec)
+  }
+}
+
+func TestComputeUnknownServiceRate(t *testing.T) {
+  plugin := NewForecastPlugin("forecast", "epsilon.distributed", 0.8, 0, 1)
+  if _, err := plugin.Compute(&interfaces.MetricsSnapshot{RequestRate: 100, Replicas: 1}); err == nil {
+    t.Errorf("expected error when the service rate is unknown")
+  }
+}
@@ -0,0 +1,68 @@
+// NodeChurnPlugin scales the schedulers ahead of nodes coming online. Pods that could not be
+// scheduled are retried as soon as new nodes (eg. from a cluster autoscaler) become schedulable,
+// flooding the queue at once. The plugin recommends enough replicas to schedule the released pods
+// together with the arriving pods within the drain time.
+package node_churn
+
+import(
+  "math"
+  "time"
+  "errors"
+  "github.com/alexnjh/epsilon/autoscaler/interfaces"
+)
+
+type NodeChurnPlugin struct{
+  Name string
+  QueueName string
+  // Time the schedulers should take to schedule the pods released by the new nodes
+  DrainTime time.Duration
+}
+
+// Creates a new NodeChurnPlugin
+func NewNodeChurnPlugin(name,queueName string,drainTime time.Duration) *NodeChurnPlugin{
+  return &NodeChurnPlugin{
+    Name: name,
+    QueueName: queueName,
+    DrainTime: drainTime,
+  }
+}
+
+// Compute processes the data and return a Recommendation.
+// The plugin has no confidence when no nodes are coming online so it does not affect the decision.
+func (plugin *NodeChurnPlugin) Compute(snapshot *interfaces.MetricsSnapshot) (interfaces.Recommendation, error){
+
+  result := interfaces.Recommendation{DesiredReplicas: snapshot.Replicas, Confidence: 0}
+
+  if snapshot.ServiceRate <= 0 {
+    return result, errors.New("Service rate of the schedulers is unknown")
+  }
+
+  if plugin.DrainTime <= 0 {
+    return result, errors.New("Drain time must be positive")
+  }
+
+  incoming := IncomingNodes(snapshot.NodeChurn)
+  if incoming == 0 {
+    return result, nil
+  }
+
+  // Rates are per minute so the drain time is converted to minutes as well
+  backlog := snapshot.UnschedulablePods+snapshot.QueueDepth
+  rate := backlog/plugin.DrainTime.Minutes()+snapshot.ArrivalRate
+  desired := int32(math.Max(1, math.Ceil(rate/snapshot.ServiceRate)))
+
+  // Scaling down while nodes are coming online is avoided
+  if desired > snapshot.Replicas {
+    result.DesiredReplicas = desired
+  }
+
+  // A node pool that is large compared to the schedulable nodes releases more pods
+  result.Confidence = math.Min(1, float64(incoming)/math.Max(1, snapshot.FeasibleNodes))
+
+  return result, nil
+}
+
+// IncomingNodes returns the number of nodes that are coming online or recently became schedulable
+func IncomingNodes(churn interfaces.NodeChurn) int{
+  return churn.JoiningNodes+churn.BecameReady+churn.Uncordoned
+}
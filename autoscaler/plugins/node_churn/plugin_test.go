@@ -0,0 +1,55 @@
+package node_churn
+
+import (
+  "time"
+  "testing"
+  "github.com/alexnjh/epsilon/autoscaler/interfaces"
+)
+
+func TestCompute(t *testing.T) {
+
+  plugin := NewNodeChurnPlugin("nodechurn", "epsilon.distributed", time.Minute)
+
+  tests := []struct{
+    name string
+    snapshot interfaces.MetricsSnapshot
+    want interfaces.Recommendation
+  }{
+    {
+      name: "no churn",
+      snapshot: interfaces.MetricsSnapshot{Replicas: 3, ServiceRate: 100, UnschedulablePods: 1000, FeasibleNodes: 10},
+      want: interfaces.Recommendation{DesiredReplicas: 3, Confidence: 0},
+    },
+    {
+      name: "large node pool releasing pods",
+      snapshot: interfaces.MetricsSnapshot{
+        Replicas: 1, ServiceRate: 100, ArrivalRate: 50, QueueDepth: 50, UnschedulablePods: 400, FeasibleNodes: 10,
+        NodeChurn: interfaces.NodeChurn{JoiningNodes: 20},
+      },
+      // (400+50)/1 + 50 = 500 pods per minute
+      want: interfaces.Recommendation{DesiredReplicas: 5, Confidence: 1},
+    },
+    {
+      name: "small node pool does not scale down",
+      snapshot: interfaces.MetricsSnapshot{
+        Replicas: 4, ServiceRate: 100, ArrivalRate: 10, FeasibleNodes: 10,
+        NodeChurn: interfaces.NodeChurn{BecameReady: 1, Uncordoned: 1},
+      },
+      want: interfaces.Recommendation{DesiredReplicas: 4, Confidence: 0.2},
+    },
+  }
+
+  for _, tt := range tests {
+    got, err := plugin.Compute(&tt.snapshot)
+    if err != nil {
+      t.Fatalf("%s: %v", tt.name, err)
+    }
+    if got != tt.want {
+      t.Errorf("%s: Compute() = %+v, want %+v", tt.name, got, tt.want)
+    }
+  }
+
+  if _, err := plugin.Compute(&interfaces.MetricsSnapshot{Replicas: 1}); err == nil {
+    t.Errorf("expected error for unknown service rate")
+  }
+}
@@ -1,79 +1,118 @@
-// QueueTheoryPlugin decides based on approximation of the waiting time for all the pods current in the cluster wiating to be scheduled.
+// QueueTheoryPlugin models the schedulers of a queue as an M/M/c queue and recommends the
+// minimum number of scheduler replicas that keeps the expected waiting time of a pod within
+// a latency SLO.
 package queue_theory
 
 import(
-  "strconv"
+  "math"
   "time"
-  "net/http"
-  "bufio"
-  "strings"
-  "fmt"
-  "os"
+  "errors"
   "github.com/alexnjh/epsilon/autoscaler/interfaces"
-  log "github.com/sirupsen/logrus"
+)
+
+const (
+  // Number of service rate samples required before the plugin is fully confident
+  MinimumSamples = 30
+  // Upper limit of the replicas the plugin will search through
+  MaximumReplicas = 1000
+  // Confidence of the recommendation when the SLO cannot be met within MaximumReplicas
+  UnmetSLOConfidence = 0.1
 )
 
 type QueueTheoryPlugin struct{
   Name string
-  Threshold float64
-  targetURL string
+  // Maximum expected waiting time of a pod in the queue
+  SLO time.Duration
 }
 
 // Creates a new QueueTheoryPlugin
-func NewQueueTheoryPlugin(name string,threshold float64,targetURL string) *QueueTheoryPlugin{
+func NewQueueTheoryPlugin(name string,slo time.Duration) *QueueTheoryPlugin{
   return &QueueTheoryPlugin{
     Name: name,
-    Threshold: threshold,
-    targetURL: targetURL,
+    SLO: slo,
   }
 }
 
-// Compute processes the data and return a ComputeResult
-func (plugin *QueueTheoryPlugin) Compute(_, _, noOfSched float64) interfaces.ComputeResult{
+// Compute processes the data and return a Recommendation
+func (plugin *QueueTheoryPlugin) Compute(snapshot *interfaces.MetricsSnapshot) (interfaces.Recommendation, error){
+
+  result := interfaces.Recommendation{DesiredReplicas: snapshot.Replicas, Confidence: 0}
 
-  metricMap := promToMap(plugin.targetURL)
+  if snapshot.ServiceRate <= 0 {
+    return result, errors.New("Service rate of the schedulers is unknown")
+  }
 
-  arrivalRate, err := strconv.ParseFloat(metricMap["pod_request_total_in_1min"], 64)
-  if err != nil {
-		log.Fatalf(err.Error())
-	}
+  // Rates are per minute so the SLO is converted to minutes as well
+  slo := plugin.SLO.Minutes()
 
-  serviceRate := noOfSched*(float64((1*time.Minute)/(25*time.Millisecond)))
+  replicas, ok := MinimumReplicas(snapshot.ArrivalRate, snapshot.ServiceRate, slo, MaximumReplicas)
 
-  avgWaitingTime := arrivalRate/(serviceRate*(serviceRate-arrivalRate))
+  result.DesiredReplicas = int32(replicas)
 
-  if (avgWaitingTime < plugin.Threshold){
-    return interfaces.DoNotScale
-  }else{
-    return interfaces.ScaleUp
+  // When the SLO cannot be met the largest number of replicas searched is recommended with a low
+  // confidence so that it does not outweigh the other plugins, and left to be bounded by the
+  // scaling policy
+  if !ok {
+    result.Confidence = UnmetSLOConfidence
+    return result, nil
   }
 
+  result.Confidence = 0.5 + 0.5*math.Min(1, float64(snapshot.ServiceRateSamples)/MinimumSamples)
+
+  return result, nil
 }
 
-// Convert prometheus formatted metrics into a map
-func promToMap(url string) map[string]string{
+// MinimumReplicas returns the smallest number of servers (at least 1) where the expected
+// waiting time is within the SLO. Returns false if no such number is found within the limit.
+func MinimumReplicas(arrivalRate, serviceRate, slo float64, limit int) (int, bool){
 
-  metricMap := make(map[string]string)
+  // The queue is only stable when the servers are able to serve faster than pods arrive
+  c := int(math.Floor(arrivalRate/serviceRate))+1
 
-  resp, err := http.Get(url)
-  if err != nil {
-    log.Fatalf(err.Error())
+  for ; c <= limit; c++ {
+    if ExpectedWait(arrivalRate, serviceRate, c) <= slo {
+      return c, true
+    }
   }
 
-  scanner := bufio.NewScanner(resp.Body)
-  for scanner.Scan() {
-    if len(scanner.Text()) > 0{
-      if scanner.Text()[0] != '#'{
-        s := strings.Split(scanner.Text(), " ")
-        if (len(s) == 2){
-          metricMap[s[0]]=s[1]
-        }
-      }
-    }
+  return limit, false
+}
+
+// ExpectedWait returns the expected time a pod waits in the queue before being served by one
+// of c servers, in the same time unit as the rates. The waiting time is infinite when the
+// arrival rate is equal to or larger than the total service rate of the servers.
+func ExpectedWait(arrivalRate, serviceRate float64, c int) float64{
+
+  if arrivalRate <= 0 {
+    return 0
   }
-  if err := scanner.Err(); err != nil {
-    fmt.Fprintln(os.Stderr, "reading standard input:", err)
+
+  capacity := float64(c)*serviceRate
+
+  if c <= 0 || arrivalRate >= capacity {
+    return math.Inf(1)
+  }
+
+  return ErlangC(c, arrivalRate/serviceRate)/(capacity-arrivalRate)
+}
+
+// ErlangC returns the probability that an arriving pod has to wait when there are c servers
+// and an offered load of a (arrival rate / service rate). Computed from the Erlang B recursion
+// to avoid the factorials overflowing for a large number of servers.
+func ErlangC(c int, a float64) float64{
+
+  if a <= 0 {
+    return 0
+  }
+
+  if float64(c) <= a {
+    return 1
+  }
+
+  b := 1.0
+  for k := 1; k <= c; k++ {
+    b = a*b/(float64(k)+a*b)
   }
 
-  return metricMap
+  return float64(c)*b/(float64(c)-a*(1-b))
 }
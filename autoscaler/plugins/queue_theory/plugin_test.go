@@ -0,0 +1,141 @@
+package queue_theory
+
+import (
+  "math"
+  "time"
+  "testing"
+  "github.com/alexnjh/epsilon/autoscaler/interfaces"
+)
+
+func TestErlangC(t *testing.T) {
+
+  tests := []struct{
+    name string
+    c int
+    a float64
+    want float64
+  }{
+    {"no load", 3, 0, 0},
+    {"single server", 1, 0.5, 0.5},
+    {"two servers", 2, 1, 1.0/3},
+    {"three servers", 3, 2, 4.0/9},
+    {"overloaded", 2, 2, 1},
+  }
+
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      if got := ErlangC(tt.c, tt.a); math.Abs(got-tt.want) > 1e-9 {
+        t.Errorf("ErlangC(%d, %v) = %v, want %v", tt.c, tt.a, got, tt.want)
+      }
+    })
+  }
+}
+
+func TestErlangCLargeNumberOfServers(t *testing.T) {
+  got := ErlangC(2000, 1900)
+  if math.IsNaN(got) || got <= 0 || got >= 1 {
+    t.Errorf("ErlangC(2000, 1900) = %v, want a probability", got)
+  }
+}
+
+func TestExpectedWait(t *testing.T) {
+
+  // M/M/1 waiting time is a/(mu-lambda)
+  if got, want := ExpectedWait(1, 2, 1), 0.5; math.Abs(got-want) > 1e-9 {
+    t.Errorf("ExpectedWait(1, 2, 1) = %v, want %v", got, want)
+  }
+
+  if got := ExpectedWait(4, 2, 2); !math.IsInf(got, 1) {
+    t.Errorf("ExpectedWait(4, 2, 2) = %v, want +Inf", got)
+  }
+
+  if got := ExpectedWait(0, 2, 1); got != 0 {
+    t.Errorf("ExpectedWait(0, 2, 1) = %v, want 0", got)
+  }
+}
+
+func TestCompute(t *testing.T) {
+
+  plugin := NewQueueTheoryPlugin("queuetheory", 100*time.Millisecond)
+
+  tests := []struct{
+    name string
+    snapshot interfaces.MetricsSnapshot
+    want int32
+    wantErr bool
+  }{
+    {
+      name: "no arrivals",
+      snapshot: interfaces.MetricsSnapshot{ArrivalRate: 0, ServiceRate: 2400, Replicas: 3},
+      want: 1,
+    },
+    {
+      name: "light load",
+      snapshot: interfaces.MetricsSnapshot{ArrivalRate: 600, ServiceRate: 2400, Replicas: 1},
+      want: 1,
+    },
+    {
+      name: "unstable with current replicas",
+      snapshot: interfaces.MetricsSnapshot{ArrivalRate: 10000, ServiceRate: 2400, Replicas: 2},
+      want: 5,
+    },
+    {
+      name: "unknown service rate",
+      snapshot: interfaces.MetricsSnapshot{ArrivalRate: 600, ServiceRate: 0, Replicas: 2},
+      want: 2,
+      wantErr: true,
+    },
+  }
+
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      got, err := plugin.Compute(&tt.snapshot)
+      if (err != nil) != tt.wantErr {
+        t.Fatalf("Compute() error = %v, wantErr %v", err, tt.wantErr)
+      }
+      if got.DesiredReplicas != tt.want {
+        t.Errorf("Compute() = %d replicas, want %d", got.DesiredReplicas, tt.want)
+      }
+      if ExpectedWait(tt.snapshot.ArrivalRate, tt.snapshot.ServiceRate, int(got.DesiredReplicas)) > plugin.SLO.Minutes() && !tt.wantErr {
+        t.Errorf("Compute() = %d replicas does not meet the SLO", got.DesiredReplicas)
+      }
+    })
+  }
+}
+
+func TestComputeSLOCannotBeMet(t *testing.T) {
+
+  plugin := NewQueueTheoryPlugin("queuetheory", 100*time.Millisecond)
+
+  // The queue is unstable even with the largest number of replicas searched
+  snapshot := interfaces.MetricsSnapshot{ArrivalRate: 5000, ServiceRate: 1, ServiceRateSamples: MinimumSamples, Replicas: 2}
+
+  got, err := plugin.Compute(&snapshot)
+  if err != nil {
+    t.Fatalf("Compute() error = %v", err)
+  }
+  if got.DesiredReplicas != MaximumReplicas {
+    t.Errorf("Compute() = %d replicas, want %d", got.DesiredReplicas, MaximumReplicas)
+  }
+  if got.Confidence != UnmetSLOConfidence {
+    t.Errorf("Compute() confidence = %v, want %v", got.Confidence, UnmetSLOConfidence)
+  }
+}
+
+func TestMinimumReplicasIsMinimal(t *testing.T) {
+
+  slo := 0.001
+
+  for _, arrival := range []float64{100, 2000, 5000, 12345, 50000} {
+    c, ok := MinimumReplicas(arrival, 2400, slo, MaximumReplicas)
+    if !ok {
+      t.Fatalf("MinimumReplicas(%v) did not find a solution", arrival)
+    }
+    if ExpectedWait(arrival, 2400, c) > slo {
+      t.Errorf("MinimumReplicas(%v) = %d does not meet the SLO", arrival, c)
+    }
+    if c > 1 && ExpectedWait(arrival, 2400, c-1) <= slo {
+      t.Errorf("MinimumReplicas(%v) = %d is not the minimum", arrival, c)
+    }
+  }
+}
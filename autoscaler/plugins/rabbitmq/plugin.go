@@ -3,42 +3,29 @@ package rabbitmq
 
 import(
   "github.com/alexnjh/epsilon/autoscaler/interfaces"
-  log "github.com/sirupsen/logrus"
-  rabbithole "github.com/michaelklishin/rabbit-hole/v2"
 )
 
 type RabbitMQPlugin struct{
   Name string
-  Vhost string
   QueueName string
   threshold float64
-  client *rabbithole.Client
 }
 
 // Creates a new RabbitMQPlugin
-func NewRabbitMQPlugin(name,vhost,queueName string,threshold float64,client *rabbithole.Client) *RabbitMQPlugin{
+func NewRabbitMQPlugin(name,queueName string,threshold float64) *RabbitMQPlugin{
   return &RabbitMQPlugin{
     Name: name,
-    Vhost: vhost,
     QueueName: queueName,
     threshold: threshold,
-    client: client,
   }
 }
 
-// Compute processes the data and return a ComputeResult
-func (plugin *RabbitMQPlugin) Compute(_,_,_ float64) interfaces.ComputeResult{
+// Compute processes the data and return a Recommendation
+func (plugin *RabbitMQPlugin) Compute(snapshot *interfaces.MetricsSnapshot) (interfaces.Recommendation, error){
 
-      qs, err := plugin.client.GetQueue(plugin.Vhost,plugin.QueueName)
-
-      if err != nil{
-        log.Fatalf(err.Error())
-      }
-
-      if qs.ConsumerUtilisation > plugin.threshold {
-        return interfaces.ScaleUp
-      }else{
-        return interfaces.DoNotScale
-      }
+  if snapshot.ConsumerUtilisation > plugin.threshold {
+    return interfaces.Recommendation{DesiredReplicas: snapshot.Replicas+1, Confidence: 1}, nil
+  }
 
+  return interfaces.Recommendation{DesiredReplicas: snapshot.Replicas, Confidence: 1}, nil
 }
@@ -1,14 +1,20 @@
 // SchedProbPlugin decides based on the scheduler conflict probability based on current cluster state.
+//
+// Each scheduler replica is assumed to pick a node uniformly at random from the set of nodes that
+// are feasible for a pod. A conflict happens when two or more replicas pick the same node at the
+// same time, which is the birthday problem with the feasible nodes as days and the replicas as people.
 package scheduler_prob
 
 import(
   "math"
+  "errors"
   "github.com/alexnjh/epsilon/autoscaler/interfaces"
 )
 
 type SchedProbPlugin struct{
   Name string
   QueueName string
+  // Maximum acceptable conflict probability (0 to 1)
   threshold float64
 }
 
@@ -21,30 +27,59 @@ func NewSchedProbPlugin(name,queueName string,threshold float64) *SchedProbPlugi
   }
 }
 
-// Compute processes the data and return a ComputeResult
-func (plugin *SchedProbPlugin) Compute(_, noOfNodes, noOfSched float64) interfaces.ComputeResult{
+// Compute processes the data and return a Recommendation
+func (plugin *SchedProbPlugin) Compute(snapshot *interfaces.MetricsSnapshot) (interfaces.Recommendation, error){
 
-  p := calProb(noOfNodes,noOfSched)
+  result := interfaces.Recommendation{DesiredReplicas: snapshot.Replicas, Confidence: 1}
 
-  if (p > plugin.threshold){
-    return interfaces.DoNotScale
-  }else{
-    return interfaces.ScaleDown
+  if snapshot.FeasibleNodes < 1 {
+    return result, errors.New("No feasible nodes in the cluster")
   }
 
+  if ConflictProbability(snapshot.FeasibleNodes, float64(snapshot.Replicas)) <= plugin.threshold {
+    return result, nil
+  }
+
+  // Reduce the replicas to the largest number with an acceptable conflict probability
+  result.DesiredReplicas = int32(MaximumSchedulers(snapshot.FeasibleNodes, plugin.threshold))
+
+  return result, nil
 }
 
-// Calculate the scheduler conflict probability
-// N = No of schedulers
-// K = No of nodes
-func calProb(N,K float64) float64{
-  return Factorial(N)/(Factorial(N-K)*math.Pow(N,K))
+// ConflictProbability returns the probability that at least two of K schedulers pick the same
+// node out of N feasible nodes. Computed as 1 - N!/((N-K)! * N^K) in log space using the log
+// gamma function so that it does not overflow for a large number of nodes.
+func ConflictProbability(N, K float64) float64{
+
+  if K <= 1 {
+    return 0
+  }
+
+  if K > N {
+    return 1
+  }
+
+  lgN, _ := math.Lgamma(N+1)
+  lgNK, _ := math.Lgamma(N-K+1)
+
+  return -math.Expm1(lgN-lgNK-K*math.Log(N))
 }
 
-func Factorial(n float64)(result float64) {
-	if (n > 0) {
-		result = n * Factorial(n-1)
-		return result
-	}
-	return 1
+// MaximumSchedulers returns the largest number of schedulers (at least 1) with a conflict
+// probability of at most the threshold when there are N feasible nodes
+func MaximumSchedulers(N, threshold float64) int{
+
+  // The conflict probability increases with the number of schedulers
+  lo, hi := 1, int(math.Max(1, math.Floor(N)))
+
+  for lo < hi {
+    mid := (lo+hi+1)/2
+    if ConflictProbability(N, float64(mid)) <= threshold {
+      lo = mid
+    }else{
+      hi = mid-1
+    }
+  }
+
+  return lo
 }
@@ -0,0 +1,158 @@
+package scheduler_prob
+
+import (
+  "math"
+  "testing"
+  "math/rand"
+  "github.com/alexnjh/epsilon/autoscaler/interfaces"
+)
+
+// Estimate the conflict probability by letting K schedulers pick one of N nodes at random
+func simulateConflict(r *rand.Rand, N, K, trials int) float64 {
+
+  conflicts := 0
+  picked := make(map[int]bool, K)
+
+  for t := 0; t < trials; t++ {
+    for k := range picked {
+      delete(picked, k)
+    }
+    for k := 0; k < K; k++ {
+      node := r.Intn(N)
+      if picked[node] {
+        conflicts++
+        break
+      }
+      picked[node] = true
+    }
+  }
+
+  return float64(conflicts)/float64(trials)
+}
+
+func TestConflictProbabilityMonteCarlo(t *testing.T) {
+
+  r := rand.New(rand.NewSource(1))
+  trials := 20000
+
+  tests := []struct{
+    N, K int
+  }{
+    {10, 2},
+    {10, 5},
+    {23, 23},
+    {365, 23},
+    {1000, 10},
+    {1000, 40},
+    {5000, 100},
+  }
+
+  for _, tt := range tests {
+    want := simulateConflict(r, tt.N, tt.K, trials)
+    got := ConflictProbability(float64(tt.N), float64(tt.K))
+
+    // Allow 4 standard errors of the simulation
+    tolerance := 4*math.Sqrt(want*(1-want)/float64(trials))+1e-3
+    if math.Abs(got-want) > tolerance {
+      t.Errorf("ConflictProbability(%d, %d) = %v, simulated %v", tt.N, tt.K, got, want)
+    }
+  }
+}
+
+func TestConflictProbabilityLargeClusters(t *testing.T) {
+
+  for _, N := range []float64{171, 1000, 100000} {
+    for _, K := range []float64{2, 10, 50} {
+      p := ConflictProbability(N, K)
+      if math.IsNaN(p) || math.IsInf(p, 0) || p <= 0 || p >= 1 {
+        t.Errorf("ConflictProbability(%v, %v) = %v, want a probability", N, K, p)
+      }
+    }
+  }
+
+  // Birthday problem
+  if p := ConflictProbability(365, 23); math.Abs(p-0.5073) > 1e-4 {
+    t.Errorf("ConflictProbability(365, 23) = %v, want 0.5073", p)
+  }
+}
+
+func TestConflictProbabilityEdgeCases(t *testing.T) {
+
+  tests := []struct{
+    N, K, want float64
+  }{
+    {10, 0, 0},
+    {10, 1, 0},
+    {1, 2, 1},
+    {5, 6, 1},
+  }
+
+  for _, tt := range tests {
+    if got := ConflictProbability(tt.N, tt.K); got != tt.want {
+      t.Errorf("ConflictProbability(%v, %v) = %v, want %v", tt.N, tt.K, got, tt.want)
+    }
+  }
+}
+
+func TestMaximumSchedulers(t *testing.T) {
+
+  for _, N := range []float64{1, 2, 10, 365, 1000} {
+    for _, threshold := range []float64{0.01, 0.1, 0.5, 0.9} {
+      k := MaximumSchedulers(N, threshold)
+      if k < 1 {
+        t.Fatalf("MaximumSchedulers(%v, %v) = %d", N, threshold, k)
+      }
+      if k > 1 && ConflictProbability(N, float64(k)) > threshold {
+        t.Errorf("MaximumSchedulers(%v, %v) = %d exceeds the threshold", N, threshold, k)
+      }
+      if float64(k) < N && ConflictProbability(N, float64(k+1)) <= threshold {
+        t.Errorf("MaximumSchedulers(%v, %v) = %d is not the maximum", N, threshold, k)
+      }
+    }
+  }
+
+  if k := MaximumSchedulers(365, 0.5); k != 22 {
+    t.Errorf("MaximumSchedulers(365, 0.5) = %d, want 22", k)
+  }
+}
+
+func TestCompute(t *testing.T) {
+
+  plugin := NewSchedProbPlugin("schedprob", "epsilon.distributed", 0.5)
+
+  tests := []struct{
+    name string
+    snapshot interfaces.MetricsSnapshot
+    want int32
+    wantErr bool
+  }{
+    {
+      name: "acceptable conflict probability",
+      snapshot: interfaces.MetricsSnapshot{Nodes: 500, FeasibleNodes: 365, Replicas: 22},
+      want: 22,
+    },
+    {
+      name: "too many schedulers for the feasible nodes",
+      snapshot: interfaces.MetricsSnapshot{Nodes: 500, FeasibleNodes: 365, Replicas: 30},
+      want: 22,
+    },
+    {
+      name: "no feasible nodes",
+      snapshot: interfaces.MetricsSnapshot{Nodes: 5, FeasibleNodes: 0, Replicas: 3},
+      want: 3,
+      wantErr: true,
+    },
+  }
+
+  for _, tt := range tests {
+    t.Run(tt.name, func(t *testing.T) {
+      got, err := plugin.Compute(&tt.snapshot)
+      if (err != nil) != tt.wantErr {
+        t.Fatalf("Compute() error = %v, wantErr %v", err, tt.wantErr)
+      }
+      if got.DesiredReplicas != tt.want {
+        t.Errorf("Compute() = %d replicas, want %d", got.DesiredReplicas, tt.want)
+      }
+    })
+  }
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+  "fmt"
+  "time"
+  "context"
+  "strings"
+  "k8s.io/client-go/kubernetes"
+  "k8s.io/client-go/util/retry"
+  corev1 "k8s.io/api/core/v1"
+  metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+  log "github.com/sirupsen/logrus"
+  "github.com/alexnjh/epsilon/autoscaler/interfaces"
+)
+
+// Scaler applies the consolidated decisions of the plugins to the scheduler workloads
+type Scaler struct{
+  // Client used to record events
+  client kubernetes.Interface
+  targets *TargetClient
+  policy ScalingPolicy
+  // Only record the decisions without changing the replicas
+  dryRun bool
+  // Optional log of all decisions
+  logger *DecisionLogger
+  states map[string]*ScaleState
+}
+
+// Creates a new Scaler
+func NewScaler(
+  client kubernetes.Interface,
+  targets *TargetClient,
+  policy ScalingPolicy,
+  dryRun bool,
+  logger *DecisionLogger) *Scaler{
+
+  return &Scaler{
+    client: client,
+    targets: targets,
+    policy: policy,
+    dryRun: dryRun,
+    logger: logger,
+    states: make(map[string]*ScaleState),
+  }
+}
+
+// Scale the scheduler workload of a queue towards the desired replicas and record the decision.
+// The workload is read again and the policy applied again if the replicas are changed by someone else.
+func (s *Scaler) Scale(target ScaleTarget, decision interfaces.ComputeResult, desired int32, votes []Vote) DecisionRecord{
+
+  state := s.states[target.Queue]
+  if state == nil {
+    state = &ScaleState{}
+    s.states[target.Queue] = state
+  }
+
+  record := DecisionRecord{
+    Timestamp: time.Now(),
+    Queue: target.Queue,
+    DesiredReplicas: desired,
+    Decision: decision,
+    DryRun: s.dryRun,
+  }
+
+  record = s.apply(target, record, votes, func(current int32) int32{
+    return s.policy.nextReplicas(state, current, desired, record.Timestamp)
+  })
+
+  if record.Applied {
+    state.markScaled(record.Replicas, record.TargetReplicas, record.Timestamp)
+  }
+
+  return record
+}
+
+// Scale the scheduler workload of an idle queue to zero replicas, bypassing the plugins and the scaling policy
+func (s *Scaler) ScaleToZero(target ScaleTarget, idle time.Duration) DecisionRecord{
+
+  record := DecisionRecord{
+    Timestamp: time.Now(),
+    Queue: target.Queue,
+    DesiredReplicas: 0,
+    Decision: interfaces.ScaleDown,
+    DryRun: s.dryRun,
+    Reason: fmt.Sprintf("Queue idle for %s", idle),
+  }
+
+  return s.apply(target, record, nil, func(current int32) int32{
+    return 0
+  })
+}
+
+// Scale the scheduler workload of a queue scaled to zero back to the minimum replicas as messages
+// are published to the queue. Workloads that are already running are left unchanged.
+func (s *Scaler) Wake(target ScaleTarget) DecisionRecord{
+
+  record := DecisionRecord{
+    Timestamp: time.Now(),
+    Queue: target.Queue,
+    DesiredReplicas: s.policy.MinReplicas,
+    Decision: interfaces.ScaleUp,
+    DryRun: s.dryRun,
+    Reason: "Message published to idle queue",
+  }
+
+  return s.apply(target, record, nil, func(current int32) int32{
+    if current == 0 {
+      return s.policy.MinReplicas
+    }
+    return current
+  })
+}
+
+// Change the replicas of the workload of a queue to the replicas returned by next and record the decision
+func (s *Scaler) apply(target ScaleTarget, record DecisionRecord, votes []Vote, next func(current int32) int32) DecisionRecord{
+
+  var workload Workload
+  found := false
+
+  err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+
+    var err error
+
+    workload, err = s.targets.Get(target)
+    if err != nil {
+      return err
+    }
+
+    found = true
+    record.Target = workload.Ref.Kind+"/"+workload.Ref.Name
+    record.Replicas = workload.Replicas
+    record.TargetReplicas = next(record.Replicas)
+
+    if record.TargetReplicas == record.Replicas || s.dryRun {
+      return nil
+    }
+
+    return s.targets.Update(target, workload, record.TargetReplicas)
+  })
+
+  record.Votes = newVoteRecords(votes, record.Replicas)
+
+  // Decisions made without the plugins are explained by their reason
+  detail := record.Reason
+  if len(detail) == 0 {
+    detail = formatVotes(record.Votes)
+  }
+
+  if err != nil {
+
+    log.Errorf("Unable to scale %s: %s", target.Queue, err.Error())
+    record.Error = err.Error()
+
+    if found {
+      addScaleEvent(s.client, workload.Ref, "FailedScale", corev1.EventTypeWarning,
+        fmt.Sprintf("Unable to scale scheduler replicas from %d to %d: %s; %s", record.Replicas, record.TargetReplicas, err.Error(), detail))
+    }
+
+  }else if record.TargetReplicas != record.Replicas {
+
+    if s.dryRun {
+      log.Infof("[Dry run] Would scale %s from %d to %d replicas", record.Target, record.Replicas, record.TargetReplicas)
+    }else{
+      log.Infof("Scaled %s from %d to %d replicas", record.Target, record.Replicas, record.TargetReplicas)
+
+      record.Applied = true
+
+      reason := "ScaleUp"
+      if record.TargetReplicas < record.Replicas {
+        reason = "ScaleDown"
+      }
+
+      addScaleEvent(s.client, workload.Ref, reason, corev1.EventTypeNormal,
+        fmt.Sprintf("Scheduler replicas changed from %d to %d; %s", record.Replicas, record.TargetReplicas, detail))
+    }
+  }
+
+  recordMetrics(record)
+
+  if s.logger != nil {
+    if err := s.logger.Log(record); err != nil {
+      log.Errorf("Unable to write decision log: %s", err.Error())
+    }
+  }
+
+  return record
+}
+
+// Summarize the votes of the plugins for an event message
+func formatVotes(votes []VoteRecord) string{
+
+  s := make([]string, len(votes))
+
+  for i, v := range(votes){
+    if len(v.Error) != 0 {
+      s[i] = fmt.Sprintf("%s=Error(%s)", v.Plugin, v.Error)
+    }else{
+      s[i] = fmt.Sprintf("%s=%s(replicas: %d, confidence: %.2f, weight: %v)", v.Plugin, v.Decision, v.DesiredReplicas, v.Confidence, v.Weight)
+    }
+  }
+
+  return "votes: "+strings.Join(s, ", ")
+}
+
+// Update kube-api server of the autoscaler's scaling operations
+func addScaleEvent(client kubernetes.Interface, obj corev1.ObjectReference, reason string, eventType string, message string){
+  _, err := client.CoreV1().Events(obj.Namespace).Create(context.TODO(), &corev1.Event{
+    Count:          1,
+    Message:        message,
+    Reason:         reason,
+    LastTimestamp:  metav1.Now(),
+    FirstTimestamp: metav1.Now(),
+    Type:           eventType,
+    Source: corev1.EventSource{
+      Component: "autoscaler",
+    },
+    InvolvedObject: obj,
+    ObjectMeta: metav1.ObjectMeta{
+      GenerateName: obj.Name + "-",
+    },
+  },metav1.CreateOptions{})
+
+  if err != nil {
+    log.Errorf("Unable to create %s event for %s: %s", reason, obj.Name, err.Error())
+  }
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+  "os"
+  "fmt"
+  "time"
+  "bufio"
+  "errors"
+  "context"
+  "testing"
+  "strings"
+  "io/ioutil"
+  "path/filepath"
+  "encoding/json"
+  "k8s.io/client-go/kubernetes/fake"
+  appsv1 "k8s.io/api/apps/v1"
+  corev1 "k8s.io/api/core/v1"
+  autoscalingv1 "k8s.io/api/autoscaling/v1"
+  "k8s.io/apimachinery/pkg/api/meta"
+  "k8s.io/apimachinery/pkg/runtime"
+  "k8s.io/apimachinery/pkg/runtime/schema"
+  "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+  apierrors "k8s.io/apimachinery/pkg/api/errors"
+  metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+  k8stesting "k8s.io/client-go/testing"
+  scalefake "k8s.io/client-go/scale/fake"
+  dynamicfake "k8s.io/client-go/dynamic/fake"
+  "github.com/alexnjh/epsilon/autoscaler/interfaces"
+)
+
+// Scale subresources of the test workloads stored by resource and name
+type testScales struct{
+  replicas map[string]int32
+  // Number of updates that fail with a conflict before succeeding
+  conflicts int
+}
+
+func newTestTargets(workloads ...*unstructured.Unstructured) (*TargetClient, *testScales) {
+
+  mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{appsv1.SchemeGroupVersion})
+  mapper.Add(appsv1.SchemeGroupVersion.WithKind("Deployment"), meta.RESTScopeNamespace)
+  mapper.Add(appsv1.SchemeGroupVersion.WithKind("StatefulSet"), meta.RESTScopeNamespace)
+
+  scales := &testScales{replicas: make(map[string]int32)}
+  objs := make([]runtime.Object, 0, len(workloads))
+
+  for _, w := range workloads {
+    replicas, _, _ := unstructured.NestedInt64(w.Object, "spec", "replicas")
+    gvr, _ := meta.UnsafeGuessKindToResource(w.GroupVersionKind())
+    scales.replicas[gvr.GroupResource().String()+"/"+w.GetName()] = int32(replicas)
+    objs = append(objs, w)
+  }
+
+  scaleClient := &scalefake.FakeScaleClient{}
+
+  scaleClient.AddReactor("get", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+    a := action.(k8stesting.GetAction)
+    key := a.GetResource().GroupResource().String()+"/"+a.GetName()
+    replicas, ok := scales.replicas[key]
+    if !ok {
+      return true, nil, apierrors.NewNotFound(a.GetResource().GroupResource(), a.GetName())
+    }
+    return true, &autoscalingv1.Scale{
+      ObjectMeta: metav1.ObjectMeta{Name: a.GetName(), Namespace: a.GetNamespace()},
+      Spec: autoscalingv1.ScaleSpec{Replicas: replicas},
+    }, nil
+  })
+
+  scaleClient.AddReactor("update", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+    a := action.(k8stesting.UpdateAction)
+    obj := a.GetObject().(*autoscalingv1.Scale)
+    if scales.conflicts > 0 {
+      scales.conflicts--
+      return true, nil, apierrors.NewConflict(a.GetResource().GroupResource(), obj.Name, errors.New("object has been modified"))
+    }
+    scales.replicas[a.GetResource().GroupResource().String()+"/"+obj.Name] = obj.Spec.Replicas
+    return true, obj, nil
+  })
+
+  dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), objs...)
+
+  return NewTargetClient(dynamicClient, scaleClient, mapper, "custom-scheduler"), scales
+}
+
+func newTestScaler(dryRun bool, logger *DecisionLogger, workloads ...*unstructured.Unstructured) (*Scaler, *fake.Clientset, *testScales) {
+
+  targets, scales := newTestTargets(workloads...)
+  client := fake.NewSimpleClientset()
+
+  // The fake clientset does not generate names
+  generated := 0
+  client.PrependReactor("create", "events", func(action k8stesting.Action) (bool, runtime.Object, error) {
+    event := action.(k8stesting.CreateAction).GetObject().(*corev1.Event)
+    generated++
+    event.Name = fmt.Sprintf("%s%d", event.GenerateName, generated)
+    return false, nil, nil
+  })
+
+  policy := ScalingPolicy{MinReplicas: 1, MaxReplicas: 10, StepSize: 1}
+
+  return NewScaler(client, targets, policy, dryRun, logger), client, scales
+}
+
+func schedulerWorkload(kind, name, queue string, replicas int64) *unstructured.Unstructured {
+  return &unstructured.Unstructured{Object: map[string]interface{}{
+    "apiVersion": "apps/v1",
+    "kind": kind,
+    "metadata": map[string]interface{}{
+      "name": name,
+      "namespace": "custom-scheduler",
+      "labels": map[string]interface{}{QueueLabel: queue},
+    },
+    "spec": map[string]interface{}{"replicas": replicas},
+  }}
+}
+
+func testTarget(t *testing.T, queue, resource string) ScaleTarget {
+  target, err := newScaleTarget(queue, resource, DefaultTargetSelector)
+  if err != nil {
+    t.Fatal(err)
+  }
+  return target
+}
+
+var testVotes = []Vote{
+  vote("rabbitmq", 3, 1, 1),
+  vote("queuetheory", 4, 0.5, 2),
+}
+
+func TestScale(t *testing.T) {
+
+  scaler, client, scales := newTestScaler(false, nil, schedulerWorkload("Deployment", "scheduler", "epsilon.distributed", 2))
+
+  record := scaler.Scale(testTarget(t, "epsilon.distributed", DefaultTargetResource), interfaces.ScaleUp, 4, testVotes)
+
+  if !record.Applied || record.Replicas != 2 || record.TargetReplicas != 3 || record.Target != "Deployment/scheduler" || len(record.Error) != 0 {
+    t.Fatalf("Scale() = %+v, want applied scale from 2 to 3", record)
+  }
+
+  if r := scales.replicas["deployments.apps/scheduler"]; r != 3 {
+    t.Errorf("replicas = %d, want 3", r)
+  }
+
+  events, _ := client.CoreV1().Events("custom-scheduler").List(context.TODO(), metav1.ListOptions{})
+  if len(events.Items) != 1 {
+    t.Fatalf("got %d events, want 1", len(events.Items))
+  }
+  if e := events.Items[0]; e.InvolvedObject.Kind != "Deployment" || e.Reason != "ScaleUp" || !strings.Contains(e.Message, "rabbitmq=") || !strings.Contains(e.Message, "queuetheory=") {
+    t.Errorf("event = %s: %s, want ScaleUp with the votes of every plugin", e.Reason, e.Message)
+  }
+}
+
+func TestScaleDryRun(t *testing.T) {
+
+  scaler, client, scales := newTestScaler(true, nil, schedulerWorkload("Deployment", "scheduler", "epsilon.distributed", 2))
+
+  record := scaler.Scale(testTarget(t, "epsilon.distributed", DefaultTargetResource), interfaces.ScaleUp, 4, testVotes)
+
+  if record.Applied || !record.DryRun || record.TargetReplicas != 3 {
+    t.Fatalf("Scale() = %+v, want unapplied dry run decision of 3 replicas", record)
+  }
+
+  if r := scales.replicas["deployments.apps/scheduler"]; r != 2 {
+    t.Errorf("replicas = %d, want 2", r)
+  }
+
+  events, _ := client.CoreV1().Events("custom-scheduler").List(context.TODO(), metav1.ListOptions{})
+  if len(events.Items) != 0 {
+    t.Errorf("got %d events in dry run, want 0", len(events.Items))
+  }
+}
+
+func TestScaleStatefulSet(t *testing.T) {
+
+  scaler, _, scales := newTestScaler(false, nil,
+    schedulerWorkload("Deployment", "scheduler", "epsilon.distributed", 2),
+    schedulerWorkload("StatefulSet", "scheduler", "epsilon.distributed", 4))
+
+  record := scaler.Scale(testTarget(t, "epsilon.distributed", "statefulsets.apps"), interfaces.ScaleDown, 1, testVotes)
+
+  if !record.Applied || record.Target != "StatefulSet/scheduler" || record.TargetReplicas != 3 {
+    t.Fatalf("Scale() = %+v, want applied scale of the statefulset from 4 to 3", record)
+  }
+
+  if r := scales.replicas["statefulsets.apps/scheduler"]; r != 3 {
+    t.Errorf("statefulset replicas = %d, want 3", r)
+  }
+  if r := scales.replicas["deployments.apps/scheduler"]; r != 2 {
+    t.Errorf("deployment replicas = %d, want 2", r)
+  }
+}
+
+func TestScaleConflict(t *testing.T) {
+
+  scaler, _, scales := newTestScaler(false, nil, schedulerWorkload("Deployment", "scheduler", "epsilon.distributed", 2))
+  scales.conflicts = 2
+
+  record := scaler.Scale(testTarget(t, "epsilon.distributed", DefaultTargetResource), interfaces.ScaleUp, 4, testVotes)
+
+  if !record.Applied || len(record.Error) != 0 {
+    t.Fatalf("Scale() = %+v, want applied after retrying the conflicts", record)
+  }
+  if r := scales.replicas["deployments.apps/scheduler"]; r != 3 {
+    t.Errorf("replicas = %d, want 3", r)
+  }
+
+  // Conflicts that do not resolve are reported as a failed scale instead of panicking
+  scales.conflicts = 100
+
+  record = scaler.Scale(testTarget(t, "epsilon.distributed", DefaultTargetResource), interfaces.ScaleUp, 4, testVotes)
+
+  if record.Applied || !strings.Contains(record.Error, "object has been modified") {
+    t.Errorf("Scale() = %+v, want conflict error", record)
+  }
+}
+
+func TestScaleMissingWorkload(t *testing.T) {
+
+  scaler, _, _ := newTestScaler(false, nil, schedulerWorkload("Deployment", "scheduler", "epsilon.distributed", 2))
+
+  record := scaler.Scale(testTarget(t, "epsilon.other", DefaultTargetResource), interfaces.ScaleUp, 4, testVotes)
+
+  if record.Applied || len(record.Error) == 0 {
+    t.Errorf("Scale() = %+v, want error", record)
+  }
+}
+
+func TestScaleMultipleWorkloads(t *testing.T) {
+
+  scaler, _, _ := newTestScaler(false, nil,
+    schedulerWorkload("Deployment", "scheduler", "epsilon.distributed", 2),
+    schedulerWorkload("Deployment", "scheduler-2", "epsilon.distributed", 2))
+
+  record := scaler.Scale(testTarget(t, "epsilon.distributed", DefaultTargetResource), interfaces.ScaleUp, 4, testVotes)
+
+  if record.Applied || !strings.Contains(record.Error, "More than one") {
+    t.Errorf("Scale() = %+v, want error", record)
+  }
+}
+
+func TestScaleToZeroAndWake(t *testing.T) {
+
+  scaler, client, scales := newTestScaler(false, nil, schedulerWorkload("Deployment", "scheduler", "epsilon.shortjob", 3))
+  target := testTarget(t, "epsilon.shortjob", DefaultTargetResource)
+
+  record := scaler.ScaleToZero(target, time.Hour)
+
+  if !record.Applied || record.TargetReplicas != 0 || len(record.Reason) == 0 {
+    t.Fatalf("ScaleToZero() = %+v, want applied scale from 3 to 0", record)
+  }
+  if r := scales.replicas["deployments.apps/scheduler"]; r != 0 {
+    t.Errorf("replicas = %d, want 0", r)
+  }
+
+  record = scaler.Wake(target)
+
+  if !record.Applied || record.Replicas != 0 || record.TargetReplicas != 1 {
+    t.Fatalf("Wake() = %+v, want applied scale from 0 to 1", record)
+  }
+  if r := scales.replicas["deployments.apps/scheduler"]; r != 1 {
+    t.Errorf("replicas = %d, want 1", r)
+  }
+
+  // A workload that is already running is not changed
+  scales.replicas["deployments.apps/scheduler"] = 4
+
+  if record = scaler.Wake(target); record.Applied || record.TargetReplicas != 4 {
+    t.Errorf("Wake() = %+v, want no change", record)
+  }
+
+  events, _ := client.CoreV1().Events("custom-scheduler").List(context.TODO(), metav1.ListOptions{})
+  if len(events.Items) != 2 {
+    t.Fatalf("got %d events, want 2", len(events.Items))
+  }
+  for _, e := range events.Items {
+    if strings.Contains(e.Message, "votes:") {
+      t.Errorf("event message %q, want the reason instead of the votes", e.Message)
+    }
+  }
+}
+
+func TestDecisionLog(t *testing.T) {
+
+  dir, err := ioutil.TempDir("", "autoscaler")
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer os.RemoveAll(dir)
+
+  path := filepath.Join(dir, "decisions.jsonl")
+  logger, err := NewDecisionLogger(path)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  scaler, _, _ := newTestScaler(true, logger, schedulerWorkload("Deployment", "scheduler", "epsilon.distributed", 2))
+  target := testTarget(t, "epsilon.distributed", DefaultTargetResource)
+  scaler.Scale(target, interfaces.ScaleUp, 4, testVotes)
+  scaler.Scale(target, interfaces.DoNotScale, 2, testVotes)
+  logger.Close()
+
+  file, err := os.Open(path)
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer file.Close()
+
+  var records []DecisionRecord
+  scanner := bufio.NewScanner(file)
+  for scanner.Scan() {
+    var r DecisionRecord
+    if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+      t.Fatalf("invalid line %q: %v", scanner.Text(), err)
+    }
+    records = append(records, r)
+  }
+
+  if len(records) != 2 {
+    t.Fatalf("got %d records, want 2", len(records))
+  }
+  if r := records[0]; r.Queue != "epsilon.distributed" || r.Decision != interfaces.ScaleUp || len(r.Votes) != 2 || r.Votes[1].Decision != interfaces.ScaleUp {
+    t.Errorf("record = %+v", r)
+  }
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+  "sync"
+  "time"
+  "net/http"
+  "encoding/json"
+  log "github.com/sirupsen/logrus"
+  "github.com/alexnjh/epsilon/autoscaler/interfaces"
+)
+
+// PluginStatus is the state of a plugin of a queue after the last decision
+type PluginStatus struct{
+  Name string `json:"name"`
+  Threshold float64 `json:"threshold"`
+  Weight float64 `json:"weight"`
+  LastVote *VoteRecord `json:"lastVote,omitempty"`
+  // Internal state of plugins implementing interfaces.StatusReporter
+  State map[string]interface{} `json:"state,omitempty"`
+}
+
+// QueueStatus is the state of a queue after the last decision
+type QueueStatus struct{
+  Queue string `json:"queue"`
+  Target string `json:"target"`
+  Selector string `json:"selector"`
+  UpdatedAt time.Time `json:"updatedAt"`
+  PendingPods float64 `json:"pendingPods"`
+  Consumers float64 `json:"consumers"`
+  ArrivalRate float64 `json:"arrivalRate"`
+  ServiceRate float64 `json:"serviceRate"`
+  FeasibleNodes float64 `json:"feasibleNodes"`
+  UnschedulablePods float64 `json:"unschedulablePods"`
+  NodeChurn interfaces.NodeChurn `json:"nodeChurn"`
+  Replicas int32 `json:"replicas"`
+  ScaleToZero bool `json:"scaleToZero"`
+  // Seconds since messages were last seen in the queue
+  IdleSeconds float64 `json:"idleSeconds"`
+  ScaledToZero bool `json:"scaledToZero"`
+  LastDecision *DecisionRecord `json:"lastDecision,omitempty"`
+  Plugins []PluginStatus `json:"plugins"`
+}
+
+// PolicyStatus is the scaling policy with the durations in seconds, like the configuration
+type PolicyStatus struct{
+  MinReplicas int32 `json:"minReplicas"`
+  MaxReplicas int32 `json:"maxReplicas"`
+  StepSize int32 `json:"stepSize"`
+  ScaleUpCooldownSeconds float64 `json:"scaleUpCooldownSeconds"`
+  ScaleDownCooldownSeconds float64 `json:"scaleDownCooldownSeconds"`
+  StabilizationWindowSeconds float64 `json:"stabilizationWindowSeconds"`
+}
+
+// Status is the response of the status endpoint
+type Status struct{
+  StartedAt time.Time `json:"startedAt"`
+  DryRun bool `json:"dryRun"`
+  Policy PolicyStatus `json:"policy"`
+  Queues []QueueStatus `json:"queues"`
+}
+
+// StatusTracker keeps the latest state of every queue for the status endpoint
+type StatusTracker struct{
+  mu sync.RWMutex
+  status Status
+  // Index of each queue in status.Queues
+  index map[string]int
+}
+
+// Creates a new StatusTracker listing the queues in the order of the targets
+func NewStatusTracker(targets []ScaleTarget, policy ScalingPolicy, dryRun bool) *StatusTracker{
+
+  t := &StatusTracker{
+    status: Status{
+      StartedAt: time.Now(),
+      DryRun: dryRun,
+      Policy: PolicyStatus{
+        MinReplicas: policy.MinReplicas,
+        MaxReplicas: policy.MaxReplicas,
+        StepSize: policy.StepSize,
+        ScaleUpCooldownSeconds: policy.ScaleUpCooldown.Seconds(),
+        ScaleDownCooldownSeconds: policy.ScaleDownCooldown.Seconds(),
+        StabilizationWindowSeconds: policy.StabilizationWindow.Seconds(),
+      },
+      Queues: make([]QueueStatus, 0, len(targets)),
+    },
+    index: make(map[string]int),
+  }
+
+  for i, target := range(targets){
+    t.index[target.Queue] = i
+    t.status.Queues = append(t.status.Queues, QueueStatus{
+      Queue: target.Queue,
+      Target: target.Resource.String(),
+      Selector: target.Selector.String(),
+      ScaleToZero: target.ScaleToZero,
+      Plugins: []PluginStatus{},
+    })
+  }
+
+  return t
+}
+
+// Update the state of a queue with the snapshot given to its plugins and the resulting decision.
+// Must be called from the goroutine computing the plugins as their state is read.
+func (t *StatusTracker) Update(snapshot *interfaces.MetricsSnapshot, plugins []configuredPlugin, record DecisionRecord){
+
+  pluginStatus := make([]PluginStatus, len(plugins))
+
+  for i, p := range(plugins){
+    pluginStatus[i] = PluginStatus{
+      Name: p.Name,
+      Threshold: p.Threshold,
+      Weight: p.Weight,
+    }
+    if i < len(record.Votes) {
+      vote := record.Votes[i]
+      pluginStatus[i].LastVote = &vote
+    }
+    if reporter, ok := p.AutoScalerPlugin.(interfaces.StatusReporter); ok {
+      pluginStatus[i].State = reporter.Status()
+    }
+  }
+
+  t.mu.Lock()
+  defer t.mu.Unlock()
+
+  i, ok := t.index[snapshot.QueueName]
+  if !ok {
+    return
+  }
+
+  q := &t.status.Queues[i]
+  q.UpdatedAt = snapshot.Timestamp
+  q.PendingPods = snapshot.QueueDepth
+  q.Consumers = snapshot.Consumers
+  q.ArrivalRate = snapshot.ArrivalRate
+  q.ServiceRate = snapshot.ServiceRate
+  q.FeasibleNodes = snapshot.FeasibleNodes
+  q.UnschedulablePods = snapshot.UnschedulablePods
+  q.NodeChurn = snapshot.NodeChurn
+  q.Replicas = snapshot.Replicas
+  q.LastDecision = &record
+  q.Plugins = pluginStatus
+}
+
+// Update the last decision of a queue made without the plugins
+func (t *StatusTracker) UpdateDecision(record DecisionRecord){
+
+  t.mu.Lock()
+  defer t.mu.Unlock()
+
+  if i, ok := t.index[record.Queue]; ok {
+    t.status.Queues[i].LastDecision = &record
+  }
+}
+
+// Update how long a queue has been idle and whether its scheduler is scaled to zero
+func (t *StatusTracker) UpdateIdle(queue string, idle time.Duration, scaledToZero bool){
+
+  t.mu.Lock()
+  defer t.mu.Unlock()
+
+  if i, ok := t.index[queue]; ok {
+    t.status.Queues[i].IdleSeconds = idle.Seconds()
+    t.status.Queues[i].ScaledToZero = scaledToZero
+  }
+}
+
+// Get a copy of the current status
+func (t *StatusTracker) Status() Status{
+
+  t.mu.RLock()
+  defer t.mu.RUnlock()
+
+  status := t.status
+  status.Queues = append([]QueueStatus(nil), t.status.Queues...)
+
+  return status
+}
+
+// Serve the current status as JSON
+func (t *StatusTracker) ServeHTTP(w http.ResponseWriter, r *http.Request){
+
+  if r.Method != http.MethodGet {
+    http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+    return
+  }
+
+  w.Header().Set("Content-Type", "application/json")
+
+  if err := json.NewEncoder(w).Encode(t.Status()); err != nil {
+    log.Errorf("Unable to write status: %s", err.Error())
+  }
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+  "time"
+  "strings"
+  "testing"
+  "net/http"
+  "encoding/json"
+  "net/http/httptest"
+  "github.com/alexnjh/epsilon/autoscaler/interfaces"
+)
+
+type statusPlugin struct{}
+
+func (p statusPlugin) Compute(snapshot *interfaces.MetricsSnapshot) (interfaces.Recommendation, error) {
+  return interfaces.Recommendation{DesiredReplicas: snapshot.Replicas, Confidence: 1}, nil
+}
+
+func (p statusPlugin) Status() map[string]interface{} {
+  return map[string]interface{}{"level": 1.5}
+}
+
+type plainPlugin struct{}
+
+func (p plainPlugin) Compute(snapshot *interfaces.MetricsSnapshot) (interfaces.Recommendation, error) {
+  return interfaces.Recommendation{DesiredReplicas: snapshot.Replicas+1, Confidence: 1}, nil
+}
+
+func TestStatus(t *testing.T) {
+
+  targets := []ScaleTarget{
+    testTarget(t, "epsilon.distributed", DefaultTargetResource),
+    testTarget(t, "epsilon.short", "statefulsets.apps"),
+  }
+
+  tracker := NewStatusTracker(targets, ScalingPolicy{MinReplicas: 1, MaxReplicas: 10, StepSize: 1, ScaleDownCooldown: 5*time.Minute}, true)
+
+  plugins := []configuredPlugin{
+    {PluginConfig{Name: "forecast", Threshold: 0.8, Weight: 1}, statusPlugin{}},
+    {PluginConfig{Name: "rabbitmq", Threshold: 0.5, Weight: 2}, plainPlugin{}},
+  }
+
+  snapshot := &interfaces.MetricsSnapshot{
+    Timestamp: time.Now(),
+    QueueName: "epsilon.short",
+    QueueDepth: 12,
+    Consumers: 2,
+    Replicas: 2,
+  }
+
+  record := DecisionRecord{
+    Queue: "epsilon.short",
+    Replicas: 2,
+    TargetReplicas: 3,
+    Decision: interfaces.ScaleUp,
+    Votes: newVoteRecords([]Vote{vote("forecast", 2, 1, 1), vote("rabbitmq", 3, 1, 2)}, 2),
+  }
+
+  tracker.Update(snapshot, plugins, record)
+  tracker.UpdateIdle("epsilon.distributed", 90*time.Second, false)
+
+  w := httptest.NewRecorder()
+  tracker.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/status", nil))
+
+  if w.Code != http.StatusOK {
+    t.Fatalf("status code = %d, want 200", w.Code)
+  }
+
+  var status Status
+  if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+    t.Fatal(err)
+  }
+
+  if !status.DryRun || len(status.Queues) != 2 {
+    t.Fatalf("status = %+v, want dry run with 2 queues", status)
+  }
+
+  // The durations are in seconds like the configuration
+  if !strings.Contains(w.Body.String(), `"idleSeconds":90`) || status.Policy.ScaleDownCooldownSeconds != 300 {
+    t.Errorf("status = %s, want the durations in seconds", w.Body.String())
+  }
+
+  if q := status.Queues[0]; q.Queue != "epsilon.distributed" || q.LastDecision != nil || q.IdleSeconds != 90 {
+    t.Errorf("queues[0] = %+v, want no decision yet", q)
+  }
+
+  q := status.Queues[1]
+  if q.Target != "statefulsets.apps" || q.PendingPods != 12 || q.Consumers != 2 || q.LastDecision == nil || q.LastDecision.TargetReplicas != 3 {
+    t.Fatalf("queues[1] = %+v", q)
+  }
+  if len(q.Plugins) != 2 {
+    t.Fatalf("got %d plugins, want 2", len(q.Plugins))
+  }
+  if p := q.Plugins[0]; p.Name != "forecast" || p.State["level"] != 1.5 || p.LastVote == nil || p.LastVote.Decision != interfaces.DoNotScale {
+    t.Errorf("plugins[0] = %+v", p)
+  }
+  if p := q.Plugins[1]; p.State != nil || p.Weight != 2 || p.LastVote == nil || p.LastVote.Decision != interfaces.ScaleUp {
+    t.Errorf("plugins[1] = %+v", p)
+  }
+
+  w = httptest.NewRecorder()
+  tracker.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/status", nil))
+  if w.Code != http.StatusMethodNotAllowed {
+    t.Errorf("status code = %d, want 405", w.Code)
+  }
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+  "fmt"
+  "time"
+  "context"
+  "strings"
+  "k8s.io/client-go/scale"
+  "k8s.io/client-go/dynamic"
+  "k8s.io/apimachinery/pkg/api/meta"
+  apierrors "k8s.io/apimachinery/pkg/api/errors"
+  "k8s.io/apimachinery/pkg/labels"
+  "k8s.io/apimachinery/pkg/runtime/schema"
+  corev1 "k8s.io/api/core/v1"
+  metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+  // Default resource of the scheduler workloads
+  DefaultTargetResource = "deployments.apps"
+
+  // Default label selector of the scheduler workload of a queue, {queue} is replaced by the queue name
+  DefaultTargetSelector = QueueLabel+"={queue}"
+
+  // Default time without messages before a queue that opted in is scaled to zero
+  DefaultIdleWindow = time.Hour
+)
+
+// ScaleTarget identifies the scheduler workload consuming from a queue
+type ScaleTarget struct{
+  // Name of the queue
+  Queue string
+  // Resource of the workload, must have a scale subresource (eg. deployments.apps, statefulsets.apps)
+  Resource schema.GroupResource
+  // Selects the workload of the queue, exactly one workload must match
+  Selector labels.Selector
+  // Scale the workload to zero replicas when the queue is idle
+  ScaleToZero bool
+  // Time without messages before the queue is idle
+  IdleWindow time.Duration
+}
+
+// Workload is the scheduler workload of a queue found by the TargetClient
+type Workload struct{
+  // Reference to the workload used when recording events
+  Ref corev1.ObjectReference
+  // Desired replicas in the scale subresource of the workload
+  Replicas int32
+}
+
+// TargetClient finds the workloads of the queues and changes their replicas through the scale subresource,
+// only the replicas are written so other fields of the workload can be changed by others at the same time
+type TargetClient struct{
+  dynamic dynamic.Interface
+  scales scale.ScalesGetter
+  mapper meta.RESTMapper
+  namespace string
+}
+
+// Creates a new TargetClient, an empty namespace searches all namespaces
+func NewTargetClient(
+  dynamicClient dynamic.Interface,
+  scales scale.ScalesGetter,
+  mapper meta.RESTMapper,
+  namespace string) *TargetClient{
+
+  return &TargetClient{
+    dynamic: dynamicClient,
+    scales: scales,
+    mapper: mapper,
+    namespace: namespace,
+  }
+}
+
+// Get the workload of a queue and its current replicas
+func (c *TargetClient) Get(target ScaleTarget) (Workload, error){
+
+  var workload Workload
+
+  gvr, err := c.mapper.ResourceFor(target.Resource.WithVersion(""))
+  if err != nil {
+    return workload, err
+  }
+
+  list, err := c.dynamic.Resource(gvr).Namespace(c.namespace).List(context.TODO(), metav1.ListOptions{
+    LabelSelector: target.Selector.String(),
+  })
+  if err != nil {
+    return workload, err
+  }
+
+  if len(list.Items) > 1 {
+    return workload, fmt.Errorf("More than one %s found for queue %s", target.Resource, target.Queue)
+  }
+
+  if len(list.Items) == 0 {
+    return workload, fmt.Errorf("No %s found for queue %s", target.Resource, target.Queue)
+  }
+
+  obj := list.Items[0]
+
+  workload.Ref = corev1.ObjectReference{
+    APIVersion: obj.GetAPIVersion(),
+    Kind:       obj.GetKind(),
+    Name:       obj.GetName(),
+    Namespace:  obj.GetNamespace(),
+    UID:        obj.GetUID(),
+  }
+
+  s, err := c.scales.Scales(obj.GetNamespace()).Get(context.TODO(), target.Resource, obj.GetName(), metav1.GetOptions{})
+  if err != nil {
+    return workload, err
+  }
+
+  workload.Replicas = s.Spec.Replicas
+
+  return workload, nil
+}
+
+// Update the replicas of a workload. A conflict error is returned if the replicas are changed by
+// someone else after the workload is read so the decision can be made again.
+func (c *TargetClient) Update(target ScaleTarget, workload Workload, replicas int32) error{
+
+  s, err := c.scales.Scales(workload.Ref.Namespace).Get(context.TODO(), target.Resource, workload.Ref.Name, metav1.GetOptions{})
+  if err != nil {
+    return err
+  }
+
+  if s.Spec.Replicas != workload.Replicas {
+    return apierrors.NewConflict(target.Resource, workload.Ref.Name,
+      fmt.Errorf("replicas changed from %d to %d", workload.Replicas, s.Spec.Replicas))
+  }
+
+  s.Spec.Replicas = replicas
+  _, err = c.scales.Scales(workload.Ref.Namespace).Update(context.TODO(), target.Resource, s, metav1.UpdateOptions{})
+  return err
+}
+
+// Parse the target of a queue, {queue} in the selector is replaced by the queue name
+func newScaleTarget(queue string, resource string, selector string) (ScaleTarget, error){
+
+  target := ScaleTarget{
+    Queue: queue,
+    IdleWindow: DefaultIdleWindow,
+    Resource: schema.ParseGroupResource(strings.TrimSpace(resource)),
+  }
+
+  if len(target.Resource.Resource) == 0 {
+    return target, fmt.Errorf("Invalid target resource for queue %s: %q", queue, resource)
+  }
+
+  sel, err := labels.Parse(strings.ReplaceAll(selector, "{queue}", queue))
+  if err != nil {
+    return target, fmt.Errorf("Invalid target selector for queue %s: %v", queue, err)
+  }
+
+  if sel.Empty() {
+    return target, fmt.Errorf("Target selector of queue %s must not be empty", queue)
+  }
+
+  target.Selector = sel
+
+  return target, nil
+}
@@ -19,6 +19,7 @@ limitations under the License.
 package communication
 
 import (
+ "context"
  "errors"
  "github.com/streadway/amqp"
 )
@@ -67,6 +68,14 @@ func (c *CommunicationClient) QueueDeclare(queue string) error{
 
 // Send messages to a specific queue
 func (c *CommunicationClient) Send(message []byte, queue string) error{
+ return c.SendWithContext(context.Background(), message, queue)
+}
+
+// Send messages to a specific queue with the trace context of ctx in the message headers
+func (c *CommunicationClient) SendWithContext(ctx context.Context, message []byte, queue string) error{
+
+ headers := amqp.Table{}
+ InjectContext(ctx, headers)
 
  err := c.ch.Publish(
   "",     // exchange
@@ -75,6 +84,7 @@ func (c *CommunicationClient) Send(message []byte, queue string) error{
   false,  // immediate
   amqp.Publishing {
     ContentType: "text/json",
+    Headers:     headers,
     Body:        message,
   })
 
@@ -87,6 +97,25 @@ func (c *CommunicationClient) Send(message []byte, queue string) error{
  return nil
 }
 
+// Limit the number of unacknowledged messages delivered to the consumers of the connection.
+// Must be called again after reconnecting as the limit is set on the channel.
+func (c *CommunicationClient) Qos(prefetchCount int) error{
+
+ err := c.ch.Qos(
+   prefetchCount, // prefetch count
+   0,             // prefetch size
+   false,         // global
+ )
+
+ if err != nil{
+   return errors.New(
+     `Failed to set prefetch count maybe connection is down?
+     Consider running Connect() again to reconnect to queue service`)
+ }
+
+ return nil
+}
+
 // Receive messages from a specific queue
 func (c *CommunicationClient) Receive(queue string) (<-chan amqp.Delivery, error){
 
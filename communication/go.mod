@@ -1,3 +1,11 @@
 module github.com/alexnjh/epsilon/communication
 
 go 1.14
+
+require (
+	github.com/streadway/amqp v1.0.0
+	go.opentelemetry.io/otel v1.2.0
+	go.opentelemetry.io/otel/sdk v1.2.0
+	go.opentelemetry.io/otel/trace v1.2.0
+	k8s.io/api v0.18.6
+)
@@ -18,6 +18,8 @@ limitations under the License.
 package communication
 
 import (
+ "context"
+
  "github.com/streadway/amqp"
 )
 
@@ -30,6 +32,9 @@ type Communication interface {
  // Send data to a specific queue
  Send(message []byte, queue string) error
 
+ // Send data to a specific queue with the trace context of ctx
+ SendWithContext(ctx context.Context, message []byte, queue string) error
+
  // Receive data from a specific queue
  Receive(queue string) (<-chan amqp.Delivery, error)
 
@@ -0,0 +1,240 @@
+/*
+
+Copyright (C) 2020 Alex Neo
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package communication
+
+import (
+ "context"
+ "errors"
+ "sync"
+
+ "github.com/streadway/amqp"
+)
+
+/*
+MemoryBroker is an in-memory message broker standing in for the queue microservice in tests
+and benchmarks. Like RabbitMQ the queues are unbounded, the consumers of a queue compete for
+its messages and the messages sent to a queue that is not declared are dropped.
+*/
+type MemoryBroker struct{
+ mu sync.Mutex
+ queues map[string]*memoryQueue
+ // Tag of the last delivery
+ tag uint64
+ closed bool
+}
+
+// A queue of the in-memory broker
+type memoryQueue struct{
+ name string
+ messages []amqp.Delivery
+ // Signalled when a message is added or the broker is closed
+ cond *sync.Cond
+ // Messages delivered to the consumers and not acknowledged yet, by delivery tag
+ unacked map[uint64]amqp.Delivery
+}
+
+// Create an in-memory message broker
+func NewMemoryBroker() *MemoryBroker{
+ return &MemoryBroker{queues: make(map[string]*memoryQueue)}
+}
+
+// Create a new queue if it does not exist
+func (b *MemoryBroker) QueueDeclare(queue string) error{
+
+ b.mu.Lock()
+ defer b.mu.Unlock()
+
+ if b.closed {
+   return errors.New("Failed to declare queue, the broker is closed")
+ }
+
+ if _, ok := b.queues[queue]; !ok {
+   b.queues[queue] = &memoryQueue{name: queue, cond: sync.NewCond(&b.mu), unacked: make(map[uint64]amqp.Delivery)}
+ }
+
+ return nil
+}
+
+// Number of messages waiting in the queue
+func (b *MemoryBroker) Len(queue string) int{
+
+ b.mu.Lock()
+ defer b.mu.Unlock()
+
+ if q, ok := b.queues[queue]; ok {
+   return len(q.messages)
+ }
+ return 0
+}
+
+// Close the broker, the delivery channels of the consumers are closed
+func (b *MemoryBroker) Close(){
+
+ b.mu.Lock()
+ defer b.mu.Unlock()
+
+ b.closed = true
+ for _, q := range b.queues {
+   q.cond.Broadcast()
+ }
+}
+
+// Create a client of the broker used by a microservice
+func (b *MemoryBroker) Client() Communication{
+ return &memoryClient{broker: b}
+}
+
+// Add a message to the queue, the caller holds the lock of the broker
+func (b *MemoryBroker) publish(q *memoryQueue, d amqp.Delivery){
+ b.tag++
+ d.DeliveryTag = b.tag
+ d.RoutingKey = q.name
+ d.Acknowledger = &memoryAcknowledger{broker: b, queue: q}
+ q.messages = append(q.messages, d)
+ q.cond.Signal()
+}
+
+// Deliver the messages of the queue to a consumer until the broker is closed
+func (b *MemoryBroker) consume(q *memoryQueue, deliveries chan<- amqp.Delivery){
+
+ defer close(deliveries)
+
+ for {
+   b.mu.Lock()
+   for len(q.messages) == 0 && !b.closed {
+     q.cond.Wait()
+   }
+   if b.closed {
+     b.mu.Unlock()
+     return
+   }
+   d := q.messages[0]
+   q.messages = q.messages[1:]
+   q.unacked[d.DeliveryTag] = d
+   b.mu.Unlock()
+
+   deliveries <- d
+ }
+}
+
+// memoryAcknowledger acknowledges the messages of a queue of the in-memory broker
+type memoryAcknowledger struct{
+ broker *MemoryBroker
+ queue *memoryQueue
+}
+
+// Remove the acknowledged message, or all the messages up to the tag if multiple is set
+func (a *memoryAcknowledger) Ack(tag uint64, multiple bool) error{
+
+ a.broker.mu.Lock()
+ defer a.broker.mu.Unlock()
+
+ a.remove(tag, multiple)
+ return nil
+}
+
+// Remove the rejected messages and send them again if requeue is set
+func (a *memoryAcknowledger) Nack(tag uint64, multiple bool, requeue bool) error{
+
+ a.broker.mu.Lock()
+ defer a.broker.mu.Unlock()
+
+ for _, d := range a.remove(tag, multiple) {
+   if requeue && !a.broker.closed {
+     d.Redelivered = true
+     a.broker.publish(a.queue, d)
+   }
+ }
+ return nil
+}
+
+func (a *memoryAcknowledger) Reject(tag uint64, requeue bool) error{
+ return a.Nack(tag, false, requeue)
+}
+
+// Remove the unacknowledged messages, the caller holds the lock of the broker
+func (a *memoryAcknowledger) remove(tag uint64, multiple bool) []amqp.Delivery{
+
+ removed := make([]amqp.Delivery, 0)
+
+ for t, d := range a.queue.unacked {
+   if t == tag || (multiple && t < tag) {
+     removed = append(removed, d)
+     delete(a.queue.unacked, t)
+   }
+ }
+ return removed
+}
+
+// memoryClient is the Communication of a microservice connected to the in-memory broker
+type memoryClient struct{
+ broker *MemoryBroker
+}
+
+// Send messages to a specific queue
+func (c *memoryClient) Send(message []byte, queue string) error{
+ return c.SendWithContext(context.Background(), message, queue)
+}
+
+// Send messages to a specific queue with the trace context of ctx in the message headers
+func (c *memoryClient) SendWithContext(ctx context.Context, message []byte, queue string) error{
+
+ headers := amqp.Table{}
+ InjectContext(ctx, headers)
+
+ c.broker.mu.Lock()
+ defer c.broker.mu.Unlock()
+
+ // Unroutable messages are dropped like in RabbitMQ
+ q, ok := c.broker.queues[queue]
+ if !ok || c.broker.closed {
+   return nil
+ }
+
+ c.broker.publish(q, amqp.Delivery{
+   ContentType: "text/json",
+   Headers:     headers,
+   Body:        message,
+ })
+
+ return nil
+}
+
+// Receive messages from a specific queue
+func (c *memoryClient) Receive(queue string) (<-chan amqp.Delivery, error){
+
+ c.broker.mu.Lock()
+ q, ok := c.broker.queues[queue]
+ closed := c.broker.closed
+ c.broker.mu.Unlock()
+
+ if !ok || closed {
+   return nil, errors.New("Failed to create channel to receive message, the queue is not declared")
+ }
+
+ deliveries := make(chan amqp.Delivery)
+ go c.broker.consume(q, deliveries)
+
+ return deliveries, nil
+}
+
+// The client is always connected to the in-memory broker
+func (c *memoryClient) Connect() error{
+ return nil
+}
@@ -0,0 +1,89 @@
+/*
+
+Copyright (C) 2020 Alex Neo
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package communication
+
+import (
+ "context"
+ "time"
+
+ "github.com/streadway/amqp"
+ "go.opentelemetry.io/otel"
+ "go.opentelemetry.io/otel/attribute"
+ "go.opentelemetry.io/otel/trace"
+)
+
+const (
+ // Name of the tracer used by the microservices to trace the scheduling of a pod
+ TracerName = "github.com/alexnjh/epsilon"
+
+ // Message header holding the time the message was sent, used to trace the time spent in the queue
+ SentTimeHeader = "epsilon-sent-time"
+)
+
+// Returns the tracer used by the microservices to trace the scheduling of a pod
+func Tracer() trace.Tracer{
+ return otel.Tracer(TracerName)
+}
+
+// headerCarrier carries the trace context in the headers of a message
+type headerCarrier amqp.Table
+
+func (h headerCarrier) Get(key string) string{
+ if value, ok := h[key].(string); ok {
+   return value
+ }
+ return ""
+}
+
+func (h headerCarrier) Set(key string, value string){
+ h[key] = value
+}
+
+func (h headerCarrier) Keys() []string{
+ keys := make([]string, 0, len(h))
+ for key := range h {
+   keys = append(keys, key)
+ }
+ return keys
+}
+
+// Add the trace context of ctx and the time the message is sent to the message headers
+func InjectContext(ctx context.Context, headers amqp.Table){
+ otel.GetTextMapPropagator().Inject(ctx, headerCarrier(headers))
+ headers[SentTimeHeader] = time.Now().UTC().Format(time.RFC3339Nano)
+}
+
+// Returns a context carrying the trace context of a message received from the queue. A span covering
+// the time the message waited in the queue is recorded if the message has the time it was sent.
+func ExtractContext(d amqp.Delivery, queue string) context.Context{
+
+ ctx := otel.GetTextMapPropagator().Extract(context.Background(), headerCarrier(d.Headers))
+
+ if value, ok := d.Headers[SentTimeHeader].(string); ok {
+   if sent, err := time.Parse(time.RFC3339Nano, value); err == nil {
+     _, span := Tracer().Start(ctx, "QueueWait",
+       trace.WithTimestamp(sent),
+       trace.WithSpanKind(trace.SpanKindConsumer),
+       trace.WithAttributes(attribute.String("messaging.destination", queue)))
+     span.End()
+   }
+ }
+
+ return ctx
+}
@@ -0,0 +1,274 @@
+/*
+
+Copyright (C) 2020 Alex Neo
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package tracing
+
+import (
+ "bytes"
+ "context"
+ "encoding/json"
+ "fmt"
+ "io"
+ "io/ioutil"
+ "net/http"
+ "strconv"
+ "time"
+
+ "go.opentelemetry.io/otel/attribute"
+ "go.opentelemetry.io/otel/codes"
+ "go.opentelemetry.io/otel/sdk/resource"
+ sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+/*
+Exporter sending the spans to an OTLP collector over HTTP with the JSON encoding.
+
+The OTLP exporters of OpenTelemetry pull in a recent gRPC, which does not build with the etcd
+client of the Kubernetes libraries used by the schedulers, so the spans are encoded here instead.
+*/
+type otlpExporter struct{
+ url string
+ client *http.Client
+}
+
+func newOTLPExporter(url string) *otlpExporter{
+ return &otlpExporter{url: url, client: &http.Client{Timeout: 10*time.Second}}
+}
+
+// Send the spans to the collector
+func (e *otlpExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error{
+
+ body, err := json.Marshal(encodeSpans(spans))
+ if err != nil {
+   return err
+ }
+
+ req, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(body))
+ if err != nil {
+   return err
+ }
+ req.Header.Set("Content-Type", "application/json")
+
+ resp, err := e.client.Do(req.WithContext(ctx))
+ if err != nil {
+   return err
+ }
+ defer resp.Body.Close()
+ io.Copy(ioutil.Discard, resp.Body)
+
+ if resp.StatusCode < 200 || resp.StatusCode > 299 {
+   return fmt.Errorf("failed to export %d spans to %s: %s", len(spans), e.url, resp.Status)
+ }
+
+ return nil
+}
+
+// Nothing to release as every export is a single request
+func (e *otlpExporter) Shutdown(ctx context.Context) error{
+ return nil
+}
+
+// The messages of the OTLP trace service, see opentelemetry/proto/trace/v1/trace.proto
+type otlpRequest struct{
+ ResourceSpans []*otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct{
+ Resource otlpResource `json:"resource"`
+ ScopeSpans []*otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct{
+ Attributes []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpScopeSpans struct{
+ Scope otlpScope `json:"scope"`
+ Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct{
+ Name string `json:"name"`
+ Version string `json:"version,omitempty"`
+}
+
+type otlpSpan struct{
+ TraceID string `json:"traceId"`
+ SpanID string `json:"spanId"`
+ ParentSpanID string `json:"parentSpanId,omitempty"`
+ Name string `json:"name"`
+ Kind int `json:"kind"`
+ StartTimeUnixNano string `json:"startTimeUnixNano"`
+ EndTimeUnixNano string `json:"endTimeUnixNano"`
+ Attributes []otlpKeyValue `json:"attributes,omitempty"`
+ Events []otlpEvent `json:"events,omitempty"`
+ Status otlpStatus `json:"status"`
+}
+
+type otlpEvent struct{
+ TimeUnixNano string `json:"timeUnixNano"`
+ Name string `json:"name"`
+ Attributes []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct{
+ Code int `json:"code,omitempty"`
+ Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct{
+ Key string `json:"key"`
+ Value otlpValue `json:"value"`
+}
+
+// Only one of the fields is set, the 64 bit integers are strings in the JSON encoding
+type otlpValue struct{
+ StringValue *string `json:"stringValue,omitempty"`
+ BoolValue *bool `json:"boolValue,omitempty"`
+ IntValue *string `json:"intValue,omitempty"`
+ DoubleValue *float64 `json:"doubleValue,omitempty"`
+ ArrayValue *otlpArray `json:"arrayValue,omitempty"`
+}
+
+type otlpArray struct{
+ Values []otlpValue `json:"values"`
+}
+
+// Group the spans by resource and instrumentation library
+func encodeSpans(spans []sdktrace.ReadOnlySpan) *otlpRequest{
+
+ req := &otlpRequest{}
+ resources := make(map[*resource.Resource]*otlpResourceSpans)
+ scopes := make(map[*otlpResourceSpans]map[string]*otlpScopeSpans)
+
+ for _, span := range spans {
+
+   rs, ok := resources[span.Resource()]
+   if !ok {
+     rs = &otlpResourceSpans{Resource: otlpResource{Attributes: encodeAttributes(span.Resource().Attributes())}}
+     resources[span.Resource()] = rs
+     scopes[rs] = make(map[string]*otlpScopeSpans)
+     req.ResourceSpans = append(req.ResourceSpans, rs)
+   }
+
+   library := span.InstrumentationLibrary()
+   ss, ok := scopes[rs][library.Name+"@"+library.Version]
+   if !ok {
+     ss = &otlpScopeSpans{Scope: otlpScope{Name: library.Name, Version: library.Version}}
+     scopes[rs][library.Name+"@"+library.Version] = ss
+     rs.ScopeSpans = append(rs.ScopeSpans, ss)
+   }
+
+   ss.Spans = append(ss.Spans, encodeSpan(span))
+ }
+
+ return req
+}
+
+func encodeSpan(span sdktrace.ReadOnlySpan) otlpSpan{
+
+ s := otlpSpan{
+   TraceID: span.SpanContext().TraceID().String(),
+   SpanID: span.SpanContext().SpanID().String(),
+   Name: span.Name(),
+   // The span kinds of OpenTelemetry have the same values as in OTLP
+   Kind: int(span.SpanKind()),
+   StartTimeUnixNano: unixNano(span.StartTime()),
+   EndTimeUnixNano: unixNano(span.EndTime()),
+   Attributes: encodeAttributes(span.Attributes()),
+ }
+
+ if span.Parent().SpanID().IsValid() {
+   s.ParentSpanID = span.Parent().SpanID().String()
+ }
+
+ for _, event := range span.Events() {
+   s.Events = append(s.Events, otlpEvent{
+     TimeUnixNano: unixNano(event.Time),
+     Name: event.Name,
+     Attributes: encodeAttributes(event.Attributes),
+   })
+ }
+
+ // The status codes are numbered differently in OTLP
+ switch span.Status().Code {
+ case codes.Ok:
+   s.Status.Code = 1
+ case codes.Error:
+   s.Status.Code = 2
+   s.Status.Message = span.Status().Description
+ }
+
+ return s
+}
+
+func encodeAttributes(attrs []attribute.KeyValue) []otlpKeyValue{
+
+ values := make([]otlpKeyValue, 0, len(attrs))
+ for _, attr := range attrs {
+   values = append(values, otlpKeyValue{Key: string(attr.Key), Value: encodeValue(attr.Value)})
+ }
+
+ return values
+}
+
+func encodeValue(v attribute.Value) otlpValue{
+
+ switch v.Type() {
+ case attribute.BOOL:
+   b := v.AsBool()
+   return otlpValue{BoolValue: &b}
+ case attribute.INT64:
+   i := strconv.FormatInt(v.AsInt64(), 10)
+   return otlpValue{IntValue: &i}
+ case attribute.FLOAT64:
+   f := v.AsFloat64()
+   return otlpValue{DoubleValue: &f}
+ case attribute.BOOLSLICE:
+   array := &otlpArray{}
+   for _, b := range v.AsBoolSlice() {
+     array.Values = append(array.Values, encodeValue(attribute.BoolValue(b)))
+   }
+   return otlpValue{ArrayValue: array}
+ case attribute.INT64SLICE:
+   array := &otlpArray{}
+   for _, i := range v.AsInt64Slice() {
+     array.Values = append(array.Values, encodeValue(attribute.Int64Value(i)))
+   }
+   return otlpValue{ArrayValue: array}
+ case attribute.FLOAT64SLICE:
+   array := &otlpArray{}
+   for _, f := range v.AsFloat64Slice() {
+     array.Values = append(array.Values, encodeValue(attribute.Float64Value(f)))
+   }
+   return otlpValue{ArrayValue: array}
+ case attribute.STRINGSLICE:
+   array := &otlpArray{}
+   for _, s := range v.AsStringSlice() {
+     array.Values = append(array.Values, encodeValue(attribute.StringValue(s)))
+   }
+   return otlpValue{ArrayValue: array}
+ default:
+   s := v.Emit()
+   return otlpValue{StringValue: &s}
+ }
+}
+
+func unixNano(t time.Time) string{
+ return strconv.FormatInt(t.UnixNano(), 10)
+}
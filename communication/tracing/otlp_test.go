@@ -0,0 +1,135 @@
+/*
+
+Copyright (C) 2020 Alex Neo
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// sequentialIDs generates the trace and span ids 1, 2, 3...
+type sequentialIDs struct {
+	next byte
+}
+
+func (g *sequentialIDs) NewIDs(ctx context.Context) (trace.TraceID, trace.SpanID) {
+	g.next++
+	return trace.TraceID{15: g.next}, trace.SpanID{7: g.next}
+}
+
+func (g *sequentialIDs) NewSpanID(ctx context.Context, traceID trace.TraceID) trace.SpanID {
+	g.next++
+	return trace.SpanID{7: g.next}
+}
+
+// Records the spans of a parent and a child span covering every attribute type, an event and
+// the statuses.
+func recordSpans(t *testing.T) []sdktrace.ReadOnlySpan {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(recorder),
+		sdktrace.WithIDGenerator(&sequentialIDs{}),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", "coordinator"))),
+	)
+	tracer := provider.Tracer("github.com/alexnjh/epsilon", trace.WithInstrumentationVersion("1.0.0"))
+
+	start := time.Unix(1600000000, 0)
+	ctx, parent := tracer.Start(context.Background(), "Coordinate",
+		trace.WithTimestamp(start),
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("pod", "default/pod-1"),
+			attribute.Bool("retry", false),
+			attribute.Int64("replicas", 3),
+			attribute.Float64("score", 0.5),
+			attribute.StringSlice("queues", []string{"epsilon.distributed", "epsilon.backoff"}),
+			attribute.Int64Slice("backoff", []int64{2, 4}),
+			attribute.BoolSlice("ready", []bool{true}),
+			attribute.Float64Slice("usage", []float64{0.25}),
+		))
+	parent.SetStatus(codes.Ok, "")
+
+	_, child := tracer.Start(ctx, "Bind", trace.WithTimestamp(start.Add(time.Millisecond)))
+	child.AddEvent("conflict", trace.WithTimestamp(start.Add(2*time.Millisecond)), trace.WithAttributes(attribute.String("node", "node-a")))
+	child.SetStatus(codes.Error, "pod is already assigned")
+	child.End(trace.WithTimestamp(start.Add(3 * time.Millisecond)))
+	parent.End(trace.WithTimestamp(start.Add(4 * time.Millisecond)))
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	return spans
+}
+
+func TestExportSpans(t *testing.T) {
+	var body []byte
+	var contentType string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	if err := newOTLPExporter(server.URL).ExportSpans(context.Background(), recordSpans(t)); err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "application/json" {
+		t.Errorf("expected a JSON request, got %q", contentType)
+	}
+
+	want, err := ioutil.ReadFile("testdata/request.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got, expected interface{}
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(want, &expected); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected request\n%s", body)
+	}
+}
+
+func TestExportSpansError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	if err := newOTLPExporter(server.URL).ExportSpans(context.Background(), recordSpans(t)); err == nil {
+		t.Error("expected the rejected export to fail")
+	}
+}
@@ -0,0 +1,81 @@
+/*
+
+Copyright (C) 2020 Alex Neo
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+// Package tracing sets up the export of the spans recorded by the microservices
+package tracing
+
+import (
+ "context"
+ "os"
+ "strings"
+
+ "go.opentelemetry.io/otel"
+ "go.opentelemetry.io/otel/propagation"
+ "go.opentelemetry.io/otel/sdk/resource"
+ sdktrace "go.opentelemetry.io/otel/sdk/trace"
+ semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+)
+
+/*
+Install the W3C trace context propagator and the tracer provider of the microservice.
+
+The spans are exported with OTLP over HTTP when the OTEL_EXPORTER_OTLP_ENDPOINT (e.g.
+http://localhost:4318 for a local collector) or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT environment
+variable is set, otherwise the trace context is only propagated. The returned function flushes
+the spans and should be called before exiting.
+*/
+func Setup(serviceName string) (func(context.Context) error, error){
+
+ otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+ url := tracesURL()
+ if len(url) == 0 {
+   return func(context.Context) error { return nil }, nil
+ }
+
+ res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+   semconv.SchemaURL,
+   semconv.ServiceNameKey.String(serviceName),
+ ))
+ if err != nil {
+   return nil, err
+ }
+
+ provider := sdktrace.NewTracerProvider(
+   sdktrace.WithBatcher(newOTLPExporter(url)),
+   sdktrace.WithResource(res),
+ )
+ otel.SetTracerProvider(provider)
+
+ return provider.Shutdown, nil
+}
+
+// Returns the URL the spans are sent to, the traces endpoint is used as is while the path of the
+// traces is appended to the base endpoint
+func tracesURL() string{
+
+ if url := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"); len(url) != 0 {
+   return url
+ }
+
+ if url := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); len(url) != 0 {
+   return strings.TrimSuffix(url, "/") + "/v1/traces"
+ }
+
+ return ""
+}
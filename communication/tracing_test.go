@@ -0,0 +1,94 @@
+/*
+
+Copyright (C) 2020 Alex Neo
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package communication
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestContextRoundTrip(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	ctx, sender := Tracer().Start(context.Background(), "Send")
+	headers := amqp.Table{}
+	InjectContext(ctx, headers)
+	sender.End()
+
+	if _, ok := headers["traceparent"]; !ok {
+		t.Fatalf("expected the trace context in the headers, got %v", headers)
+	}
+	if _, err := time.Parse(time.RFC3339Nano, headers[SentTimeHeader].(string)); err != nil {
+		t.Fatalf("expected the time the message was sent in the headers: %v", err)
+	}
+
+	received := ExtractContext(amqp.Delivery{Headers: headers}, "epsilon.distributed")
+	_, receiver := Tracer().Start(received, "Receive")
+	receiver.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("expected the Send, QueueWait and Receive spans, got %d spans", len(spans))
+	}
+	send, wait, receive := spans[0], spans[1], spans[2]
+
+	if wait.Name() != "QueueWait" || wait.SpanKind() != trace.SpanKindConsumer {
+		t.Errorf("expected a QueueWait consumer span, got %s %s", wait.Name(), wait.SpanKind())
+	}
+	if wait.Parent().SpanID() != send.SpanContext().SpanID() || wait.SpanContext().TraceID() != send.SpanContext().TraceID() {
+		t.Errorf("expected the QueueWait span to be a child of the Send span")
+	}
+	if wait.StartTime().After(wait.EndTime()) {
+		t.Errorf("expected the QueueWait span to start when the message was sent")
+	}
+	destination := attribute.String("messaging.destination", "epsilon.distributed")
+	if attrs := wait.Attributes(); len(attrs) != 1 || attrs[0] != destination {
+		t.Errorf("expected the queue in the attributes of the QueueWait span, got %v", attrs)
+	}
+
+	if receive.Parent().SpanID() != send.SpanContext().SpanID() || receive.SpanContext().TraceID() != send.SpanContext().TraceID() {
+		t.Errorf("expected the spans of the receiver to be children of the Send span")
+	}
+}
+
+func TestExtractContextWithoutHeaders(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	ctx := ExtractContext(amqp.Delivery{}, "epsilon.distributed")
+
+	if trace.SpanContextFromContext(ctx).IsValid() {
+		t.Error("expected no trace context")
+	}
+	if spans := recorder.Ended(); len(spans) != 0 {
+		t.Errorf("expected no QueueWait span without the sent time, got %d spans", len(spans))
+	}
+}
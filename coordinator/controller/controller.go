@@ -16,11 +16,15 @@ limitations under the License.
 
 */
 
-package main
+// Package controller sends a schedule request for every new pod of the custom scheduler. It is
+// the process of the coordinator microservice, shared with the benchmark harness of the
+// general purpose scheduler.
+package controller
 
 import (
 	"fmt"
 	"time"
+	"sync/atomic"
 
   log "github.com/sirupsen/logrus"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
@@ -29,6 +33,7 @@ import (
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
   listers "k8s.io/client-go/listers/core/v1"
+  corev1 "k8s.io/api/core/v1"
 )
 
 // PodController struct defines how a PodController should encapsulate
@@ -42,6 +47,45 @@ type PodController struct {
 	handler   Handler
 }
 
+// Create a pod controller passing the pods added to the queue to the handler
+func NewPodController(clientset kubernetes.Interface, informer cache.SharedIndexInformer, lister listers.PodLister, queue workqueue.RateLimitingInterface, handler Handler) *PodController{
+  return &PodController{
+    clientset: clientset,
+    informer: informer,
+    lister: lister,
+    queue: queue,
+    handler: handler,
+  }
+}
+
+// Returns the event handler adding the new pods of the custom scheduler to the queue,
+// requestsCounter counts the pods added
+func PodEventHandler(queue workqueue.Interface, requestsCounter *uint64) cache.ResourceEventHandlerFuncs{
+  return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+
+      key, err := cache.MetaNamespaceKeyFunc(obj)
+
+      if err == nil {
+
+        obj := obj.(*corev1.Pod)
+
+        if obj.Spec.SchedulerName != "custom" ||  obj.Spec.NodeName != ""{
+          return
+        }
+
+        atomic.AddUint64(requestsCounter, 1)
+        // Add to workqueue
+        queue.Add(key)
+      }
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+		},
+		DeleteFunc: func(obj interface{}) {
+		},
+	}
+}
+
 // Run is the main path of execution for the PodController loop
 func (c *PodController) Run(stopCh <-chan struct{}) {
 	// handle a panic with logging and exiting
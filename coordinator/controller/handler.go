@@ -16,10 +16,11 @@ limitations under the License.
 
 */
 
-package main
+package controller
 
 import (
   "fmt"
+  "context"
   "encoding/json"
   "time"
   "math/rand"
@@ -34,6 +35,8 @@ import (
   corelisters "k8s.io/client-go/listers/core/v1"
   corev1 "k8s.io/api/core/v1"
   communication "github.com/alexnjh/epsilon/communication"
+  "go.opentelemetry.io/otel/attribute"
+  "go.opentelemetry.io/otel/trace"
 )
 
 // Handler interface contains the methods that are required to handle pods
@@ -55,6 +58,19 @@ type PodHandler struct{
   metricCounter prometheus.Counter
 }
 
+// Create a pod handler sending the schedule requests of the pods to their queue, or to
+// defaultQueue if the pod has no epsilon.queue label
+func NewPodHandler(defaultQueue string, hostname string, clientset kubernetes.Interface, lister corelisters.PodLister, comm communication.Communication, metricCounter prometheus.Counter) *PodHandler{
+  return &PodHandler{
+    defaultQueue: defaultQueue,
+    hostname: hostname,
+    clientset: clientset,
+    lister: lister,
+    comm: comm,
+    metricCounter: metricCounter,
+  }
+}
+
 // Init handles any handler initialization
 func (t *PodHandler) Init() error {
 	log.Info("PodHandler.Init")
@@ -93,13 +109,20 @@ func (t *PodHandler) ObjectSync(key string) error {
     queueName = t.defaultQueue
   }
 
+  // Start the trace of the scheduling of the pod, the context is sent along with the schedule request
+  ctx, span := communication.Tracer().Start(context.Background(), "Coordinate",
+    trace.WithTimestamp(timeStamp),
+    trace.WithSpanKind(trace.SpanKindProducer),
+    trace.WithAttributes(attribute.String("pod", key), attribute.String("queue", queueName)))
+  defer span.End()
+
 
   // Keep trying if unable to send schedule request to the queued.
   // This happens when connection to the rabbitmq server might be down.
   // The pod coordinator will keep trying as if send to backoff the next pod requested
   // will also fail due to connection failure.
   for {
-    if t.sendScheduleRequest(key,timeStamp,queueName) == false {
+    if t.sendScheduleRequest(ctx,key,timeStamp,queueName) == false {
 
       for{
         err := t.comm.Connect()
@@ -136,7 +159,7 @@ func (t *PodHandler) ObjectSync(key string) error {
 }
 
 // Send schedule request to the schedulers
-func (t *PodHandler) sendScheduleRequest(key string, timestamp time.Time, queueName string) bool{
+func (t *PodHandler) sendScheduleRequest(ctx context.Context, key string, timestamp time.Time, queueName string) bool{
 
   timeElapsed := time.Since(timestamp);
 
@@ -145,7 +168,7 @@ func (t *PodHandler) sendScheduleRequest(key string, timestamp time.Time, queueN
     log.Fatalf("%s", err)
   }
 
-  err = t.comm.Send(respBytes,queueName)
+  err = t.comm.SendWithContext(ctx,respBytes,queueName)
 
   if err != nil{
     return false
@@ -21,12 +21,12 @@ package main
 import (
 	"os"
   "time"
+  "context"
   "fmt"
   "syscall"
   "net/http"
   "os/signal"
   "sync/atomic"
-	"k8s.io/client-go/tools/cache"
   "k8s.io/client-go/util/workqueue"
   "github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -34,9 +34,10 @@ import (
 
   kubeinformers "k8s.io/client-go/informers"
   log "github.com/sirupsen/logrus"
-  corev1 "k8s.io/api/core/v1"
   configparser "github.com/bigkevmcd/go-configparser"
   communication "github.com/alexnjh/epsilon/communication"
+  tracing "github.com/alexnjh/epsilon/communication/tracing"
+  coordinator "github.com/alexnjh/epsilon/coordinator/controller"
 )
 
 const (
@@ -128,6 +129,13 @@ func main() {
     }
   }
 
+  // Start the trace of the pods and export the spans if a collector is configured
+  shutdown, err := tracing.Setup("coordinator")
+  if err != nil {
+    log.Fatalf(err.Error())
+  }
+  defer shutdown(context.Background())
+
   // Declare the counter as unsigned int
   var requestsCounter uint64 = 0
 
@@ -176,46 +184,12 @@ func main() {
   go metricsServer()
 
   // Create a pod controller
-  controller := PodController{
-  clientset: client,
-  informer: pod_informer,
-  lister: pod_lister,
-  queue: queue,
-  handler: &PodHandler{
-      defaultQueue: defaultQueue,
-      hostname: hostName,
-      clientset: client,
-      lister: pod_lister,
-      comm: &comm,
-      metricCounter: newCounter,
-    },
-  }
+  controller := coordinator.NewPodController(client, pod_informer, pod_lister, queue,
+    coordinator.NewPodHandler(defaultQueue, hostName, client, pod_lister, &comm, newCounter))
 
 
   // Add a event handler to listen for new pods
-	pod_informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc: func(obj interface{}) {
-
-      key, err := cache.MetaNamespaceKeyFunc(obj)
-
-      if err == nil {
-
-        obj := obj.(*corev1.Pod)
-
-        if obj.Spec.SchedulerName != "custom" ||  obj.Spec.NodeName != ""{
-          return
-        }
-
-        atomic.AddUint64(&requestsCounter, 1)
-        // Add to workqueue
-        queue.Add(key)
-      }
-		},
-		UpdateFunc: func(oldObj, newObj interface{}) {
-		},
-		DeleteFunc: func(obj interface{}) {
-		},
-	})
+	pod_informer.AddEventHandler(coordinator.PodEventHandler(queue, &requestsCounter))
 
 	// use a channel to synchronize the finalization for a graceful shutdown
 	stopCh := make(chan struct{})
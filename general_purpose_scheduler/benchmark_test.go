@@ -0,0 +1,109 @@
+/*
+Copyright (C) 2020 Alex Neo
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Size of the benchmark, e.g. go test -run NONE -bench Cluster -benchtime 1x -epsilon.pods 5000
+var (
+	benchNodes         = flag.Int("epsilon.nodes", 200, "Number of fake nodes")
+	benchReplicas      = flag.Int("epsilon.replicas", 3, "Number of scheduler replicas")
+	benchPods          = flag.Int("epsilon.pods", 2000, "Number of pods fitting on the nodes")
+	benchUnschedulable = flag.Int("epsilon.unschedulable", 0, "Number of pods requesting more than a node has")
+	benchRate          = flag.Float64("epsilon.rate", 1000, "Pods created per second, 0 creates them as fast as possible")
+	benchBackoffUnit   = flag.Duration("epsilon.backoff-unit", 10*time.Millisecond, "Duration of one second of the retry backoff")
+	benchTimeout       = flag.Duration("epsilon.timeout", 5*time.Minute, "Time to wait for the pods to be scheduled")
+	benchLogs          = flag.Bool("epsilon.logs", false, "Keep the logs of the services")
+)
+
+func runHarness(tb testing.TB, config harnessConfig) harnessReport {
+	tb.Helper()
+
+	if !*benchLogs {
+		log.SetOutput(ioutil.Discard)
+	}
+
+	h, err := newHarness(config)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer h.Stop()
+
+	if err := h.Start(); err != nil {
+		tb.Fatal(err)
+	}
+	return h.Run()
+}
+
+// BenchmarkCluster runs the coordinator, the scheduler replicas and the retry service against a
+// fake API server and reports the scheduling latency, the throughput and the conflicts between
+// the replicas. Every iteration schedules all the pods, run it with -benchtime 1x.
+func BenchmarkCluster(b *testing.B) {
+	config := harnessConfig{
+		Nodes:         *benchNodes,
+		Replicas:      *benchReplicas,
+		Pods:          *benchPods,
+		Unschedulable: *benchUnschedulable,
+		Rate:          *benchRate,
+		BackoffUnit:   *benchBackoffUnit,
+		Timeout:       *benchTimeout,
+	}
+
+	var report harnessReport
+	for i := 0; i < b.N; i++ {
+		report = runHarness(b, config)
+		if report.Pending != 0 {
+			b.Errorf("%d pods were not scheduled within %v", report.Pending, config.Timeout)
+		}
+	}
+
+	b.Logf("%d pods over %d nodes with %d replicas\n%s", config.Pods+config.Unschedulable, config.Nodes, config.Replicas, report)
+	b.ReportMetric(report.Throughput, "pods/s")
+	b.ReportMetric(float64(report.P50)/float64(time.Millisecond), "p50-ms")
+	b.ReportMetric(float64(report.P99)/float64(time.Millisecond), "p99-ms")
+	b.ReportMetric(float64(report.Conflicts), "conflicts")
+	b.ReportMetric(float64(report.Overcommitted), "overcommitted-nodes")
+}
+
+func TestHarness(t *testing.T) {
+	report := runHarness(t, harnessConfig{
+		Nodes:         10,
+		Replicas:      2,
+		Pods:          50,
+		Unschedulable: 1,
+		BackoffUnit:   time.Millisecond,
+		Timeout:       30 * time.Second,
+	})
+
+	if report.Scheduled != 50 || report.Unschedulable != 1 || report.Pending != 0 {
+		t.Fatalf("expected 50 scheduled and 1 unschedulable pods, got\n%s", report)
+	}
+	// The pod is retried after waiting 2, 4, 16 and 256 seconds of backoff
+	if report.Retries != 4 {
+		t.Errorf("expected 4 retries, got %d", report.Retries)
+	}
+	if report.P99 <= 0 || report.P99 > report.Max || report.Throughput <= 0 {
+		t.Errorf("expected the latencies and the throughput to be measured, got\n%s", report)
+	}
+}
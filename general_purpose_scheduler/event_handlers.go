@@ -81,6 +81,9 @@ func addAllEventHandlers(
         if err != nil{
           fmt.Println("Fail to add pod to cache", err)
         }
+
+        // Count the pods already bound, also when the informer lists the pods at start up
+        sched.ObservePod(pod)
       },
       // One of the pods got updated information
       UpdateFunc: func(oldObj, newObj interface{}) {
@@ -94,6 +97,9 @@ func addAllEventHandlers(
           fmt.Println("Fail to update pod to cache", err)
         }
 
+        // Count the binding made by any of the schedulers
+        sched.ObservePod(newPod)
+
       },
       // A pod is deleted
       DeleteFunc: func(obj interface{}) {
@@ -104,6 +110,8 @@ func addAllEventHandlers(
           fmt.Println("Fail to remove Pod from cache", err)
         }
 
+        sched.ForgetPod(pod)
+
       },
     })
 
@@ -0,0 +1,64 @@
+/*
+Copyright (C) 2020 Alex Neo
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package loadaware
+
+import (
+	"context"
+	"sync"
+)
+
+// FakeProvider is a MetricsProvider returning fixed usage, it is used in tests and to run the
+// scheduler without a metrics server.
+type FakeProvider struct {
+	mu      sync.Mutex
+	metrics map[string]NodeMetrics
+	err     error
+	calls   int
+}
+
+// NewFakeProvider creates a provider returning the given usage.
+func NewFakeProvider(metrics map[string]NodeMetrics) *FakeProvider {
+	return &FakeProvider{metrics: metrics}
+}
+
+// Set replaces the usage and the error returned by the provider.
+func (p *FakeProvider) Set(metrics map[string]NodeMetrics, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.metrics, p.err = metrics, err
+}
+
+// Calls returns the number of times the usage was fetched.
+func (p *FakeProvider) Calls() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.calls
+}
+
+func (p *FakeProvider) NodeMetrics(ctx context.Context) (map[string]NodeMetrics, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	if p.err != nil {
+		return nil, p.err
+	}
+	metrics := make(map[string]NodeMetrics, len(p.metrics))
+	for name, m := range p.metrics {
+		metrics[name] = m
+	}
+	return metrics, nil
+}
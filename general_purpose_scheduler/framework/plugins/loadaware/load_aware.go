@@ -0,0 +1,186 @@
+/*
+Copyright (C) 2020 Alex Neo
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package loadaware
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	schedulerv1alpha2 "github.com/alexnjh/epsilon/general_purpose_scheduler/scheduler/config"
+	framework "github.com/alexnjh/epsilon/general_purpose_scheduler/framework/v1alpha1"
+	schedutil "github.com/alexnjh/epsilon/general_purpose_scheduler/scheduler/util"
+)
+
+const (
+	// Name is the name of the plugin used in the plugin registry and configurations.
+	Name = "LoadAware"
+
+	// Sources of the node usage
+	SourceMetricsAPI = "metrics-api"
+	SourcePrometheus = "prometheus"
+
+	// Default PromQL queries, they need node-exporter series relabelled with the node name
+	DefaultCPUQuery    = `sum by (node) (rate(node_cpu_seconds_total{mode!="idle"}[1m]))`
+	DefaultMemoryQuery = `sum by (node) (node_memory_MemTotal_bytes - node_memory_MemAvailable_bytes)`
+
+	defaultUsageWeight     = 50
+	defaultRefreshInterval = 15 * time.Second
+	defaultMaxStaleness    = 120 * time.Second
+)
+
+// LoadAware is a score plugin that favors nodes with a low utilisation. The utilisation of a node
+// blends the usage measured on the node with the resources requested by its pods, so that nodes
+// whose pods request more than they use are not passed over. Nodes without recent usage are scored
+// on their requests only.
+type LoadAware struct {
+	handle framework.FrameworkHandle
+	cache  *metricsCache
+	// Starts the refresh of the usage on the first Score
+	start sync.Once
+	// Percentage of the utilisation taken from the usage
+	usageWeight int64
+}
+
+var _ framework.ScorePlugin = &LoadAware{}
+
+// Name returns name of the plugin. It is used in logs, etc.
+func (pl *LoadAware) Name() string {
+	return Name
+}
+
+// Score invoked at the score extension point.
+func (pl *LoadAware) Score(ctx context.Context, state *framework.CycleState, pod *v1.Pod, nodeName string) (int64, *framework.Status) {
+	nodeInfo, err := pl.handle.SnapshotSharedLister().NodeInfos().Get(nodeName)
+	if err != nil {
+		return 0, framework.NewStatus(framework.Error, fmt.Sprintf("getting node %q from Snapshot: %v", nodeName, err))
+	}
+
+	// The usage is fetched in the background for as long as the scheduler runs, Score only reads
+	// the last usage fetched
+	pl.start.Do(func() {
+		go pl.cache.run(wait.NeverStop)
+	})
+
+	metrics, ok := pl.cache.get(nodeName)
+	return pl.score(pod, nodeInfo, metrics, ok), nil
+}
+
+// score returns (1 - utilisation) * MaxNodeScore where the utilisation is the average of the CPU and
+// memory fractions after placing the pod on the node.
+func (pl *LoadAware) score(pod *v1.Pod, nodeInfo *framework.NodeInfo, metrics NodeMetrics, hasMetrics bool) int64 {
+	allocatable := nodeInfo.Allocatable
+	if allocatable.MilliCPU == 0 || allocatable.Memory == 0 {
+		return 0
+	}
+
+	var podCPU, podMemory int64
+	for i := range pod.Spec.Containers {
+		cpu, memory := schedutil.GetNonzeroRequests(&pod.Spec.Containers[i].Resources.Requests)
+		podCPU += cpu
+		podMemory += memory
+	}
+
+	requested := (fraction(nodeInfo.NonZeroRequested.MilliCPU+podCPU, allocatable.MilliCPU) +
+		fraction(nodeInfo.NonZeroRequested.Memory+podMemory, allocatable.Memory)) / 2
+
+	utilisation := requested
+	if hasMetrics {
+		used := (fraction(metrics.MilliCPU+podCPU, allocatable.MilliCPU) +
+			fraction(metrics.Memory+podMemory, allocatable.Memory)) / 2
+		w := float64(pl.usageWeight) / 100
+		utilisation = w*used + (1-w)*requested
+	}
+
+	return int64((1 - utilisation) * float64(framework.MaxNodeScore))
+}
+
+// fraction returns value / total capped to 1.
+func fraction(value, total int64) float64 {
+	if value >= total {
+		return 1
+	}
+	return float64(value) / float64(total)
+}
+
+// ScoreExtensions of the Score plugin.
+func (pl *LoadAware) ScoreExtensions() framework.ScoreExtensions {
+	return nil
+}
+
+// New initializes a new plugin and returns it. The usage is only fetched once the plugin starts
+// scoring.
+func New(plArgs runtime.Object, h framework.FrameworkHandle) (framework.Plugin, error) {
+	args := &schedulerv1alpha2.LoadAwareArgs{}
+	if err := framework.DecodeInto(plArgs, args); err != nil {
+		return nil, err
+	}
+
+	var provider MetricsProvider
+	switch args.Source {
+	case "", SourceMetricsAPI:
+		if h.ClientSet() == nil {
+			return nil, fmt.Errorf("%s: the %q source needs a clientset", Name, SourceMetricsAPI)
+		}
+		provider = NewMetricsAPIProvider(h.ClientSet().CoreV1().RESTClient())
+	case SourcePrometheus:
+		if len(args.PrometheusURL) == 0 {
+			return nil, fmt.Errorf("%s: the %q source needs a prometheus url", Name, SourcePrometheus)
+		}
+		cpuQuery, memoryQuery := args.CPUQuery, args.MemoryQuery
+		if len(cpuQuery) == 0 {
+			cpuQuery = DefaultCPUQuery
+		}
+		if len(memoryQuery) == 0 {
+			memoryQuery = DefaultMemoryQuery
+		}
+		provider = NewPrometheusProvider(args.PrometheusURL, cpuQuery, memoryQuery)
+	default:
+		return nil, fmt.Errorf("%s: unknown metrics source %q", Name, args.Source)
+	}
+
+	return newWithProvider(args, provider, h)
+}
+
+// newWithProvider creates the plugin reading the usage from the given provider.
+func newWithProvider(args *schedulerv1alpha2.LoadAwareArgs, provider MetricsProvider, h framework.FrameworkHandle) (*LoadAware, error) {
+	usageWeight := int64(defaultUsageWeight)
+	if args.UsageWeight != nil {
+		usageWeight = *args.UsageWeight
+	}
+	if usageWeight < 0 || usageWeight > 100 {
+		return nil, fmt.Errorf("%s: usage weight %d is not a percentage", Name, usageWeight)
+	}
+
+	refreshInterval, maxStaleness := defaultRefreshInterval, defaultMaxStaleness
+	if args.RefreshIntervalSeconds > 0 {
+		refreshInterval = time.Duration(args.RefreshIntervalSeconds) * time.Second
+	}
+	if args.MaxStalenessSeconds > 0 {
+		maxStaleness = time.Duration(args.MaxStalenessSeconds) * time.Second
+	}
+
+	return &LoadAware{
+		handle:      h,
+		cache:       newMetricsCache(provider, refreshInterval, maxStaleness),
+		usageWeight: usageWeight,
+	}, nil
+}
@@ -0,0 +1,252 @@
+/*
+Copyright (C) 2020 Alex Neo
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package loadaware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	schedulerv1alpha2 "github.com/alexnjh/epsilon/general_purpose_scheduler/scheduler/config"
+	framework "github.com/alexnjh/epsilon/general_purpose_scheduler/framework/v1alpha1"
+)
+
+const gi = 1024 * 1024 * 1024
+
+func makePod(cpu, memory string) *v1.Pod {
+	return &v1.Pod{
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{
+				Resources: v1.ResourceRequirements{
+					Requests: v1.ResourceList{
+						v1.ResourceCPU:    resource.MustParse(cpu),
+						v1.ResourceMemory: resource.MustParse(memory),
+					},
+				},
+			}},
+		},
+	}
+}
+
+func makeNodeInfo(cpu, memory string, pods ...*v1.Pod) *framework.NodeInfo {
+	allocatable := v1.ResourceList{
+		v1.ResourceCPU:    resource.MustParse(cpu),
+		v1.ResourceMemory: resource.MustParse(memory),
+	}
+	nodeInfo := framework.NewNodeInfo(pods...)
+	nodeInfo.SetNode(&v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node"},
+		Status:     v1.NodeStatus{Capacity: allocatable, Allocatable: allocatable},
+	})
+	return nodeInfo
+}
+
+func TestScore(t *testing.T) {
+	// Half of the node is requested once the pod is placed
+	nodeInfo := makeNodeInfo("4", "8Gi", makePod("1", "2Gi"))
+	pod := makePod("1", "2Gi")
+	busy := NodeMetrics{MilliCPU: 3000, Memory: 6 * gi}
+	idle := NodeMetrics{}
+
+	tests := []struct {
+		name        string
+		usageWeight int64
+		metrics     NodeMetrics
+		hasMetrics  bool
+		want        int64
+	}{
+		{name: "requests only", usageWeight: 0, metrics: busy, hasMetrics: true, want: 50},
+		{name: "usage only", usageWeight: 100, metrics: busy, hasMetrics: true, want: 0},
+		{name: "blended busy node", usageWeight: 50, metrics: busy, hasMetrics: true, want: 25},
+		{name: "blended idle node", usageWeight: 50, metrics: idle, hasMetrics: true, want: 62},
+		{name: "no metrics falls back to requests", usageWeight: 100, hasMetrics: false, want: 50},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pl := &LoadAware{usageWeight: tt.usageWeight}
+			if score := pl.score(pod, nodeInfo, tt.metrics, tt.hasMetrics); score != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, score)
+			}
+		})
+	}
+}
+
+func TestMetricsCache(t *testing.T) {
+	start := time.Unix(1000, 0)
+	now := start
+	provider := NewFakeProvider(map[string]NodeMetrics{"node": {MilliCPU: 100, Timestamp: start}})
+
+	c := newMetricsCache(provider, 15*time.Second, time.Minute)
+	c.now = func() time.Time { return now }
+
+	if _, ok := c.get("node"); ok {
+		t.Fatalf("expected no usage before the first fetch")
+	}
+
+	c.refresh(context.Background())
+	if m, ok := c.get("node"); !ok || m.MilliCPU != 100 {
+		t.Fatalf("expected the usage of the node, got %v %v", m, ok)
+	}
+
+	// The previous usage is kept when the provider fails
+	now = start.Add(20 * time.Second)
+	provider.Set(nil, errors.New("unavailable"))
+	c.refresh(context.Background())
+	if _, ok := c.get("node"); !ok {
+		t.Errorf("expected the previous usage to be kept")
+	}
+
+	// Stale usage is ignored
+	now = start.Add(2 * time.Minute)
+	if _, ok := c.get("node"); ok {
+		t.Errorf("expected stale usage to be ignored")
+	}
+	if provider.Calls() != 2 {
+		t.Errorf("expected 2 fetches, got %d", provider.Calls())
+	}
+}
+
+// blockingProvider blocks until the context of the fetch is done.
+type blockingProvider struct {
+	calls chan struct{}
+}
+
+func (p *blockingProvider) NodeMetrics(ctx context.Context) (map[string]NodeMetrics, error) {
+	p.calls <- struct{}{}
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestMetricsCacheRun(t *testing.T) {
+	provider := NewFakeProvider(map[string]NodeMetrics{"node": {MilliCPU: 100, Timestamp: time.Now()}})
+	c := newMetricsCache(provider, 10*time.Millisecond, time.Minute)
+
+	stopCh := make(chan struct{})
+	go c.run(stopCh)
+	defer close(stopCh)
+
+	// Fetched every refresh interval
+	deadline := time.Now().Add(5 * time.Second)
+	for provider.Calls() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the usage to be fetched again, got %d fetches", provider.Calls())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if m, ok := c.get("node"); !ok || m.MilliCPU != 100 {
+		t.Errorf("expected the usage of the node, got %v %v", m, ok)
+	}
+}
+
+func TestMetricsCacheRunBounded(t *testing.T) {
+	provider := &blockingProvider{calls: make(chan struct{})}
+	c := newMetricsCache(provider, 10*time.Millisecond, time.Minute)
+	c.metrics["node"] = NodeMetrics{MilliCPU: 100, Timestamp: time.Now()}
+
+	stopCh := make(chan struct{})
+	go c.run(stopCh)
+	defer close(stopCh)
+
+	// The usage is read while a fetch is in flight, and the fetch is cancelled so that the next
+	// one starts
+	<-provider.calls
+	if m, ok := c.get("node"); !ok || m.MilliCPU != 100 {
+		t.Errorf("expected the previous usage during the fetch, got %v %v", m, ok)
+	}
+	select {
+	case <-provider.calls:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the blocked fetch to be cancelled")
+	}
+}
+
+func TestNewWithProvider(t *testing.T) {
+	weight := int64(150)
+	if _, err := newWithProvider(&schedulerv1alpha2.LoadAwareArgs{UsageWeight: &weight}, NewFakeProvider(nil), nil); err == nil {
+		t.Errorf("expected an error for a usage weight above 100")
+	}
+
+	pl, err := newWithProvider(&schedulerv1alpha2.LoadAwareArgs{}, NewFakeProvider(nil), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pl.usageWeight != defaultUsageWeight || pl.cache.refreshInterval != defaultRefreshInterval || pl.cache.maxStaleness != defaultMaxStaleness {
+		t.Errorf("expected the defaults, got %d %v %v", pl.usageWeight, pl.cache.refreshInterval, pl.cache.maxStaleness)
+	}
+}
+
+func TestPrometheusProvider(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		value := `"1.5"`
+		if r.URL.Query().Get("query") == "memory" {
+			value = fmt.Sprintf(`"%d"`, 2*gi)
+		}
+		fmt.Fprintf(w, `{"status":"success","data":{"resultType":"vector","result":[
+			{"metric":{"node":"node-a"},"value":[1000.5,%s]},
+			{"metric":{"instance":"10.0.0.1:9100"},"value":[1000.5,%s]}]}}`, value, value)
+	}))
+	defer srv.Close()
+
+	metrics, err := NewPrometheusProvider(srv.URL, "cpu", "memory").NodeMetrics(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metrics) != 1 {
+		t.Fatalf("expected the series without a node label to be skipped, got %v", metrics)
+	}
+	m := metrics["node-a"]
+	if m.MilliCPU != 1500 || m.Memory != 2*gi || !m.Timestamp.Equal(time.Unix(1000, 5e8)) {
+		t.Errorf("unexpected usage %+v", m)
+	}
+}
+
+func TestMetricsAPIProvider(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != nodeMetricsPath {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"kind":"NodeMetricsList","items":[
+			{"metadata":{"name":"node-a"},"timestamp":"2020-06-01T10:00:00Z","usage":{"cpu":"250m","memory":"1Gi"}}]}`)
+	}))
+	defer srv.Close()
+
+	client, err := kubernetes.NewForConfig(&rest.Config{Host: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metrics, err := NewMetricsAPIProvider(client.CoreV1().RESTClient()).NodeMetrics(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := metrics["node-a"]
+	if m.MilliCPU != 250 || m.Memory != gi || !m.Timestamp.Equal(time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected usage %+v", m)
+	}
+}
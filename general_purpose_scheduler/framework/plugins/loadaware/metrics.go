@@ -0,0 +1,275 @@
+/*
+Copyright (C) 2020 Alex Neo
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package loadaware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog"
+)
+
+const (
+	// Path of the node metrics in the metrics.k8s.io API
+	nodeMetricsPath = "/apis/metrics.k8s.io/v1beta1/nodes"
+
+	// Label of the Prometheus series containing the node name
+	prometheusNodeLabel = "node"
+)
+
+// NodeMetrics is the resource usage of a node.
+type NodeMetrics struct {
+	// CPU usage in millicores
+	MilliCPU int64
+	// Memory usage in bytes
+	Memory int64
+	// Time the usage was measured
+	Timestamp time.Time
+}
+
+// MetricsProvider fetches the resource usage of the nodes, keyed by node name.
+type MetricsProvider interface {
+	NodeMetrics(ctx context.Context) (map[string]NodeMetrics, error)
+}
+
+// metricsAPIProvider reads the node usage from the metrics.k8s.io API served by the metrics server.
+type metricsAPIProvider struct {
+	client rest.Interface
+}
+
+// NewMetricsAPIProvider creates a provider reading the metrics.k8s.io API through the client.
+func NewMetricsAPIProvider(client rest.Interface) MetricsProvider {
+	return &metricsAPIProvider{client: client}
+}
+
+// nodeMetricsList is the subset of the metrics.k8s.io NodeMetricsList used by the plugin.
+type nodeMetricsList struct {
+	Items []struct {
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+		Timestamp time.Time       `json:"timestamp"`
+		Usage     v1.ResourceList `json:"usage"`
+	} `json:"items"`
+}
+
+func (p *metricsAPIProvider) NodeMetrics(ctx context.Context) (map[string]NodeMetrics, error) {
+	raw, err := p.client.Get().AbsPath(nodeMetricsPath).DoRaw(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var list nodeMetricsList
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, fmt.Errorf("decoding node metrics: %v", err)
+	}
+
+	metrics := make(map[string]NodeMetrics, len(list.Items))
+	for _, item := range list.Items {
+		metrics[item.Metadata.Name] = NodeMetrics{
+			MilliCPU:  item.Usage.Cpu().MilliValue(),
+			Memory:    item.Usage.Memory().Value(),
+			Timestamp: item.Timestamp,
+		}
+	}
+	return metrics, nil
+}
+
+// prometheusProvider reads the node usage from the results of two PromQL queries.
+type prometheusProvider struct {
+	url         string
+	cpuQuery    string
+	memoryQuery string
+	client      *http.Client
+}
+
+// NewPrometheusProvider creates a provider running the queries against the Prometheus server. The
+// CPU query must return cores and the memory query bytes, both with the node name in the "node" label.
+func NewPrometheusProvider(address, cpuQuery, memoryQuery string) MetricsProvider {
+	return &prometheusProvider{
+		url:         strings.TrimSuffix(address, "/"),
+		cpuQuery:    cpuQuery,
+		memoryQuery: memoryQuery,
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// prometheusResponse is the response of the Prometheus instant query API for a vector result.
+type prometheusResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Metric map[string]string `json:"metric"`
+			Value  []interface{}     `json:"value"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+type sample struct {
+	value     float64
+	timestamp time.Time
+}
+
+func (p *prometheusProvider) NodeMetrics(ctx context.Context) (map[string]NodeMetrics, error) {
+	cpu, err := p.query(ctx, p.cpuQuery)
+	if err != nil {
+		return nil, err
+	}
+	memory, err := p.query(ctx, p.memoryQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the nodes with both samples are used
+	metrics := make(map[string]NodeMetrics, len(cpu))
+	for node, c := range cpu {
+		m, ok := memory[node]
+		if !ok {
+			continue
+		}
+		timestamp := c.timestamp
+		if m.timestamp.Before(timestamp) {
+			timestamp = m.timestamp
+		}
+		metrics[node] = NodeMetrics{
+			MilliCPU:  int64(math.Round(c.value * 1000)),
+			Memory:    int64(m.value),
+			Timestamp: timestamp,
+		}
+	}
+	return metrics, nil
+}
+
+// Run an instant query and return the samples by node name
+func (p *prometheusProvider) query(ctx context.Context, query string) (map[string]sample, error) {
+	req, err := http.NewRequest(http.MethodGet, p.url+"/api/v1/query?query="+url.QueryEscape(query), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result prometheusResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decoding prometheus response: %v", err)
+	}
+	if result.Status != "success" {
+		return nil, fmt.Errorf("prometheus query %q failed: %s", query, result.Error)
+	}
+	if result.Data.ResultType != "vector" {
+		return nil, fmt.Errorf("prometheus query %q returned a %s, expected a vector", query, result.Data.ResultType)
+	}
+
+	samples := make(map[string]sample, len(result.Data.Result))
+	for _, r := range result.Data.Result {
+		node := r.Metric[prometheusNodeLabel]
+		if len(node) == 0 || len(r.Value) != 2 {
+			continue
+		}
+		ts, ok := r.Value[0].(float64)
+		if !ok {
+			continue
+		}
+		str, ok := r.Value[1].(string)
+		if !ok {
+			continue
+		}
+		value, err := strconv.ParseFloat(str, 64)
+		if err != nil || math.IsNaN(value) {
+			continue
+		}
+		sec, frac := math.Modf(ts)
+		samples[node] = sample{value: value, timestamp: time.Unix(int64(sec), int64(frac*1e9))}
+	}
+	return samples, nil
+}
+
+// metricsCache keeps the last usage fetched from the provider. The usage is fetched in the
+// background every refresh interval (see run), and the usage of a node measured longer than
+// maxStaleness ago is not returned.
+type metricsCache struct {
+	provider        MetricsProvider
+	refreshInterval time.Duration
+	maxStaleness    time.Duration
+	now             func() time.Time
+
+	mu      sync.RWMutex
+	metrics map[string]NodeMetrics
+}
+
+func newMetricsCache(provider MetricsProvider, refreshInterval, maxStaleness time.Duration) *metricsCache {
+	return &metricsCache{
+		provider:        provider,
+		refreshInterval: refreshInterval,
+		maxStaleness:    maxStaleness,
+		now:             time.Now,
+		metrics:         make(map[string]NodeMetrics),
+	}
+}
+
+// run fetches the usage every refresh interval until stopCh is closed. A fetch is cancelled once it
+// takes longer than the refresh interval so that an unreachable provider does not delay the next one.
+func (c *metricsCache) run(stopCh <-chan struct{}) {
+	wait.Until(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), c.refreshInterval)
+		defer cancel()
+		c.refresh(ctx)
+	}, c.refreshInterval, stopCh)
+}
+
+// refresh fetches the usage from the provider, the lock is only held to replace the usage. The
+// previous usage is kept when the provider fails.
+func (c *metricsCache) refresh(ctx context.Context) {
+	metrics, err := c.provider.NodeMetrics(ctx)
+	if err != nil {
+		klog.Errorf("Unable to fetch node metrics: %v", err)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.metrics = metrics
+}
+
+// get returns the usage of the node if it is not stale.
+func (c *metricsCache) get(nodeName string) (NodeMetrics, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	m, ok := c.metrics[nodeName]
+	if !ok || c.now().Sub(m.Timestamp) > c.maxStaleness {
+		return NodeMetrics{}, false
+	}
+	return m, true
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+Modifications copyright (C) 2020 Alex Neo
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package noderesources
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	schedulerv1alpha2 "github.com/alexnjh/epsilon/general_purpose_scheduler/scheduler/config"
+	framework "github.com/alexnjh/epsilon/general_purpose_scheduler/framework/v1alpha1"
+)
+
+// BalancedAllocation is a score plugin that calculates the difference between the fractions of capacity
+// of the resources, and prioritizes the host based on how close the fractions are to each other.
+type BalancedAllocation struct {
+	handle framework.FrameworkHandle
+	resourceAllocationScorer
+}
+
+var _ = framework.ScorePlugin(&BalancedAllocation{})
+
+// BalancedAllocationName is the name of the plugin used in the plugin registry and configurations.
+const BalancedAllocationName = "NodeResourcesBalancedAllocation"
+
+// Name returns name of the plugin. It is used in logs, etc.
+func (ba *BalancedAllocation) Name() string {
+	return BalancedAllocationName
+}
+
+// Score invoked at the score extension point.
+func (ba *BalancedAllocation) Score(ctx context.Context, state *framework.CycleState, pod *v1.Pod, nodeName string) (int64, *framework.Status) {
+	nodeInfo, err := ba.handle.SnapshotSharedLister().NodeInfos().Get(nodeName)
+	if err != nil {
+		return 0, framework.NewStatus(framework.Error, fmt.Sprintf("getting node %q from Snapshot: %v", nodeName, err))
+	}
+
+	// ba.score favors nodes with balanced resource usage rate.
+	// It calculates the weighted standard deviation of the fractions of requested to capacity of the resources
+	// and prioritizes the host based on how close the fractions are to each other.
+	// Detail: (1 - 2 * std(fractions)) * MaxNodeScore
+	// This algorithm is partly inspired from:
+	// "Wei Huang et al. An Energy Efficient Virtual Machine Placement Algorithm with Balanced
+	// Resource Utilization"
+	return ba.score(pod, nodeInfo)
+}
+
+// ScoreExtensions of the Score plugin.
+func (ba *BalancedAllocation) ScoreExtensions() framework.ScoreExtensions {
+	return nil
+}
+
+// NewBalancedAllocation initializes a new plugin and returns it.
+func NewBalancedAllocation(plArgs runtime.Object, h framework.FrameworkHandle) (framework.Plugin, error) {
+	args := &schedulerv1alpha2.NodeResourcesBalancedAllocationArgs{}
+	if err := framework.DecodeInto(plArgs, args); err != nil {
+		return nil, err
+	}
+
+	weights, err := resourcesToWeightMap(args.Resources)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", BalancedAllocationName, err)
+	}
+
+	return &BalancedAllocation{
+		handle: h,
+		resourceAllocationScorer: resourceAllocationScorer{
+			Name:                BalancedAllocationName,
+			scorer:              balancedResourceScorer,
+			resourceToWeightMap: weights,
+		},
+	}, nil
+}
+
+func balancedResourceScorer(requested, allocable resourceToValueMap, weights resourceToWeightMap) int64 {
+	fractions := make(map[v1.ResourceName]float64, len(weights))
+	var mean, weightSum float64
+	for resource, weight := range weights {
+		fraction := fractionOfCapacity(requested[resource], allocable[resource])
+		// if requested >= capacity, the corresponding host should never be preferred.
+		if fraction >= 1 {
+			return 0
+		}
+		fractions[resource] = fraction
+		mean += fraction * float64(weight)
+		weightSum += float64(weight)
+	}
+	mean = mean / weightSum
+
+	var variance float64
+	for resource, weight := range weights {
+		variance += float64(weight) * (fractions[resource] - mean) * (fractions[resource] - mean)
+	}
+	std := math.Sqrt(variance / weightSum)
+
+	// The standard deviation of fractions in [0, 1] is at most 0.5, it is doubled so that a node with
+	// one resource fully used and another one unused gets the lowest score.
+	return int64((1 - 2*std) * float64(framework.MaxNodeScore))
+}
+
+func fractionOfCapacity(requested, capacity int64) float64 {
+	if capacity == 0 {
+		return 1
+	}
+	return float64(requested) / float64(capacity)
+}
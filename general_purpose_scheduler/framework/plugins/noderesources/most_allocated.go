@@ -0,0 +1,115 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+Modifications copyright (C) 2020 Alex Neo
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package noderesources
+
+import (
+	"context"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	schedulerv1alpha2 "github.com/alexnjh/epsilon/general_purpose_scheduler/scheduler/config"
+	framework "github.com/alexnjh/epsilon/general_purpose_scheduler/framework/v1alpha1"
+)
+
+// MostAllocated is a score plugin that favors nodes with high allocation based on requested resources.
+// Packing pods on the busiest nodes leaves the other nodes idle so that the cluster autoscaler can remove them.
+type MostAllocated struct {
+	handle framework.FrameworkHandle
+	resourceAllocationScorer
+}
+
+var _ = framework.ScorePlugin(&MostAllocated{})
+
+// MostAllocatedName is the name of the plugin used in the plugin registry and configurations.
+const MostAllocatedName = "NodeResourcesMostAllocated"
+
+// Name returns name of the plugin. It is used in logs, etc.
+func (ma *MostAllocated) Name() string {
+	return MostAllocatedName
+}
+
+// Score invoked at the Score extension point.
+func (ma *MostAllocated) Score(ctx context.Context, state *framework.CycleState, pod *v1.Pod, nodeName string) (int64, *framework.Status) {
+	nodeInfo, err := ma.handle.SnapshotSharedLister().NodeInfos().Get(nodeName)
+	if err != nil {
+		return 0, framework.NewStatus(framework.Error, fmt.Sprintf("getting node %q from Snapshot: %v", nodeName, err))
+	}
+
+	// ma.score favors nodes with most requested resources.
+	// It calculates the percentage of memory and CPU requested by pods scheduled on the node, and prioritizes
+	// based on the maximum of the average of the fraction of requested to capacity.
+	// Details: (cpu(MaxNodeScore * sum(requested) / capacity) + memory(MaxNodeScore * sum(requested) / capacity)) / weightSum
+	return ma.score(pod, nodeInfo)
+}
+
+// ScoreExtensions of the Score plugin.
+func (ma *MostAllocated) ScoreExtensions() framework.ScoreExtensions {
+	return nil
+}
+
+// NewMostAllocated initializes a new plugin and returns it.
+func NewMostAllocated(plArgs runtime.Object, h framework.FrameworkHandle) (framework.Plugin, error) {
+	args := &schedulerv1alpha2.NodeResourcesMostAllocatedArgs{}
+	if err := framework.DecodeInto(plArgs, args); err != nil {
+		return nil, err
+	}
+
+	weights, err := resourcesToWeightMap(args.Resources)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", MostAllocatedName, err)
+	}
+
+	return &MostAllocated{
+		handle: h,
+		resourceAllocationScorer: resourceAllocationScorer{
+			Name:                MostAllocatedName,
+			scorer:              mostResourceScorer,
+			resourceToWeightMap: weights,
+		},
+	}, nil
+}
+
+func mostResourceScorer(requested, allocable resourceToValueMap, weights resourceToWeightMap) int64 {
+	var nodeScore, weightSum int64
+	for resource, weight := range weights {
+		resourceScore := mostRequestedScore(requested[resource], allocable[resource])
+		nodeScore += resourceScore * weight
+		weightSum += weight
+	}
+	return nodeScore / weightSum
+}
+
+// The used capacity is calculated on a scale of 0-MaxNodeScore (MaxNodeScore is
+// constant with value set to 100).
+// 0 being the lowest priority and 100 being the highest.
+// The more resources are used the higher the score is. This function
+// is almost a reversed version of least_requested_priority.calculateUnusedScore
+// (MaxNodeScore - calculateUnusedScore). The main difference is in rounding.
+// It was added to keep the final formula clean and not to modify the widely
+// used (by users in their default scheduling policies) calculateUsedScore.
+func mostRequestedScore(requested, capacity int64) int64 {
+	if capacity == 0 {
+		return 0
+	}
+	if requested > capacity {
+		return 0
+	}
+
+	return (requested * framework.MaxNodeScore) / capacity
+}
@@ -0,0 +1,164 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+Modifications copyright (C) 2020 Alex Neo
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package noderesources
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	schedulerv1alpha2 "github.com/alexnjh/epsilon/general_purpose_scheduler/scheduler/config"
+	framework "github.com/alexnjh/epsilon/general_purpose_scheduler/framework/v1alpha1"
+)
+
+const gpu v1.ResourceName = "nvidia.com/gpu"
+
+func makeAllocationNode(name, cpu, memory string, gpus int64) *v1.Node {
+	allocatable := v1.ResourceList{
+		v1.ResourceCPU:    resource.MustParse(cpu),
+		v1.ResourceMemory: resource.MustParse(memory),
+	}
+	if gpus != 0 {
+		allocatable[gpu] = *resource.NewQuantity(gpus, resource.DecimalSI)
+	}
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status:     v1.NodeStatus{Capacity: allocatable, Allocatable: allocatable},
+	}
+}
+
+func makeAllocationPod(nodeName, cpu, memory string) *v1.Pod {
+	return &v1.Pod{
+		Spec: v1.PodSpec{
+			NodeName: nodeName,
+			Containers: []v1.Container{{
+				Resources: v1.ResourceRequirements{
+					Requests: v1.ResourceList{
+						v1.ResourceCPU:    resource.MustParse(cpu),
+						v1.ResourceMemory: resource.MustParse(memory),
+					},
+				},
+			}},
+		},
+	}
+}
+
+func makeNodeInfo(node *v1.Node, pods ...*v1.Pod) *framework.NodeInfo {
+	nodeInfo := framework.NewNodeInfo(pods...)
+	nodeInfo.SetNode(node)
+	return nodeInfo
+}
+
+func TestAllocationScores(t *testing.T) {
+	busy := makeNodeInfo(makeAllocationNode("busy", "4", "10Gi", 0), makeAllocationPod("busy", "3", "6Gi"))
+	empty := makeNodeInfo(makeAllocationNode("empty", "4", "10Gi", 0))
+	pod := makeAllocationPod("", "1", "2Gi")
+
+	tests := []struct {
+		name      string
+		scorer    func(requested, allocatable resourceToValueMap, weights resourceToWeightMap) int64
+		wantBusy  int64
+		wantEmpty int64
+	}{
+		{
+			name:      "least allocated spreads the pods",
+			scorer:    leastResourceScorer,
+			wantBusy:  (0 + 20) / 2,
+			wantEmpty: (75 + 80) / 2,
+		},
+		{
+			name:      "most allocated packs the pods",
+			scorer:    mostResourceScorer,
+			wantBusy:  (100 + 80) / 2,
+			wantEmpty: (25 + 20) / 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := resourceAllocationScorer{scorer: tt.scorer, resourceToWeightMap: defaultRequestedRatioResources}
+
+			if score, _ := r.score(pod, busy); score != tt.wantBusy {
+				t.Errorf("expected busy node to score %d, got %d", tt.wantBusy, score)
+			}
+			if score, _ := r.score(pod, empty); score != tt.wantEmpty {
+				t.Errorf("expected empty node to score %d, got %d", tt.wantEmpty, score)
+			}
+		})
+	}
+}
+
+func TestResourceWeights(t *testing.T) {
+	requested := resourceToValueMap{v1.ResourceCPU: 4000, gpu: 1}
+	allocatable := resourceToValueMap{v1.ResourceCPU: 4000, gpu: 4}
+
+	if score := mostResourceScorer(requested, allocatable, resourceToWeightMap{v1.ResourceCPU: 1, gpu: 1}); score != (100+25)/2 {
+		t.Errorf("expected equal weights to score %d, got %d", (100+25)/2, score)
+	}
+	if score := mostResourceScorer(requested, allocatable, resourceToWeightMap{v1.ResourceCPU: 1, gpu: 3}); score != (100+3*25)/4 {
+		t.Errorf("expected weighted gpu to score %d, got %d", (100+3*25)/4, score)
+	}
+
+	// The gpu is left out on nodes without gpus.
+	weights := resourceToWeightMap{v1.ResourceCPU: 1, v1.ResourceMemory: 1, gpu: 5}
+	r := resourceAllocationScorer{scorer: leastResourceScorer, resourceToWeightMap: weights}
+	nodeInfo := makeNodeInfo(makeAllocationNode("cpu-only", "4", "10Gi", 0))
+	if score, _ := r.score(makeAllocationPod("", "1", "2Gi"), nodeInfo); score != (75+80)/2 {
+		t.Errorf("expected node without gpus to score %d, got %d", (75+80)/2, score)
+	}
+
+	if _, err := resourcesToWeightMap([]schedulerv1alpha2.ResourceSpec{{Name: "cpu", Weight: 0}}); err == nil {
+		t.Errorf("expected an error for a zero weight")
+	}
+}
+
+func TestBalancedResourceScorer(t *testing.T) {
+	weights := resourceToWeightMap{v1.ResourceCPU: 1, v1.ResourceMemory: 1}
+	allocatable := resourceToValueMap{v1.ResourceCPU: 4000, v1.ResourceMemory: 4000}
+
+	tests := []struct {
+		name      string
+		requested resourceToValueMap
+		want      int64
+	}{
+		{
+			name:      "same fractions",
+			requested: resourceToValueMap{v1.ResourceCPU: 2000, v1.ResourceMemory: 2000},
+			want:      framework.MaxNodeScore,
+		},
+		{
+			name:      "half the cpu and no memory",
+			requested: resourceToValueMap{v1.ResourceCPU: 2000, v1.ResourceMemory: 0},
+			want:      50,
+		},
+		{
+			name:      "cpu fully requested",
+			requested: resourceToValueMap{v1.ResourceCPU: 4000, v1.ResourceMemory: 2000},
+			want:      0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if score := balancedResourceScorer(tt.requested, allocatable, weights); score != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, score)
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+Modifications copyright (C) 2020 Alex Neo
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodevolumelimits
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	csitrans "k8s.io/csi-translation-lib"
+	framework "github.com/alexnjh/epsilon/general_purpose_scheduler/framework/v1alpha1"
+	utilpointer "k8s.io/utils/pointer"
+)
+
+const (
+	ebsCSIDriverName = "ebs.csi.aws.com"
+	testNamespace    = "default"
+)
+
+// newTestPlugin creates the plugin with listers backed by the given objects.
+func newTestPlugin(t *testing.T, csiNode *storagev1.CSINode, pvs []*v1.PersistentVolume, pvcs []*v1.PersistentVolumeClaim, scs []*storagev1.StorageClass) *CSILimits {
+	factory := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0)
+
+	if csiNode != nil {
+		if err := factory.Storage().V1().CSINodes().Informer().GetIndexer().Add(csiNode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, pv := range pvs {
+		if err := factory.Core().V1().PersistentVolumes().Informer().GetIndexer().Add(pv); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, pvc := range pvcs {
+		if err := factory.Core().V1().PersistentVolumeClaims().Informer().GetIndexer().Add(pvc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, sc := range scs {
+		if err := factory.Storage().V1().StorageClasses().Informer().GetIndexer().Add(sc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return &CSILimits{
+		csiNodeLister:        factory.Storage().V1().CSINodes().Lister(),
+		pvLister:             factory.Core().V1().PersistentVolumes().Lister(),
+		pvcLister:            factory.Core().V1().PersistentVolumeClaims().Lister(),
+		scLister:             factory.Storage().V1().StorageClasses().Lister(),
+		randomVolumeIDPrefix: "prefix",
+		translator:           csitrans.New(),
+	}
+}
+
+func makeCSINode(name string, limit int32) *storagev1.CSINode {
+	return &storagev1.CSINode{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: storagev1.CSINodeSpec{
+			Drivers: []storagev1.CSINodeDriver{{
+				Name:        ebsCSIDriverName,
+				NodeID:      name,
+				Allocatable: &storagev1.VolumeNodeResources{Count: utilpointer.Int32Ptr(limit)},
+			}},
+		},
+	}
+}
+
+// makeVolumes creates count bound CSI volumes and their claims, the claims are named <prefix>-<i>.
+func makeVolumes(prefix string, count int) ([]*v1.PersistentVolume, []*v1.PersistentVolumeClaim) {
+	var pvs []*v1.PersistentVolume
+	var pvcs []*v1.PersistentVolumeClaim
+	for i := 0; i < count; i++ {
+		name := fmt.Sprintf("%s-%d", prefix, i)
+		pvs = append(pvs, &v1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: "pv-" + name},
+			Spec: v1.PersistentVolumeSpec{
+				PersistentVolumeSource: v1.PersistentVolumeSource{
+					CSI: &v1.CSIPersistentVolumeSource{Driver: ebsCSIDriverName, VolumeHandle: "handle-" + name},
+				},
+			},
+		})
+		pvcs = append(pvcs, &v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace},
+			Spec:       v1.PersistentVolumeClaimSpec{VolumeName: "pv-" + name},
+		})
+	}
+	return pvs, pvcs
+}
+
+func makePodWithClaims(name string, claims ...string) *v1.Pod {
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace}}
+	for _, claim := range claims {
+		pod.Spec.Volumes = append(pod.Spec.Volumes, v1.Volume{
+			Name: claim,
+			VolumeSource: v1.VolumeSource{
+				PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: claim},
+			},
+		})
+	}
+	return pod
+}
+
+func TestCSILimits(t *testing.T) {
+	pvs, pvcs := makeVolumes("claim", 4)
+
+	scName := "csi-sc"
+	pendingClaim := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "pending", Namespace: testNamespace},
+		Spec:       v1.PersistentVolumeClaimSpec{StorageClassName: &scName},
+	}
+	storageClass := &storagev1.StorageClass{
+		ObjectMeta:  metav1.ObjectMeta{Name: scName},
+		Provisioner: ebsCSIDriverName,
+	}
+
+	tests := []struct {
+		name         string
+		newPod       *v1.Pod
+		existingPods []*v1.Pod
+		csiNode      *storagev1.CSINode
+		want         framework.Code
+	}{
+		{
+			name:         "fits under the limit",
+			newPod:       makePodWithClaims("new", "claim-2"),
+			existingPods: []*v1.Pod{makePodWithClaims("old", "claim-0", "claim-1")},
+			csiNode:      makeCSINode("node", 3),
+			want:         framework.Success,
+		},
+		{
+			name:         "exceeds the limit",
+			newPod:       makePodWithClaims("new", "claim-2", "claim-3"),
+			existingPods: []*v1.Pod{makePodWithClaims("old", "claim-0", "claim-1")},
+			csiNode:      makeCSINode("node", 3),
+			want:         framework.Unschedulable,
+		},
+		{
+			name:         "volume shared with a pod on the node is counted once",
+			newPod:       makePodWithClaims("new", "claim-1"),
+			existingPods: []*v1.Pod{makePodWithClaims("old", "claim-0", "claim-1")},
+			csiNode:      makeCSINode("node", 2),
+			want:         framework.Success,
+		},
+		{
+			name:         "unbound claim is counted through its storage class",
+			newPod:       makePodWithClaims("new", "pending"),
+			existingPods: []*v1.Pod{makePodWithClaims("old", "claim-0", "claim-1")},
+			csiNode:      makeCSINode("node", 2),
+			want:         framework.Unschedulable,
+		},
+		{
+			name:         "no limits without a CSINode",
+			newPod:       makePodWithClaims("new", "claim-2", "claim-3"),
+			existingPods: []*v1.Pod{makePodWithClaims("old", "claim-0", "claim-1")},
+			want:         framework.Success,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := newTestPlugin(t, test.csiNode, pvs, append(pvcs, pendingClaim), []*storagev1.StorageClass{storageClass})
+
+			node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node"}}
+			nodeInfo := framework.NewNodeInfo(test.existingPods...)
+			nodeInfo.SetNode(node)
+
+			status := p.Filter(context.Background(), nil, test.newPod, nodeInfo)
+			if status.Code() != test.want {
+				t.Errorf("expected %v, got %v: %s", test.want, status.Code(), status.Message())
+			}
+		})
+	}
+}
+
+func TestGetCSIAttachLimitKey(t *testing.T) {
+	if key := getCSIAttachLimitKey(ebsCSIDriverName); key != v1.ResourceAttachableVolumesPrefix+"csi-"+ebsCSIDriverName {
+		t.Errorf("unexpected key %q", key)
+	}
+
+	long := "a-very-long-csi-driver-name.with-a-domain.example.com.and-more"
+	if key := getCSIAttachLimitKey(long); len(key) != len(v1.ResourceAttachableVolumesPrefix)+len("csi-")+23+16 {
+		t.Errorf("expected long driver names to be hashed, got %q", key)
+	}
+}
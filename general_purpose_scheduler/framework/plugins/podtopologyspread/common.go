@@ -0,0 +1,142 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+Modifications copyright (C) 2020 Alex Neo
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podtopologyspread
+
+import (
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog"
+	pluginhelper "github.com/alexnjh/epsilon/general_purpose_scheduler/framework/plugins/helper"
+	framework "github.com/alexnjh/epsilon/general_purpose_scheduler/framework/v1alpha1"
+)
+
+// How long the preFilterState of a pod is reused for the scheduling cycle of the pod.
+const stateTTL = 2 * time.Second
+
+type topologyPair struct {
+	key   string
+	value string
+}
+
+// topologySpreadConstraint is an internal version for v1.TopologySpreadConstraint
+// and where the selector is parsed.
+type topologySpreadConstraint struct {
+	MaxSkew     int32
+	TopologyKey string
+	Selector    labels.Selector
+}
+
+// filterTopologySpreadConstraints returns the constraints of the pod with the given action
+// (DoNotSchedule for filtering, ScheduleAnyway for scoring) with their selectors parsed.
+func filterTopologySpreadConstraints(constraints []v1.TopologySpreadConstraint, action v1.UnsatisfiableConstraintAction) ([]topologySpreadConstraint, error) {
+	var result []topologySpreadConstraint
+	for _, c := range constraints {
+		if c.WhenUnsatisfiable == action {
+			selector, err := metav1.LabelSelectorAsSelector(c.LabelSelector)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, topologySpreadConstraint{
+				MaxSkew:     c.MaxSkew,
+				TopologyKey: c.TopologyKey,
+				Selector:    selector,
+			})
+		}
+	}
+	return result, nil
+}
+
+// nodeLabelsMatchSpreadConstraints checks if ALL topology keys in spread Constraints are present in node labels.
+func nodeLabelsMatchSpreadConstraints(nodeLabels map[string]string, constraints []topologySpreadConstraint) bool {
+	for _, c := range constraints {
+		if _, ok := nodeLabels[c.TopologyKey]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// nodeQualifies checks if the pods on the node are counted for the constraints of the pod. Nodes
+// the pod cannot be placed on because of its node selector or affinity are not counted.
+func nodeQualifies(pod *v1.Pod, node *v1.Node, constraints []topologySpreadConstraint) bool {
+	return pluginhelper.PodMatchesNodeSelectorAndAffinityTerms(pod, node) && nodeLabelsMatchSpreadConstraints(node.Labels, constraints)
+}
+
+// countPodsMatchSelector counts the pods in the namespace matching the selector, terminating pods are ignored.
+func countPodsMatchSelector(pods []*v1.Pod, selector labels.Selector, ns string) int32 {
+	var count int32
+	for _, p := range pods {
+		// Bypass terminating Pod (see #87621).
+		if p.DeletionTimestamp != nil || p.Namespace != ns {
+			continue
+		}
+		if selector.Matches(labels.Set(p.Labels)) {
+			count++
+		}
+	}
+	return count
+}
+
+// livePods returns the pods in the namespace of the pod that are bound to a node according to the
+// pod informer, keyed by node name. Pods bound by other scheduler replicas may not be in the snapshot
+// yet. Returns nil when the pods cannot be listed, in which case only the snapshot is used.
+func (pl *PodTopologySpread) livePods(pod *v1.Pod) map[string][]*v1.Pod {
+	if pl.podLister == nil {
+		return nil
+	}
+
+	list, err := pl.podLister.Pods(pod.Namespace).List(labels.Everything())
+	if err != nil {
+		klog.Errorf("Unable to list pods for topology spreading of %s/%s: %v", pod.Namespace, pod.Name, err)
+		return nil
+	}
+
+	pods := make(map[string][]*v1.Pod)
+	for _, p := range list {
+		if len(p.Spec.NodeName) != 0 {
+			pods[p.Spec.NodeName] = append(pods[p.Spec.NodeName], p)
+		}
+	}
+	return pods
+}
+
+// podKey identifies the scheduling cycles of a pod in the cached states.
+func podKey(pod *v1.Pod) string {
+	return string(pod.UID) + "/" + pod.Namespace + "/" + pod.Name
+}
+
+// podsOnNode merges the pods of the node in the snapshot with the pods bound to the node according
+// to the pod informer.
+func podsOnNode(nodeInfo *framework.NodeInfo, live map[string][]*v1.Pod) []*v1.Pod {
+	pods := make([]*v1.Pod, 0, len(nodeInfo.Pods))
+	seen := make(map[types.UID]bool, len(nodeInfo.Pods))
+	for _, p := range nodeInfo.Pods {
+		pods = append(pods, p.Pod)
+		seen[p.Pod.UID] = true
+	}
+	for _, p := range live[nodeInfo.Node().Name] {
+		if !seen[p.UID] {
+			pods = append(pods, p)
+		}
+	}
+	return pods
+}
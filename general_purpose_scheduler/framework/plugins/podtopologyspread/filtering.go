@@ -0,0 +1,213 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+Modifications copyright (C) 2020 Alex Neo
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podtopologyspread
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	framework "github.com/alexnjh/epsilon/general_purpose_scheduler/framework/v1alpha1"
+)
+
+// preFilterStateKey is the key in CycleState to PodTopologySpread pre-computed data for Filtering.
+// Using the name of the plugin will likely help us avoid collisions with other plugins.
+const preFilterStateKey = "PreFilter" + Name
+
+// preFilterState computed at PreFilter and used at Filter.
+type preFilterState struct {
+	Constraints []topologySpreadConstraint
+	// Minimum number of matching pods in a domain of each topology key.
+	TpKeyToMinMatchNum map[string]int32
+	// Number of matching pods in each domain.
+	TpPairToMatchNum map[topologyPair]int32
+}
+
+// Clone makes a copy of the given state.
+func (s *preFilterState) Clone() framework.StateData {
+	if s == nil {
+		return nil
+	}
+	copy := preFilterState{
+		// Constraints are shared because they don't change.
+		Constraints:        s.Constraints,
+		TpKeyToMinMatchNum: make(map[string]int32, len(s.TpKeyToMinMatchNum)),
+		TpPairToMatchNum:   make(map[topologyPair]int32, len(s.TpPairToMatchNum)),
+	}
+	for key, num := range s.TpKeyToMinMatchNum {
+		copy.TpKeyToMinMatchNum[key] = num
+	}
+	for pair, num := range s.TpPairToMatchNum {
+		copy.TpPairToMatchNum[pair] = num
+	}
+	return &copy
+}
+
+// stateEntry holds the preFilterState computed for a pod.
+type stateEntry struct {
+	computed time.Time
+	state    *preFilterState
+}
+
+// PreFilter invoked at the prefilter extension point.
+func (pl *PodTopologySpread) PreFilter(ctx context.Context, cycleState *framework.CycleState, pod *v1.Pod) *framework.Status {
+	s, err := pl.cachedPreFilterState(pod)
+	if err != nil {
+		return framework.NewStatus(framework.Error, err.Error())
+	}
+	cycleState.Write(preFilterStateKey, s)
+	return nil
+}
+
+// cachedPreFilterState returns the preFilterState of the pod. PreFilter runs once for every node,
+// the state is computed for the first node and reused within stateTTL. The state is only read
+// by Filter so it is shared by the cycle states of the nodes.
+func (pl *PodTopologySpread) cachedPreFilterState(pod *v1.Pod) (*preFilterState, error) {
+	key := podKey(pod)
+	now := time.Now()
+
+	pl.stateMu.Lock()
+	defer pl.stateMu.Unlock()
+
+	for k, e := range pl.states {
+		if now.Sub(e.computed) > stateTTL {
+			delete(pl.states, k)
+		}
+	}
+
+	if e, ok := pl.states[key]; ok {
+		return e.state, nil
+	}
+
+	s, err := pl.calPreFilterState(pod)
+	if err != nil {
+		return nil, err
+	}
+	pl.states[key] = &stateEntry{computed: now, state: s}
+	return s, nil
+}
+
+// calPreFilterState counts the pods matching each hard constraint in every topology domain.
+func (pl *PodTopologySpread) calPreFilterState(pod *v1.Pod) (*preFilterState, error) {
+	constraints, err := filterTopologySpreadConstraints(pod.Spec.TopologySpreadConstraints, v1.DoNotSchedule)
+	if err != nil {
+		return nil, fmt.Errorf("obtaining pod's hard topology spread constraints: %v", err)
+	}
+
+	s := preFilterState{
+		Constraints:        constraints,
+		TpKeyToMinMatchNum: make(map[string]int32, len(constraints)),
+		TpPairToMatchNum:   make(map[topologyPair]int32),
+	}
+	if len(constraints) == 0 {
+		return &s, nil
+	}
+
+	allNodes, err := pl.sharedLister.NodeInfos().List()
+	if err != nil {
+		return nil, fmt.Errorf("listing NodeInfos: %v", err)
+	}
+
+	live := pl.livePods(pod)
+
+	for _, nodeInfo := range allNodes {
+		node := nodeInfo.Node()
+		if node == nil || !nodeQualifies(pod, node, constraints) {
+			continue
+		}
+		pods := podsOnNode(nodeInfo, live)
+		for _, c := range constraints {
+			pair := topologyPair{key: c.TopologyKey, value: node.Labels[c.TopologyKey]}
+			s.TpPairToMatchNum[pair] += countPodsMatchSelector(pods, c.Selector, pod.Namespace)
+		}
+	}
+
+	for _, c := range constraints {
+		min := int32(math.MaxInt32)
+		for pair, num := range s.TpPairToMatchNum {
+			if pair.key == c.TopologyKey && num < min {
+				min = num
+			}
+		}
+		if min == math.MaxInt32 {
+			min = 0
+		}
+		s.TpKeyToMinMatchNum[c.TopologyKey] = min
+	}
+
+	return &s, nil
+}
+
+func getPreFilterState(cycleState *framework.CycleState) (*preFilterState, error) {
+	c, err := cycleState.Read(preFilterStateKey)
+	if err != nil {
+		// preFilterState doesn't exist, likely PreFilter wasn't invoked.
+		return nil, fmt.Errorf("error reading %q from cycleState: %v", preFilterStateKey, err)
+	}
+
+	s, ok := c.(*preFilterState)
+	if !ok {
+		return nil, fmt.Errorf("%+v  convert to podtopologyspread.preFilterState error", c)
+	}
+	return s, nil
+}
+
+// Filter invoked at the filter extension point.
+func (pl *PodTopologySpread) Filter(ctx context.Context, cycleState *framework.CycleState, pod *v1.Pod, nodeInfo *framework.NodeInfo) *framework.Status {
+	node := nodeInfo.Node()
+	if node == nil {
+		return framework.NewStatus(framework.Error, "node not found")
+	}
+
+	s, err := getPreFilterState(cycleState)
+	if err != nil {
+		return framework.NewStatus(framework.Error, err.Error())
+	}
+
+	// However, "empty" preFilterState is legit which tolerates every toSchedule Pod.
+	if len(s.Constraints) == 0 {
+		return nil
+	}
+
+	podLabelSet := labels.Set(pod.Labels)
+	for _, c := range s.Constraints {
+		tpKey := c.TopologyKey
+		tpVal, ok := node.Labels[c.TopologyKey]
+		if !ok {
+			return framework.NewStatus(framework.UnschedulableAndUnresolvable, ErrReasonNodeLabelNotMatch)
+		}
+
+		selfMatchNum := int32(0)
+		if c.Selector.Matches(podLabelSet) {
+			selfMatchNum = 1
+		}
+
+		matchNum := s.TpPairToMatchNum[topologyPair{key: tpKey, value: tpVal}]
+
+		// The skew is the difference with the domain with the least matching pods
+		skew := matchNum + selfMatchNum - s.TpKeyToMinMatchNum[tpKey]
+		if skew > c.MaxSkew {
+			return framework.NewStatus(framework.Unschedulable, ErrReasonConstraintsNotMatch)
+		}
+	}
+
+	return nil
+}
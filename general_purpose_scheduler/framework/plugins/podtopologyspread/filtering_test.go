@@ -0,0 +1,210 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+Modifications copyright (C) 2020 Alex Neo
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podtopologyspread
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	toolscache "k8s.io/client-go/tools/cache"
+	framework "github.com/alexnjh/epsilon/general_purpose_scheduler/framework/v1alpha1"
+	"github.com/alexnjh/epsilon/general_purpose_scheduler/internal/cache"
+)
+
+const (
+	zoneKey     = "zone"
+	hostnameKey = "kubernetes.io/hostname"
+)
+
+var fooSelector = &metav1.LabelSelector{
+	MatchLabels: map[string]string{"foo": "bar"},
+}
+
+func makeNode(name, zone string) *v1.Node {
+	labels := map[string]string{hostnameKey: name}
+	if len(zone) != 0 {
+		labels[zoneKey] = zone
+	}
+	return &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+}
+
+func makePod(name, nodeName string, constraints ...v1.TopologySpreadConstraint) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			UID:       types.UID(name),
+			Labels:    map[string]string{"foo": "bar"},
+		},
+		Spec: v1.PodSpec{
+			NodeName:                  nodeName,
+			TopologySpreadConstraints: constraints,
+		},
+	}
+}
+
+func constraint(maxSkew int32, key string, action v1.UnsatisfiableConstraintAction) v1.TopologySpreadConstraint {
+	return v1.TopologySpreadConstraint{
+		MaxSkew:           maxSkew,
+		TopologyKey:       key,
+		WhenUnsatisfiable: action,
+		LabelSelector:     fooSelector,
+	}
+}
+
+func newPlugin(pods, livePods []*v1.Pod, nodes []*v1.Node) *PodTopologySpread {
+	indexer := toolscache.NewIndexer(toolscache.MetaNamespaceKeyFunc, toolscache.Indexers{toolscache.NamespaceIndex: toolscache.MetaNamespaceIndexFunc})
+	for _, p := range livePods {
+		indexer.Add(p)
+	}
+	return &PodTopologySpread{
+		sharedLister: cache.NewSnapshot(pods, nodes),
+		podLister:    corelisters.NewPodLister(indexer),
+		states:       make(map[string]*stateEntry),
+	}
+}
+
+func TestSingleConstraint(t *testing.T) {
+	nodes := []*v1.Node{
+		makeNode("node-a", "zone1"),
+		makeNode("node-b", "zone1"),
+		makeNode("node-x", "zone2"),
+		makeNode("node-y", ""),
+	}
+
+	tests := []struct {
+		name     string
+		pod      *v1.Pod
+		pods     []*v1.Pod
+		livePods []*v1.Pod
+		want     map[string]framework.Code
+	}{
+		{
+			name: "no constraints",
+			pod:  makePod("p", ""),
+			pods: []*v1.Pod{makePod("p-a1", "node-a")},
+			want: map[string]framework.Code{
+				"node-a": framework.Success,
+				"node-b": framework.Success,
+				"node-x": framework.Success,
+				"node-y": framework.Success,
+			},
+		},
+		{
+			name: "zone key, the zone with more matching pods is skewed",
+			pod:  makePod("p", "", constraint(1, zoneKey, v1.DoNotSchedule)),
+			pods: []*v1.Pod{makePod("p-a1", "node-a"), makePod("p-b1", "node-b")},
+			want: map[string]framework.Code{
+				"node-a": framework.Unschedulable,
+				"node-b": framework.Unschedulable,
+				"node-x": framework.Success,
+				"node-y": framework.UnschedulableAndUnresolvable,
+			},
+		},
+		{
+			name: "hostname key",
+			pod:  makePod("p", "", constraint(1, hostnameKey, v1.DoNotSchedule)),
+			pods: []*v1.Pod{makePod("p-a1", "node-a"), makePod("p-b1", "node-b"), makePod("p-y1", "node-y")},
+			want: map[string]framework.Code{
+				"node-a": framework.Unschedulable,
+				"node-b": framework.Unschedulable,
+				"node-x": framework.Success,
+				"node-y": framework.Unschedulable,
+			},
+		},
+		{
+			name: "pods bound by another replica are counted",
+			pod:  makePod("p", "", constraint(1, zoneKey, v1.DoNotSchedule)),
+			pods: []*v1.Pod{makePod("p-a1", "node-a")},
+			livePods: []*v1.Pod{
+				makePod("p-a1", "node-a"),
+				makePod("p-x1", "node-x"),
+				makePod("p-x2", "node-x"),
+			},
+			want: map[string]framework.Code{
+				"node-a": framework.Success,
+				"node-b": framework.Success,
+				"node-x": framework.Unschedulable,
+				"node-y": framework.UnschedulableAndUnresolvable,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newPlugin(tt.pods, tt.livePods, nodes)
+
+			state := framework.NewCycleState()
+			if s := p.PreFilter(context.Background(), state, tt.pod); !s.IsSuccess() {
+				t.Fatal(s.Message())
+			}
+
+			for _, node := range nodes {
+				nodeInfo, err := p.sharedLister.NodeInfos().Get(node.Name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				status := p.Filter(context.Background(), state, tt.pod, nodeInfo)
+				if status.Code() != tt.want[node.Name] {
+					t.Errorf("node %s: expected %v, got %v", node.Name, tt.want[node.Name], status.Code())
+				}
+			}
+		})
+	}
+}
+
+func TestPreFilterStateSharedByNodes(t *testing.T) {
+	nodes := []*v1.Node{makeNode("node-a", "zone1"), makeNode("node-x", "zone2")}
+	pod := makePod("p", "", constraint(1, zoneKey, v1.DoNotSchedule))
+	p := newPlugin([]*v1.Pod{makePod("p-a1", "node-a")}, nil, nodes)
+
+	// PreFilter runs once for every node with a new cycle state
+	states := make([]*preFilterState, 0, len(nodes))
+	for range nodes {
+		cycleState := framework.NewCycleState()
+		if s := p.PreFilter(context.Background(), cycleState, pod); !s.IsSuccess() {
+			t.Fatal(s.Message())
+		}
+		s, err := getPreFilterState(cycleState)
+		if err != nil {
+			t.Fatal(err)
+		}
+		states = append(states, s)
+	}
+
+	if states[0] != states[1] {
+		t.Error("expected the preFilterState to be computed once for the pod")
+	}
+	if num := states[0].TpPairToMatchNum[topologyPair{key: zoneKey, value: "zone1"}]; num != 1 {
+		t.Errorf("expected 1 matching pod in zone1, got %d", num)
+	}
+
+	// Another pod gets its own state
+	other := makePod("q", "", constraint(1, zoneKey, v1.DoNotSchedule))
+	cycleState := framework.NewCycleState()
+	if s := p.PreFilter(context.Background(), cycleState, other); !s.IsSuccess() {
+		t.Fatal(s.Message())
+	}
+	if s, _ := getPreFilterState(cycleState); s == states[0] {
+		t.Error("expected a new preFilterState for another pod")
+	}
+}
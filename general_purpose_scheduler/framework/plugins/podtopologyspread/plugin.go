@@ -0,0 +1,93 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+Modifications copyright (C) 2020 Alex Neo
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podtopologyspread
+
+import (
+	"fmt"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	schedulerv1alpha2 "github.com/alexnjh/epsilon/general_purpose_scheduler/scheduler/config"
+	framework "github.com/alexnjh/epsilon/general_purpose_scheduler/framework/v1alpha1"
+)
+
+const (
+	// Name is the name of the plugin used in the plugin registry and configurations.
+	Name = "PodTopologySpread"
+
+	// ErrReasonConstraintsNotMatch is used for PodTopologySpread filter error.
+	ErrReasonConstraintsNotMatch = "node(s) didn't match pod topology spread constraints"
+	// ErrReasonNodeLabelNotMatch is used when the node doesn't hold the required label.
+	ErrReasonNodeLabelNotMatch = ErrReasonConstraintsNotMatch + " (missing required label)"
+)
+
+var _ framework.PreFilterPlugin = &PodTopologySpread{}
+var _ framework.FilterPlugin = &PodTopologySpread{}
+var _ framework.PreScorePlugin = &PodTopologySpread{}
+var _ framework.ScorePlugin = &PodTopologySpread{}
+
+// PodTopologySpread is a plugin that ensures pod's topologySpreadConstraints is satisfied.
+//
+// Several scheduler replicas consume the same queue and bind pods without waiting for each
+// other, so the pods counted in each topology domain are the union of the snapshot of this
+// replica and the pods already bound according to the pod informer (see livePods).
+type PodTopologySpread struct {
+	args         schedulerv1alpha2.PodTopologySpreadArgs
+	sharedLister framework.SharedLister
+	podLister    corelisters.PodLister
+
+	// preFilterState of the pods being scheduled. PreFilter runs once for every node so the state
+	// is computed once and shared by the scheduling cycle of a pod. The lock is held while the
+	// state is computed so that the nodes filtered in parallel wait for the first one.
+	stateMu sync.Mutex
+	states  map[string]*stateEntry
+}
+
+// Name returns name of the plugin. It is used in logs, etc.
+func (pl *PodTopologySpread) Name() string {
+	return Name
+}
+
+// BuildArgs returns the args that were used to build the plugin.
+func (pl *PodTopologySpread) BuildArgs() interface{} {
+	return pl.args
+}
+
+// New initializes a new plugin and returns it.
+func New(plArgs runtime.Object, h framework.FrameworkHandle) (framework.Plugin, error) {
+	if h.SnapshotSharedLister() == nil {
+		return nil, fmt.Errorf("SnapshotSharedlister is nil")
+	}
+	pl := &PodTopologySpread{
+		sharedLister: h.SnapshotSharedLister(),
+		states:       make(map[string]*stateEntry),
+	}
+	if f := h.SharedInformerFactory(); f != nil {
+		pl.podLister = f.Core().V1().Pods().Lister()
+	}
+	if err := framework.DecodeInto(plArgs, &pl.args); err != nil {
+		return nil, err
+	}
+	if len(pl.args.DefaultConstraints) != 0 {
+		// Default constraints need the selectors of the services and controllers of the pod,
+		// which are not available to the plugins.
+		return nil, fmt.Errorf("default constraints are not supported by %s", Name)
+	}
+	return pl, nil
+}
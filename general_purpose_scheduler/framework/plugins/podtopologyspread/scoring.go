@@ -0,0 +1,191 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+Modifications copyright (C) 2020 Alex Neo
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podtopologyspread
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	v1 "k8s.io/api/core/v1"
+	framework "github.com/alexnjh/epsilon/general_purpose_scheduler/framework/v1alpha1"
+)
+
+// preScoreStateKey is the key in CycleState to PodTopologySpread pre-computed data for Scoring.
+const preScoreStateKey = "PreScore" + Name
+
+// preScoreState computed at PreScore and used at Score.
+// The framework does not run NormalizeScore so the scores are normalized in PreScore.
+type preScoreState struct {
+	// Normalized score of each filtered node.
+	NodeNameToScore map[string]int64
+}
+
+// Clone implements the mandatory Clone interface. We don't really copy the data since
+// there is no need for that.
+func (s *preScoreState) Clone() framework.StateData {
+	return s
+}
+
+// PreScore counts the pods matching each soft constraint in the topology domains of the filtered
+// nodes and computes the score of every filtered node.
+func (pl *PodTopologySpread) PreScore(
+	ctx context.Context,
+	cycleState *framework.CycleState,
+	pod *v1.Pod,
+	filteredNodes []*v1.Node,
+) *framework.Status {
+	constraints, err := filterTopologySpreadConstraints(pod.Spec.TopologySpreadConstraints, v1.ScheduleAnyway)
+	if err != nil {
+		return framework.NewStatus(framework.Error, fmt.Sprintf("obtaining pod's soft topology spread constraints: %v", err))
+	}
+
+	state := &preScoreState{
+		NodeNameToScore: make(map[string]int64, len(filteredNodes)),
+	}
+	cycleState.Write(preScoreStateKey, state)
+
+	if len(constraints) == 0 || len(filteredNodes) == 0 {
+		return nil
+	}
+
+	allNodes, err := pl.sharedLister.NodeInfos().List()
+	if err != nil {
+		return framework.NewStatus(framework.Error, fmt.Sprintf("listing NodeInfos: %v", err))
+	}
+
+	// Only the domains of the filtered nodes are counted.
+	tpPairToMatchNum := make(map[topologyPair]int32)
+	topologySize := make(map[string]int, len(constraints))
+	for _, node := range filteredNodes {
+		if !nodeLabelsMatchSpreadConstraints(node.Labels, constraints) {
+			continue
+		}
+		for _, c := range constraints {
+			pair := topologyPair{key: c.TopologyKey, value: node.Labels[c.TopologyKey]}
+			if _, ok := tpPairToMatchNum[pair]; !ok {
+				tpPairToMatchNum[pair] = 0
+				topologySize[c.TopologyKey]++
+			}
+		}
+	}
+
+	live := pl.livePods(pod)
+
+	for _, nodeInfo := range allNodes {
+		node := nodeInfo.Node()
+		if node == nil || !nodeQualifies(pod, node, constraints) {
+			continue
+		}
+		pods := podsOnNode(nodeInfo, live)
+		for _, c := range constraints {
+			pair := topologyPair{key: c.TopologyKey, value: node.Labels[c.TopologyKey]}
+			if _, ok := tpPairToMatchNum[pair]; ok {
+				tpPairToMatchNum[pair] += countPodsMatchSelector(pods, c.Selector, pod.Namespace)
+			}
+		}
+	}
+
+	// Nodes missing a topology key get the lowest score.
+	rawScores := make(map[string]float64, len(filteredNodes))
+	for _, node := range filteredNodes {
+		if !nodeLabelsMatchSpreadConstraints(node.Labels, constraints) {
+			continue
+		}
+		var score float64
+		for _, c := range constraints {
+			cnt := tpPairToMatchNum[topologyPair{key: c.TopologyKey, value: node.Labels[c.TopologyKey]}]
+			score += scoreForCount(cnt, c.MaxSkew, topologyNormalizingWeight(topologySize[c.TopologyKey]))
+		}
+		rawScores[node.Name] = score
+	}
+
+	if len(rawScores) == 0 {
+		return nil
+	}
+
+	minScore, maxScore := math.MaxFloat64, float64(0)
+	for _, score := range rawScores {
+		if score < minScore {
+			minScore = score
+		}
+		if score > maxScore {
+			maxScore = score
+		}
+	}
+
+	// The fewer matching pods in the domains of a node the higher its score.
+	for name, score := range rawScores {
+		if maxScore == 0 {
+			state.NodeNameToScore[name] = framework.MaxNodeScore
+			continue
+		}
+		state.NodeNameToScore[name] = int64(float64(framework.MaxNodeScore) * (maxScore + minScore - score) / maxScore)
+	}
+
+	return nil
+}
+
+func getPreScoreState(cycleState *framework.CycleState) (*preScoreState, error) {
+	c, err := cycleState.Read(preScoreStateKey)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %q from cycleState: %v", preScoreStateKey, err)
+	}
+
+	s, ok := c.(*preScoreState)
+	if !ok {
+		return nil, fmt.Errorf("%+v  convert to podtopologyspread.preScoreState error", c)
+	}
+	return s, nil
+}
+
+// Score invoked at the Score extension point.
+// The score of the nodes is computed in PreScore as it depends on all the filtered nodes.
+func (pl *PodTopologySpread) Score(ctx context.Context, cycleState *framework.CycleState, pod *v1.Pod, nodeName string) (int64, *framework.Status) {
+	s, err := getPreScoreState(cycleState)
+	if err != nil {
+		return 0, framework.NewStatus(framework.Error, err.Error())
+	}
+	return s.NodeNameToScore[nodeName], nil
+}
+
+// topologyNormalizingWeight calculates the weight for the topology, based on
+// the number of values that exist for a topology.
+// Since <size> is at least 1 (all nodes that passed the Filters are in the
+// same topology), and k8s supports 5k nodes, the result is in the interval
+// <1.09, 8.52>.
+//
+// Note: <size> could also be zero when no nodes have the required topologies,
+// however we don't care about topology weight in this case as we return a 0
+// score for all nodes.
+func topologyNormalizingWeight(size int) float64 {
+	return math.Log(float64(size + 2))
+}
+
+// scoreForCount calculates the score based on number of matching pods in a
+// topology domain, the constraint's maxSkew and the topology weight.
+// `maxSkew-1` is added to the score so that differences between topology
+// domains get watered down, controlling the tolerance of the score to skews.
+func scoreForCount(cnt int32, maxSkew int32, tpWeight float64) float64 {
+	return float64(cnt)*tpWeight + float64(maxSkew-1)
+}
+
+// ScoreExtensions of the Score plugin.
+func (pl *PodTopologySpread) ScoreExtensions() framework.ScoreExtensions {
+	return nil
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+Modifications copyright (C) 2020 Alex Neo
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podtopologyspread
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	framework "github.com/alexnjh/epsilon/general_purpose_scheduler/framework/v1alpha1"
+)
+
+func scoreNodes(t *testing.T, p *PodTopologySpread, pod *v1.Pod, nodes []*v1.Node) map[string]int64 {
+	state := framework.NewCycleState()
+	if s := p.PreScore(context.Background(), state, pod, nodes); !s.IsSuccess() {
+		t.Fatal(s.Message())
+	}
+	scores := make(map[string]int64, len(nodes))
+	for _, node := range nodes {
+		score, s := p.Score(context.Background(), state, pod, node.Name)
+		if !s.IsSuccess() {
+			t.Fatal(s.Message())
+		}
+		scores[node.Name] = score
+	}
+	return scores
+}
+
+func TestScoreHostname(t *testing.T) {
+	nodes := []*v1.Node{
+		makeNode("node-a", "zone1"),
+		makeNode("node-b", "zone1"),
+		makeNode("node-x", "zone2"),
+	}
+	pods := []*v1.Pod{
+		makePod("p-a1", "node-a"),
+		makePod("p-a2", "node-a"),
+		makePod("p-b1", "node-b"),
+	}
+	pod := makePod("p", "", constraint(1, hostnameKey, v1.ScheduleAnyway))
+
+	scores := scoreNodes(t, newPlugin(pods, nil, nodes), pod, nodes)
+
+	if scores["node-x"] != framework.MaxNodeScore {
+		t.Errorf("expected node-x to score %d, got %d", framework.MaxNodeScore, scores["node-x"])
+	}
+	if scores["node-a"] != 0 {
+		t.Errorf("expected node-a to score 0, got %d", scores["node-a"])
+	}
+	if scores["node-b"] <= scores["node-a"] || scores["node-b"] >= scores["node-x"] {
+		t.Errorf("expected node-b to score between node-a and node-x, got %v", scores)
+	}
+}
+
+func TestScoreZoneWithLivePods(t *testing.T) {
+	nodes := []*v1.Node{
+		makeNode("node-a", "zone1"),
+		makeNode("node-x", "zone2"),
+		makeNode("node-y", ""),
+	}
+	// The snapshot of this replica has not seen the pods bound to zone2 yet.
+	livePods := []*v1.Pod{
+		makePod("p-x1", "node-x"),
+		makePod("p-x2", "node-x"),
+	}
+	pod := makePod("p", "", constraint(1, zoneKey, v1.ScheduleAnyway))
+
+	scores := scoreNodes(t, newPlugin(nil, livePods, nodes), pod, nodes)
+
+	if scores["node-a"] != framework.MaxNodeScore {
+		t.Errorf("expected node-a to score %d, got %d", framework.MaxNodeScore, scores["node-a"])
+	}
+	if scores["node-x"] != 0 {
+		t.Errorf("expected node-x to score 0, got %d", scores["node-x"])
+	}
+	if scores["node-y"] != 0 {
+		t.Errorf("expected node-y without the zone label to score 0, got %d", scores["node-y"])
+	}
+}
+
+func TestScoreNoConstraints(t *testing.T) {
+	nodes := []*v1.Node{makeNode("node-a", "zone1"), makeNode("node-x", "zone2")}
+	pod := makePod("p", "", constraint(1, zoneKey, v1.DoNotSchedule))
+
+	scores := scoreNodes(t, newPlugin([]*v1.Pod{makePod("p-a1", "node-a")}, nil, nodes), pod, nodes)
+
+	for name, score := range scores {
+		if score != 0 {
+			t.Errorf("expected node %s to score 0, got %d", name, score)
+		}
+	}
+}
@@ -30,6 +30,9 @@ import (
   "github.com/alexnjh/epsilon/general_purpose_scheduler/framework/plugins/volumerestrictions"
   "github.com/alexnjh/epsilon/general_purpose_scheduler/framework/plugins/resourcepriority"
   "github.com/alexnjh/epsilon/general_purpose_scheduler/framework/plugins/repeatpriority"
+  "github.com/alexnjh/epsilon/general_purpose_scheduler/framework/plugins/podtopologyspread"
+  "github.com/alexnjh/epsilon/general_purpose_scheduler/framework/plugins/nodevolumelimits"
+  "github.com/alexnjh/epsilon/general_purpose_scheduler/framework/plugins/loadaware"
 	framework "github.com/alexnjh/epsilon/general_purpose_scheduler/framework/v1alpha1"
 )
 
@@ -45,11 +48,17 @@ func NewInTreeRegistry() framework.Registry {
     nodeports.Name:                             nodeports.New,
     nodeunschedulable.Name:                     nodeunschedulable.New,
     noderesources.FitName:                      noderesources.NewFit,
+    noderesources.LeastAllocatedName:           noderesources.NewLeastAllocated,
+    noderesources.MostAllocatedName:            noderesources.NewMostAllocated,
+    noderesources.BalancedAllocationName:       noderesources.NewBalancedAllocation,
     interpodaffinity.Name:                      interpodaffinity.New,
     imagelocality.Name:                         imagelocality.New,
     volumebinding.Name:                         volumebinding.New,
     volumerestrictions.Name:                    volumerestrictions.New,
     resourcepriority.Name:                      resourcepriority.New,
     repeatpriority.Name:                        repeatpriority.New,
+    podtopologyspread.Name:                     podtopologyspread.New,
+    nodevolumelimits.CSIName:                   nodevolumelimits.NewCSI,
+    loadaware.Name:                             loadaware.New,
 	}
 }
@@ -35,7 +35,7 @@ const (
   Name = "RepeatPriority"
 )
 
-// RepeatPriority is a score plugin that favors nodes that are allocated the least pods by the scheduler
+// RepeatPriority is a score plugin that favors nodes that were recently bound the least pods by the schedulers
 type RepeatPriority struct {
 	handle framework.FrameworkHandle
 }
@@ -54,7 +54,7 @@ func (pl *RepeatPriority) Score(ctx context.Context, state *framework.CycleState
   rnode := pl.handle.GetNodeUsageFactor(nodeName)
   rhigh := pl.handle.GetHighestUsageFactor()
 
-  nodeRF := float64(rnode)/float64(rhigh)
+  nodeRF := rnode/rhigh
   score := math.Exp(-5*float64(nodeRF))
   return int64(score*100), nil
 }
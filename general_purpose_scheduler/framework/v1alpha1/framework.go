@@ -19,6 +19,8 @@ package v1alpha1
 
 import (
   "fmt"
+  "math"
+  "time"
   "k8s.io/klog"
   "reflect"
   "k8s.io/apimachinery/pkg/util/sets"
@@ -27,17 +29,25 @@ import (
   "github.com/alexnjh/epsilon/general_purpose_scheduler/k8s.io/kubernetes/pkg/controller/volume/scheduling"
 
   v1 "k8s.io/api/core/v1"
+  "k8s.io/apimachinery/pkg/runtime"
   clientset "k8s.io/client-go/kubernetes"
+  "k8s.io/client-go/informers"
   config "github.com/alexnjh/epsilon/general_purpose_scheduler/scheduler/config"
+  "github.com/alexnjh/epsilon/general_purpose_scheduler/scheduler/metrics"
+)
+
+const (
+  // Extension point labels of the metrics
+  filter = "Filter"
+  score  = "Score"
 )
 
 
 // framework is the component responsible for initializing and running scheduler plugins.
 type framework struct {
-  highestRepeatFactor   int
 	registry              Registry
 	pluginNameToWeightMap map[string]int
-  nodeUsageMap          map[string]int
+  nodeUsage             *nodeUsage
   preFilterPlugins      []PreFilterPlugin
 	filterPlugins         []FilterPlugin
   preScorePlugins       []PreScorePlugin
@@ -45,32 +55,42 @@ type framework struct {
 	clientSet             clientset.Interface
   snapshotSharedLister  SharedLister
   volumeBinder          scheduling.SchedulerVolumeBinder
+  informerFactory       informers.SharedInformerFactory
 
 
 }
 
-// Creates a new framework struct
+// Creates a new framework struct. The plugins in pluginConfig are initialized with their args and
+// resourceScorers are the plugins used to score the resources of the nodes (ResourcePriority by default).
 func NewFramework(
   r Registry,
   client clientset.Interface,
   sharedLister SharedLister,
-  volumeBinder scheduling.SchedulerVolumeBinder) (framework,error){
+  volumeBinder scheduling.SchedulerVolumeBinder,
+  informerFactory informers.SharedInformerFactory,
+  pluginConfig []config.PluginConfig,
+  resourceScorers []string) (framework,error){
 
   f := &framework{
-    highestRepeatFactor:   1,
     registry:              r,
     pluginNameToWeightMap: make(map[string]int),
-    nodeUsageMap:          make(map[string]int),
+    nodeUsage:             newNodeUsage(DefaultUsageHalfLife),
     clientSet:             client,
     snapshotSharedLister:  sharedLister,
     volumeBinder:          volumeBinder,
+    informerFactory:       informerFactory,
   }
 
 
   pluginsMap := make(map[string]Plugin)
 
+  pluginArgs := make(map[string]runtime.Object, len(pluginConfig))
+  for _, c := range pluginConfig {
+    pluginArgs[c.Name] = c.Args
+  }
+
   for name, factory := range r {
-    p, err := factory(nil, f)
+    p, err := factory(pluginArgs[name], f)
     if err != nil {
       return *f, fmt.Errorf("error initializing plugin %q: %v", name, err)
     }
@@ -106,12 +126,18 @@ func NewFramework(
   },config.Plugin{
     Name: "InterPodAffinity",
     Weight: 1,
+  },config.Plugin{
+    Name: "PodTopologySpread",
+    Weight: 1,
   },config.Plugin{
     Name: "VolumeBinding",
     Weight: 1,
   },config.Plugin{
     Name: "VolumeRestrictions",
     Weight: 1,
+  },config.Plugin{
+    Name: "NodeVolumeLimits",
+    Weight: 1,
   })
 
   // Prefilter plugins
@@ -125,6 +151,9 @@ func NewFramework(
   },config.Plugin{
     Name: "InterPodAffinity",
     Weight: 1,
+  },config.Plugin{
+    Name: "PodTopologySpread",
+    Weight: 1,
   })
 
   // Prescore plugins
@@ -135,6 +164,9 @@ func NewFramework(
   },config.Plugin{
     Name: "InterPodAffinity",
     Weight: 1,
+  },config.Plugin{
+    Name: "PodTopologySpread",
+    Weight: 1,
   })
 
   // Score plugins
@@ -148,17 +180,28 @@ func NewFramework(
   },config.Plugin{
     Name: "ImageLocality",
     Weight: 1,
-  },config.Plugin{
-    Name: "ResourcePriority",
-    Weight: 1,
   },config.Plugin{
     Name: "RepeatPriority",
     Weight: 1,
   },config.Plugin{
     Name: "InterPodAffinity",
     Weight: 1,
+  },config.Plugin{
+    Name: "PodTopologySpread",
+    Weight: 1,
   })
 
+  if len(resourceScorers) == 0 {
+    resourceScorers = []string{"ResourcePriority"}
+  }
+
+  for _, name := range resourceScorers {
+    pluginArr4 = append(pluginArr4, config.Plugin{
+      Name: name,
+      Weight: 1,
+    })
+  }
+
   // This plugin set consist of all the configured filter plugins.
   pluginSet := config.PluginSet{
     Enabled: pluginArr,
@@ -288,7 +331,11 @@ func (f *framework) RunFilterPlugins(
 	state *CycleState,
 	pod *v1.Pod,
 	nodeInfo *NodeInfo,
-) PluginToStatus {
+) (result PluginToStatus) {
+	startTime := time.Now()
+	defer func() {
+		metrics.FrameworkExtensionPointDuration.WithLabelValues(filter, result.Merge().Code().String()).Observe(metrics.SinceInSeconds(startTime))
+	}()
 	var firstFailedStatus *Status
 	statuses := make(PluginToStatus)
 	for _, pl := range f.filterPlugins {
@@ -312,7 +359,9 @@ func (f *framework) RunFilterPlugins(
 
 
 func (f *framework) runFilterPlugin(ctx context.Context, pl FilterPlugin, state *CycleState, pod *v1.Pod, nodeInfo *NodeInfo) *Status {
+	startTime := time.Now()
 	status := pl.Filter(ctx, state, pod, nodeInfo)
+	metrics.PluginExecutionDuration.WithLabelValues(pl.Name(), filter, status.Code().String()).Observe(metrics.SinceInSeconds(startTime))
 	return status
 }
 
@@ -331,29 +380,34 @@ func (f *framework) HasScorePlugins() (bool){
   return (len(f.scorePlugins) > 0)
 }
 
-// Get the highest repeat factor for at the time of invoking this function.
-func (f *framework) GetHighestUsageFactor() (int){
-  return f.highestRepeatFactor
+// Get the highest usage factor at the time of invoking this function, it is at least 1.
+func (f *framework) GetHighestUsageFactor() (float64){
+  return math.Max(f.nodeUsage.max(), 1)
 }
 
-// Get the current repeat factor for a specific node at the time of invoking this function.
-func (f *framework) GetNodeUsageFactor(nodeName string) (int){
-  return f.nodeUsageMap[nodeName]
+// Get the current usage factor for a specific node at the time of invoking this function.
+func (f *framework) GetNodeUsageFactor(nodeName string) (float64){
+  return f.nodeUsage.get(nodeName)
 }
 
-// Increase node repeat factory by 1 one function invoked.
-func (f *framework) IncreaseNodeUsageFactor(nodeName string){
-
-  f.nodeUsageMap[nodeName] +=1
+// Count the pod selected for a node in the usage factor of the node before the binding is observed
+// in the pod informer.
+func (f *framework) IncreaseNodeUsageFactor(pod *v1.Pod, nodeName string){
+  f.nodeUsage.observe(pod.UID, nodeName, time.Now())
+}
 
-  if f.nodeUsageMap[nodeName] > f.highestRepeatFactor {
-    f.highestRepeatFactor+=1
+// Count a bound pod observed in the pod informer in the usage factor of its node, the pods bound by
+// the other schedulers are counted so that the usage factor covers the whole cluster.
+func (f *framework) ObservePodBinding(pod *v1.Pod){
+  if len(pod.Spec.NodeName) == 0 {
+    return
   }
+  f.nodeUsage.observe(pod.UID, pod.Spec.NodeName, bindingTime(pod))
+}
 
-  // Special case, reset the map if this happens
-  if f.nodeUsageMap[nodeName] == 0 {
-    f.nodeUsageMap = make(map[string]int)
-  }
+// Stop tracking a deleted pod.
+func (f *framework) ForgetPod(pod *v1.Pod){
+  f.nodeUsage.forget(pod.UID)
 }
 
 // SnapshotSharedLister returns the scheduler's SharedLister of the latest NodeInfo
@@ -374,6 +428,11 @@ func (f *framework) VolumeBinder() scheduling.SchedulerVolumeBinder {
 	return f.volumeBinder
 }
 
+// SharedInformerFactory returns the informer factory of the scheduler.
+func (f *framework) SharedInformerFactory() informers.SharedInformerFactory {
+	return f.informerFactory
+}
+
 // RunPreScorePlugins runs the set of configured pre-score plugins. If any
 // of these plugins returns any status other than "Success", the given pod is rejected.
 func (f *framework) RunPreScorePlugins(
@@ -405,6 +464,10 @@ func (f *framework) runPreScorePlugin(ctx context.Context, pl PreScorePlugin, st
 // It also returns *Status, which is set to non-success if any of the plugins returns
 // a non-success status.
 func (f *framework) RunScorePlugins(ctx context.Context, state *CycleState, pod *v1.Pod, nodes []*v1.Node) (ps PluginToNodeScores, status *Status) {
+	startTime := time.Now()
+	defer func() {
+		metrics.FrameworkExtensionPointDuration.WithLabelValues(score, status.Code().String()).Observe(metrics.SinceInSeconds(startTime))
+	}()
 	pluginToNodeScores := make(PluginToNodeScores, len(f.scorePlugins))
 	for _, pl := range f.scorePlugins {
 		pluginToNodeScores[pl.Name()] = make(NodeScoreList, len(nodes))
@@ -460,6 +523,8 @@ func (f *framework) RunScorePlugins(ctx context.Context, state *CycleState, pod
 }
 
 func (f *framework) runScorePlugin(ctx context.Context, pl ScorePlugin, state *CycleState, pod *v1.Pod, nodeName string) (int64, *Status) {
+	startTime := time.Now()
 	s, status := pl.Score(ctx, state, pod, nodeName)
+	metrics.PluginExecutionDuration.WithLabelValues(pl.Name(), score, status.Code().String()).Observe(metrics.SinceInSeconds(startTime))
 	return s, status
 }
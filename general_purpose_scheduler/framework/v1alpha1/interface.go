@@ -23,6 +23,7 @@ import(
   "math"
 	v1 "k8s.io/api/core/v1"
   clientset "k8s.io/client-go/kubernetes"
+  "k8s.io/client-go/informers"
   "github.com/alexnjh/epsilon/general_purpose_scheduler/k8s.io/kubernetes/pkg/controller/volume/scheduling"
 )
 
@@ -94,6 +95,12 @@ type Framework interface {
 	RunScorePlugins(ctx context.Context, state *CycleState, pod *v1.Pod, nodes []*v1.Node) (PluginToNodeScores, *Status)
 
   WithSnapshotSharedLister(snapshotSharedLister SharedLister)
+
+  // ObservePodBinding counts a bound pod seen in the pod informer in the usage factor of its node.
+  ObservePodBinding(pod *v1.Pod)
+
+  // ForgetPod stops tracking a deleted pod in the usage factors.
+  ForgetPod(pod *v1.Pod)
 }
 
 // FrameworkHandle provides data and some tools that plugins can use. It is
@@ -116,14 +123,19 @@ type FrameworkHandle interface {
   // VolumeBinder returns the volume binder used by scheduler.
   VolumeBinder() scheduling.SchedulerVolumeBinder
 
-  // Get current highest repeat factory among all the nodes in the cluster for this scheduler instance.
-  GetHighestUsageFactor() int
+  // SharedInformerFactory returns the informer factory of the scheduler, the informers must have
+  // been created before the factory is started.
+  SharedInformerFactory() informers.SharedInformerFactory
+
+  // Get current highest usage factor among all the nodes in the cluster, the usage factor is the
+  // number of pods bound to a node decayed with the time since the binding.
+  GetHighestUsageFactor() float64
 
-  // Get current repeat factory of a node.
-  GetNodeUsageFactor(nodeName string) int
+  // Get current usage factor of a node.
+  GetNodeUsageFactor(nodeName string) float64
 
-  // Increase repeat factor of a specific node.
-  IncreaseNodeUsageFactor(nodeName string)
+  // Count a pod selected for a node in the usage factor of the node.
+  IncreaseNodeUsageFactor(pod *v1.Pod, nodeName string)
 
 }
 
@@ -152,6 +164,13 @@ const (
 	// Skip is used when a bind plugin chooses to skip binding.
 	Skip
 )
+
+// This list should be exactly the same as the codes iota defined above in the same order.
+var codes = []string{"Success", "Error", "Unschedulable", "UnschedulableAndUnresolvable", "Wait", "Skip"}
+
+func (c Code) String() string {
+	return codes[c]
+}
 // Status contain the status and also the reasons for causing this status.
 type Status struct{
   code    Code
@@ -174,6 +193,14 @@ func (s *Status) Message() string {
 	return strings.Join(s.reasons, ", ")
 }
 
+// Reasons returns the reasons of the Status.
+func (s *Status) Reasons() []string {
+	if s == nil {
+		return nil
+	}
+	return s.reasons
+}
+
 // AppendReason appends given reason to the Status.
 func (s *Status) AppendReason(reason string) {
 	s.reasons = append(s.reasons, reason)
@@ -194,6 +221,9 @@ func (s *Status) IsUnschedulable() bool {
 // returned which status.
 type PluginToStatus map[string]*Status
 
+// NodeToStatusMap declares map from node name to its status.
+type NodeToStatusMap map[string]*Status
+
 // Merge merges the statuses in the map into one. The resulting status code have the following
 // precedence: Error, UnschedulableAndUnresolvable, Unschedulable.
 func (p PluginToStatus) Merge() *Status {
@@ -0,0 +1,185 @@
+/*
+Copyright (C) 2020 Alex Neo
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// DefaultUsageHalfLife is the time after which a binding counts for half in the usage factor of a node.
+	DefaultUsageHalfLife = 10 * time.Minute
+
+	// The stored usage is rescaled once the reference time is this many half-lives old, so that the
+	// scaled values stay far from overflowing
+	maxUsageHalfLives = 32
+)
+
+// nodeUsage is the usage factor of the nodes, the number of pods bound to every node decayed
+// exponentially with the time since the binding. Every pod is counted once, whether the binding is
+// made by this scheduler or observed in the pod informer, so the usage factor is the same on all
+// the scheduler replicas and is rebuilt from the bound pods after a restart.
+//
+// The usage is stored scaled to a reference time so that the decay does not need to update every
+// node, the usage at time t is value * 2^(-(t - base) / halfLife).
+type nodeUsage struct {
+	mu       sync.RWMutex
+	halfLife time.Duration
+	now      func() time.Time
+	base     time.Time
+	values   map[string]float64
+	highest  float64
+	// Node each counted pod is bound to and its scaled contribution to the usage of the node
+	pods map[types.UID]podUsage
+}
+
+// podUsage is the contribution of a pod to the usage of the node it is bound to.
+type podUsage struct {
+	node   string
+	weight float64
+}
+
+func newNodeUsage(halfLife time.Duration) *nodeUsage {
+	if halfLife <= 0 {
+		halfLife = DefaultUsageHalfLife
+	}
+	return &nodeUsage{
+		halfLife: halfLife,
+		now:      time.Now,
+		base:     time.Now(),
+		values:   make(map[string]float64),
+		pods:     make(map[types.UID]podUsage),
+	}
+}
+
+// Returns 2^((t - base) / halfLife), the weight of a binding made at t relative to the reference time.
+func (u *nodeUsage) scale(t time.Time) float64 {
+	return math.Exp2(t.Sub(u.base).Seconds() / u.halfLife.Seconds())
+}
+
+// observe counts a pod bound to the node at the given time. A pod already counted on the node is
+// not counted again, and a pod counted on another node, e.g. the node of a binding that failed, is
+// moved to the node.
+func (u *nodeUsage) observe(uid types.UID, nodeName string, at time.Time) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	previous, counted := u.pods[uid]
+	if counted && previous.node == nodeName {
+		return
+	}
+
+	now := u.now()
+	if at.After(now) {
+		at = now
+	}
+	if now.Sub(u.base) > maxUsageHalfLives*u.halfLife {
+		u.rebase(now)
+		previous = u.pods[uid]
+	}
+
+	weight := u.scale(at)
+	u.pods[uid] = podUsage{node: nodeName, weight: weight}
+
+	u.values[nodeName] += weight
+	if u.values[nodeName] > u.highest {
+		u.highest = u.values[nodeName]
+	}
+
+	if counted {
+		u.remove(previous)
+	}
+}
+
+// Subtract the contribution of a pod from the usage of its previous node
+func (u *nodeUsage) remove(p podUsage) {
+	value := u.values[p.node] - p.weight
+	wasHighest := u.values[p.node] >= u.highest
+	if value < 1e-9 {
+		delete(u.values, p.node)
+	} else {
+		u.values[p.node] = value
+	}
+
+	if !wasHighest {
+		return
+	}
+	u.highest = 0
+	for _, value := range u.values {
+		if value > u.highest {
+			u.highest = value
+		}
+	}
+}
+
+// Move the reference time to now and drop the nodes whose usage decayed away
+func (u *nodeUsage) rebase(now time.Time) {
+	factor := 1 / u.scale(now)
+	for node, value := range u.values {
+		if value*factor < 1e-6 {
+			delete(u.values, node)
+			continue
+		}
+		u.values[node] = value * factor
+	}
+	for uid, p := range u.pods {
+		p.weight *= factor
+		u.pods[uid] = p
+	}
+	u.highest *= factor
+	u.base = now
+}
+
+// forget stops tracking a deleted pod, its binding still counts until it decays.
+func (u *nodeUsage) forget(uid types.UID) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	delete(u.pods, uid)
+}
+
+// get returns the usage factor of the node.
+func (u *nodeUsage) get(nodeName string) float64 {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	return u.values[nodeName] / u.scale(u.now())
+}
+
+// max returns the highest usage factor among the nodes.
+func (u *nodeUsage) max() float64 {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	return u.highest / u.scale(u.now())
+}
+
+// bindingTime returns when the pod was bound, the time its PodScheduled condition became true or
+// its creation time if the condition is missing.
+func bindingTime(pod *v1.Pod) time.Time {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == v1.PodScheduled && c.Status == v1.ConditionTrue && !c.LastTransitionTime.IsZero() {
+			return c.LastTransitionTime.Time
+		}
+	}
+	if !pod.CreationTimestamp.IsZero() {
+		return pod.CreationTimestamp.Time
+	}
+	return time.Now()
+}
@@ -0,0 +1,156 @@
+/*
+Copyright (C) 2020 Alex Neo
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func newTestNodeUsage(now *time.Time) *nodeUsage {
+	u := newNodeUsage(time.Minute)
+	u.base = *now
+	u.now = func() time.Time { return *now }
+	return u
+}
+
+func expectUsage(t *testing.T, what string, got, want float64) {
+	t.Helper()
+	if math.Abs(got-want) > 1e-9 {
+		t.Errorf("expected %s to be %v, got %v", what, want, got)
+	}
+}
+
+func TestNodeUsageCountsPodsOnce(t *testing.T) {
+	now := time.Unix(1000, 0)
+	u := newTestNodeUsage(&now)
+
+	// The binding made by this scheduler and the same binding seen in the informer
+	u.observe("pod-1", "node-a", now)
+	u.observe("pod-1", "node-a", now)
+	u.observe("pod-2", "node-a", now)
+	u.observe("pod-3", "node-b", now)
+
+	expectUsage(t, "node-a", u.get("node-a"), 2)
+	expectUsage(t, "node-b", u.get("node-b"), 1)
+	expectUsage(t, "the highest usage", u.max(), 2)
+
+	// A pod bound to another node after a failed binding is moved to that node
+	u.observe("pod-3", "node-a", now)
+	expectUsage(t, "node-a", u.get("node-a"), 3)
+	expectUsage(t, "node-b", u.get("node-b"), 0)
+	expectUsage(t, "the highest usage", u.max(), 3)
+
+	// Moving the pod off the busiest node lowers the highest usage
+	u.observe("pod-1", "node-b", now)
+	expectUsage(t, "node-a", u.get("node-a"), 2)
+	expectUsage(t, "node-b", u.get("node-b"), 1)
+	expectUsage(t, "the highest usage", u.max(), 2)
+}
+
+func TestNodeUsageMovesDecayedPods(t *testing.T) {
+	now := time.Unix(1000, 0)
+	u := newTestNodeUsage(&now)
+
+	// The contribution of the pod decayed by the time it is seen on another node
+	u.observe("pod-1", "node-a", now)
+	u.observe("pod-2", "node-a", now)
+	now = now.Add(time.Minute)
+	u.observe("pod-1", "node-b", now)
+
+	expectUsage(t, "node-a", u.get("node-a"), 0.5)
+	expectUsage(t, "node-b", u.get("node-b"), 1)
+
+	// The contribution is rescaled with the usage when the reference time moves
+	now = now.Add(maxUsageHalfLives * time.Minute)
+	u.observe("pod-1", "node-a", now)
+	if now != u.base {
+		t.Fatalf("expected the reference time to move to %v, got %v", now, u.base)
+	}
+	expectUsage(t, "node-b", u.get("node-b"), 0)
+	expectUsage(t, "node-a", u.get("node-a"), 1)
+}
+
+func TestNodeUsageDecays(t *testing.T) {
+	now := time.Unix(1000, 0)
+	u := newTestNodeUsage(&now)
+
+	u.observe("pod-1", "node-a", now)
+	u.observe("pod-2", "node-b", now.Add(-time.Minute))
+	expectUsage(t, "node-b", u.get("node-b"), 0.5)
+
+	now = now.Add(2 * time.Minute)
+	expectUsage(t, "node-a", u.get("node-a"), 0.25)
+	expectUsage(t, "the highest usage", u.max(), 0.25)
+
+	// Rebasing keeps the usage
+	now = now.Add(maxUsageHalfLives * time.Minute)
+	u.observe("pod-3", "node-b", now)
+	if now != u.base {
+		t.Fatalf("expected the reference time to move to %v, got %v", now, u.base)
+	}
+	expectUsage(t, "node-b", u.get("node-b"), 1+math.Exp2(-maxUsageHalfLives-3))
+	expectUsage(t, "the highest usage", u.max(), u.get("node-b"))
+}
+
+func TestNodeUsageConcurrent(t *testing.T) {
+	u := newNodeUsage(time.Minute)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				u.observe(types.UID(fmt.Sprintf("pod-%d-%d", i, j)), "node", time.Now())
+				u.get("node")
+				u.max()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if len(u.pods) != 800 {
+		t.Errorf("expected 800 pods, got %d", len(u.pods))
+	}
+}
+
+func TestBindingTime(t *testing.T) {
+	created := time.Unix(1000, 0)
+	scheduled := time.Unix(1060, 0)
+
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(created)}}
+	if got := bindingTime(pod); !got.Equal(created) {
+		t.Errorf("expected the creation time, got %v", got)
+	}
+
+	pod.Status.Conditions = []v1.PodCondition{{
+		Type:               v1.PodScheduled,
+		Status:             v1.ConditionTrue,
+		LastTransitionTime: metav1.NewTime(scheduled),
+	}}
+	if got := bindingTime(pod); !got.Equal(scheduled) {
+		t.Errorf("expected the scheduled time, got %v", got)
+	}
+}
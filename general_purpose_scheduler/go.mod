@@ -5,24 +5,49 @@ go 1.14
 require (
 	github.com/MichaelTJones/pcg v0.0.0-20180122055547-df440c6ed7ed
 	github.com/alexnjh/epsilon/communication v0.0.0-20210325152251-2532cd9a7283
-	github.com/bigkevmcd/go-configparser v0.0.0-20200217161103-d137835d2579
+	github.com/alexnjh/epsilon/coordinator v0.0.0-00010101000000-000000000000
+	github.com/alexnjh/epsilon/retry v0.0.0-00010101000000-000000000000
+	github.com/bigkevmcd/go-configparser v0.0.0-20210106142102-909504547ead
 	github.com/davidminor/gorand v0.0.0-20161120223607-283446f2caf5
 	github.com/davidminor/uint128 v0.0.0-20141227063632-5745f1bf8041 // indirect
 	github.com/docker/distribution v2.7.1+incompatible
-	github.com/json-iterator/go v1.1.8
+	github.com/docker/docker v0.7.3-0.20190327010347-be7ac8be2ae0 // indirect
+	github.com/json-iterator/go v1.1.10
 	github.com/opencontainers/go-digest v1.0.0 // indirect
-	github.com/sirupsen/logrus v1.6.0
+	github.com/prometheus/client_golang v1.9.0
+	github.com/prometheus/client_model v0.2.0
+	github.com/sirupsen/logrus v1.7.0
 	github.com/streadway/amqp v1.0.0
+	go.opentelemetry.io/otel v1.2.0
+	go.opentelemetry.io/otel/sdk v1.2.0 // indirect
+	go.opentelemetry.io/otel/trace v1.2.0
 	golang.org/x/oauth2 v0.0.0-20200107190931-bf48bf16ab8d // indirect
 	golang.org/x/time v0.0.0-20200630173020-3af7569d3a1e // indirect
-	k8s.io/api v0.18.6
-	k8s.io/apimachinery v0.18.6
-	k8s.io/apiserver v0.18.6
-	k8s.io/client-go v0.18.6
-	k8s.io/component-base v0.18.6
+	k8s.io/api v0.20.0
+	k8s.io/apimachinery v0.20.0
+	k8s.io/apiserver v0.20.0
+	k8s.io/client-go v11.0.0+incompatible
+	k8s.io/component-base v0.20.0
 	k8s.io/csi-translation-lib v0.18.6
 	k8s.io/klog v1.0.0
 	k8s.io/kube-scheduler v0.18.6
-	k8s.io/utils v0.0.0-20200619165400-6e3d28b6ed19
+	k8s.io/utils v0.0.0-20201110183641-67b214c5f920
 	sigs.k8s.io/yaml v1.2.0
 )
+
+replace github.com/alexnjh/epsilon/communication => ../communication
+
+replace github.com/alexnjh/epsilon/coordinator => ../coordinator
+
+replace github.com/alexnjh/epsilon/retry => ../retry
+
+// The coordinator and retry modules are built against a newer client-go, keep the versions the
+// scheduler framework is built against
+replace (
+	github.com/googleapis/gnostic => github.com/googleapis/gnostic v0.1.0
+	k8s.io/api => k8s.io/api v0.18.6
+	k8s.io/apimachinery => k8s.io/apimachinery v0.18.6
+	k8s.io/apiserver => k8s.io/apiserver v0.18.6
+	k8s.io/client-go => k8s.io/client-go v0.18.6
+	k8s.io/component-base => k8s.io/component-base v0.18.6
+)
@@ -0,0 +1,586 @@
+/*
+Copyright (C) 2020 Alex Neo
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	communication "github.com/alexnjh/epsilon/communication"
+	coordinator "github.com/alexnjh/epsilon/coordinator/controller"
+	internalcache "github.com/alexnjh/epsilon/general_purpose_scheduler/internal/cache"
+	"github.com/alexnjh/epsilon/general_purpose_scheduler/k8s.io/kubernetes/pkg/controller/volume/scheduling"
+	sched "github.com/alexnjh/epsilon/general_purpose_scheduler/scheduler"
+	"github.com/alexnjh/epsilon/retry/backoff"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/streadway/amqp"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	kubeinformers "k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	core "k8s.io/client-go/testing"
+	"k8s.io/client-go/util/workqueue"
+)
+
+const (
+	// Queues of the harness, the defaults of the deployment files
+	harnessQueue        = "epsilon.distributed"
+	harnessBackoffQueue = "epsilon.backoff"
+)
+
+var podsResource = schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+
+// harnessConfig is the size of the cluster and of the workload run by the harness.
+type harnessConfig struct {
+	Nodes    int
+	Replicas int
+	// Pods fitting on the nodes
+	Pods int
+	// Pods requesting more than a node has, they are retried until the backoff is exceeded
+	Unschedulable int
+	// Pods created per second, 0 creates them as fast as possible
+	Rate float64
+	// Duration of one second of the retry backoff, the retry service waits 2, 4, 16 then 256 seconds
+	BackoffUnit time.Duration
+	// Time to wait for the pods to be bound or to exceed the backoff
+	Timeout time.Duration
+}
+
+// harness runs the coordinator, the scheduler replicas and the retry service against a fake API
+// server and an in-memory broker. The replicas run the ScheduleProcess of the scheduler, the
+// coordinator and the retry service run the processes of their microservices from the
+// communication module.
+type harness struct {
+	config harnessConfig
+	client *fake.Clientset
+	broker *communication.MemoryBroker
+	stopCh chan struct{}
+	// Signalled by the ScheduleProcess of the replicas when they stop
+	closed chan bool
+
+	podWatches int32
+	retries    int64
+
+	mu        sync.Mutex
+	start     time.Time
+	created   map[string]time.Time
+	bound     map[string]time.Time
+	failed    map[string]bool
+	conflicts int
+	done      chan struct{}
+}
+
+// harnessReport is the outcome of a run of the harness.
+type harnessReport struct {
+	Scheduled     int
+	Unschedulable int
+	// Pods neither bound nor given up on when the run ended
+	Pending int
+	// Bindings rejected because the pod was already bound
+	Conflicts int
+	// Pods sent to the retry service
+	Retries int64
+	// Nodes whose pods request more than their allocatable resources, as the replicas place pods
+	// without seeing the bindings of each other in time
+	Overcommitted int
+	Duration      time.Duration
+	// Pods bound per second
+	Throughput float64
+	// Time from the creation of a pod to its binding
+	P50, P90, P99, Max time.Duration
+}
+
+func (r harnessReport) String() string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "scheduled\t%d\n", r.Scheduled)
+	fmt.Fprintf(w, "unschedulable\t%d\n", r.Unschedulable)
+	fmt.Fprintf(w, "pending\t%d\n", r.Pending)
+	fmt.Fprintf(w, "binding conflicts\t%d\n", r.Conflicts)
+	fmt.Fprintf(w, "retries\t%d\n", r.Retries)
+	fmt.Fprintf(w, "overcommitted nodes\t%d\n", r.Overcommitted)
+	fmt.Fprintf(w, "duration\t%v\n", r.Duration)
+	fmt.Fprintf(w, "throughput\t%.1f pods/s\n", r.Throughput)
+	fmt.Fprintf(w, "latency p50/p90/p99/max\t%v / %v / %v / %v\n", r.P50, r.P90, r.P99, r.Max)
+	w.Flush()
+	return b.String()
+}
+
+func newHarness(config harnessConfig) (*harness, error) {
+	objects := make([]runtime.Object, 0, config.Nodes)
+	for i := 0; i < config.Nodes; i++ {
+		objects = append(objects, harnessNode(fmt.Sprintf("node-%04d", i)))
+	}
+
+	h := &harness{
+		config:  config,
+		client:  fake.NewSimpleClientset(objects...),
+		broker:  communication.NewMemoryBroker(),
+		stopCh:  make(chan struct{}),
+		closed:  make(chan bool, config.Replicas),
+		created: make(map[string]time.Time),
+		bound:   make(map[string]time.Time),
+		failed:  make(map[string]bool),
+		done:    make(chan struct{}),
+	}
+	h.client.PrependWatchReactor("*", h.watchReactor)
+	h.client.PrependReactor("create", "pods", h.bindReactor)
+	h.client.PrependReactor("update", "pods", h.statusReactor)
+
+	for _, queue := range []string{harnessQueue, harnessBackoffQueue} {
+		if err := h.broker.QueueDeclare(queue); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+// Start the services and wait for their informers to watch the pods.
+func (h *harness) Start() error {
+	for i := 0; i < h.config.Replicas; i++ {
+		if err := h.startReplica(fmt.Sprintf("scheduler-%d", i)); err != nil {
+			return err
+		}
+	}
+	if err := h.startCoordinator(); err != nil {
+		return err
+	}
+	if err := h.startRetry(); err != nil {
+		return err
+	}
+
+	// The fake watchers do not replay the events missed between the list and the watch
+	return wait.PollImmediate(10*time.Millisecond, 10*time.Second, func() (bool, error) {
+		return atomic.LoadInt32(&h.podWatches) >= int32(h.config.Replicas+1), nil
+	})
+}
+
+// Stop the services.
+func (h *harness) Stop() {
+	close(h.stopCh)
+	h.broker.Close()
+}
+
+// Run creates the pods and waits until they are all bound or given up on.
+func (h *harness) Run() harnessReport {
+	h.mu.Lock()
+	h.start = time.Now()
+	h.mu.Unlock()
+
+	total := h.config.Pods + h.config.Unschedulable
+	for i := 0; i < total; i++ {
+		if h.config.Rate > 0 {
+			if wait := time.Until(h.start.Add(time.Duration(float64(i) / h.config.Rate * float64(time.Second)))); wait > 0 {
+				time.Sleep(wait)
+			}
+		}
+
+		pod := harnessPod(fmt.Sprintf("pod-%05d", i), i >= h.config.Pods, i)
+		h.mu.Lock()
+		h.created[pod.Name] = pod.CreationTimestamp.Time
+		h.mu.Unlock()
+
+		if _, err := h.client.CoreV1().Pods(pod.Namespace).Create(context.TODO(), pod, metav1.CreateOptions{}); err != nil {
+			panic(err)
+		}
+	}
+
+	select {
+	case <-h.done:
+	case <-time.After(h.config.Timeout):
+	}
+	return h.report()
+}
+
+func (h *harness) report() harnessReport {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r := harnessReport{
+		Scheduled:     len(h.bound),
+		Unschedulable: len(h.failed),
+		Pending:       len(h.created) - len(h.bound) - len(h.failed),
+		Conflicts:     h.conflicts,
+		Retries:       atomic.LoadInt64(&h.retries),
+		Overcommitted: h.overcommitted(),
+	}
+
+	latencies := make([]time.Duration, 0, len(h.bound))
+	var last time.Time
+	for name, at := range h.bound {
+		latencies = append(latencies, at.Sub(h.created[name]))
+		if at.After(last) {
+			last = at
+		}
+	}
+	if len(latencies) == 0 {
+		return r
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	percentile := func(p float64) time.Duration {
+		return latencies[int(p*float64(len(latencies)-1))]
+	}
+	r.P50, r.P90, r.P99, r.Max = percentile(0.5), percentile(0.9), percentile(0.99), latencies[len(latencies)-1]
+	r.Duration = last.Sub(h.start)
+	r.Throughput = float64(len(latencies)) / r.Duration.Seconds()
+	return r
+}
+
+// Count the nodes whose pods request more than the allocatable resources of the node.
+func (h *harness) overcommitted() int {
+	pods, err := h.client.Tracker().List(podsResource, schema.GroupVersionKind{Version: "v1", Kind: "Pod"}, "")
+	if err != nil {
+		panic(err)
+	}
+
+	requested := make(map[string]corev1.ResourceList)
+	for _, pod := range pods.(*corev1.PodList).Items {
+		if pod.Spec.NodeName == "" {
+			continue
+		}
+		list, ok := requested[pod.Spec.NodeName]
+		if !ok {
+			list = corev1.ResourceList{corev1.ResourcePods: resource.MustParse("0")}
+			requested[pod.Spec.NodeName] = list
+		}
+		for _, c := range pod.Spec.Containers {
+			for name, quantity := range c.Resources.Requests {
+				sum := list[name]
+				sum.Add(quantity)
+				list[name] = sum
+			}
+		}
+		count := list[corev1.ResourcePods]
+		count.Add(resource.MustParse("1"))
+		list[corev1.ResourcePods] = count
+	}
+
+	overcommitted := 0
+	allocatable := harnessNode("").Status.Allocatable
+	for _, list := range requested {
+		for name, quantity := range list {
+			if limit, ok := allocatable[name]; ok && quantity.Cmp(limit) > 0 {
+				overcommitted++
+				break
+			}
+		}
+	}
+	return overcommitted
+}
+
+// Record a bound or given up pod, the run is done once every pod is.
+func (h *harness) settled(name string, bound bool) {
+	if bound {
+		h.bound[name] = time.Now()
+	} else {
+		h.failed[name] = true
+	}
+	if len(h.bound)+len(h.failed) == h.config.Pods+h.config.Unschedulable {
+		select {
+		case <-h.done:
+		default:
+			close(h.done)
+		}
+	}
+}
+
+// The fake watchers panic once 100 events are waiting to be read, the events are buffered so that
+// the informers can fall behind during bursts of pods.
+func (h *harness) watchReactor(action core.Action) (bool, watch.Interface, error) {
+	w, err := h.client.Tracker().Watch(action.GetResource(), action.GetNamespace())
+	if err != nil {
+		return true, nil, err
+	}
+	if action.GetResource().Resource == "pods" {
+		atomic.AddInt32(&h.podWatches, 1)
+	}
+	return true, newBufferedWatcher(w), nil
+}
+
+// Bind the pod like the API server, the binding is rejected if the pod is already bound.
+func (h *harness) bindReactor(action core.Action) (bool, runtime.Object, error) {
+	if action.GetSubresource() != "binding" {
+		return false, nil, nil
+	}
+	binding := action.(core.CreateAction).GetObject().(*corev1.Binding)
+
+	obj, err := h.client.Tracker().Get(podsResource, binding.Namespace, binding.Name)
+	if err != nil {
+		return true, nil, err
+	}
+	pod := obj.(*corev1.Pod).DeepCopy()
+
+	if pod.Spec.NodeName != "" {
+		h.mu.Lock()
+		h.conflicts++
+		h.mu.Unlock()
+		return true, nil, errors.NewConflict(podsResource.GroupResource(), pod.Name,
+			fmt.Errorf("pod %s is already assigned to node %q", pod.Name, pod.Spec.NodeName))
+	}
+
+	pod.Spec.NodeName = binding.Target.Name
+	if pod.Annotations == nil {
+		pod.Annotations = make(map[string]string)
+	}
+	for k, v := range binding.Annotations {
+		pod.Annotations[k] = v
+	}
+	pod.Status.Conditions = append(pod.Status.Conditions, corev1.PodCondition{
+		Type:               corev1.PodScheduled,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+	})
+	if err := h.client.Tracker().Update(podsResource, pod, pod.Namespace); err != nil {
+		return true, nil, err
+	}
+
+	h.mu.Lock()
+	h.settled(pod.Name, true)
+	h.mu.Unlock()
+	return true, binding, nil
+}
+
+// Update the status of the pod only, the fake clientset would replace the whole pod and undo a
+// binding made since the pod was fetched.
+func (h *harness) statusReactor(action core.Action) (bool, runtime.Object, error) {
+	if action.GetSubresource() != "status" {
+		return false, nil, nil
+	}
+	updated := action.(core.UpdateAction).GetObject().(*corev1.Pod)
+
+	obj, err := h.client.Tracker().Get(podsResource, updated.Namespace, updated.Name)
+	if err != nil {
+		return true, nil, err
+	}
+	pod := obj.(*corev1.Pod).DeepCopy()
+	pod.Status = updated.Status
+	if err := h.client.Tracker().Update(podsResource, pod, pod.Namespace); err != nil {
+		return true, nil, err
+	}
+
+	if pod.Status.Phase == PodBackoffExceeded {
+		h.mu.Lock()
+		if _, ok := h.bound[pod.Name]; !ok && !h.failed[pod.Name] {
+			h.settled(pod.Name, false)
+		}
+		h.mu.Unlock()
+	}
+	return true, pod, nil
+}
+
+// Start a replica of the scheduler, set up like main() with its own informers and cache.
+func (h *harness) startReplica(hostname string) error {
+	kubefactory := kubeinformers.NewSharedInformerFactory(h.client, 0)
+	node_informer := kubefactory.Core().V1().Nodes().Informer()
+	pod_informer := kubefactory.Core().V1().Pods().Informer()
+	node_lister := kubefactory.Core().V1().Nodes().Lister()
+	pod_lister := kubefactory.Core().V1().Pods().Lister()
+
+	volumeBinder := scheduling.NewVolumeBinder(
+		h.client,
+		kubefactory.Core().V1().Nodes(),
+		kubefactory.Storage().V1().CSINodes(),
+		kubefactory.Core().V1().PersistentVolumeClaims(),
+		kubefactory.Core().V1().PersistentVolumes(),
+		kubefactory.Storage().V1().StorageClasses(),
+		10*time.Second,
+	)
+
+	schedulerCache := internalcache.New(30*time.Second, h.stopCh)
+	s, err := sched.New(volumeBinder, h.client, schedulerCache, kubefactory, node_lister, pod_lister, false, 10.0, nil, getResourceScorers("ResourcePriority"))
+	if err != nil {
+		return err
+	}
+	addAllEventHandlers(s, kubefactory, node_informer, pod_informer)
+
+	kubefactory.Start(h.stopCh)
+	kubefactory.WaitForCacheSync(h.stopCh)
+
+	comm := h.broker.Client()
+	msgs, err := comm.Receive(harnessQueue)
+	if err != nil {
+		return err
+	}
+	go ScheduleProcess(comm, s, h.client, pod_lister, msgs, h.closed, harnessQueue, harnessBackoffQueue, hostname, MaxBackOffTime)
+	return nil
+}
+
+// Start the coordinator, sending a schedule request for every new pod of the custom scheduler.
+func (h *harness) startCoordinator() error {
+	kubefactory := kubeinformers.NewSharedInformerFactory(h.client, 0)
+	pod_informer := kubefactory.Core().V1().Pods().Informer()
+	pod_lister := kubefactory.Core().V1().Pods().Lister()
+	queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+
+	var requests uint64
+	pod_informer.AddEventHandler(coordinator.PodEventHandler(queue, &requests))
+
+	processed := prometheus.NewCounter(prometheus.CounterOpts{Name: "pod_request_processed"})
+	controller := coordinator.NewPodController(h.client, pod_informer, pod_lister, queue,
+		coordinator.NewPodHandler(harnessQueue, "coordinator", h.client, pod_lister, h.broker.Client(), processed))
+
+	kubefactory.Start(h.stopCh)
+	go controller.Run(h.stopCh)
+
+	// The worker of the controller waits for the next pod until the queue is shut down
+	go func() {
+		<-h.stopCh
+		queue.ShutDown()
+	}()
+	return nil
+}
+
+// Start the retry service, sending the failed pods back to their queue after the backoff.
+func (h *harness) startRetry() error {
+	comm := h.broker.Client()
+	msgs, err := comm.Receive(harnessBackoffQueue)
+	if err != nil {
+		return err
+	}
+
+	// Count the pods sent to the retry service
+	counted := make(chan amqp.Delivery)
+	go func() {
+		defer close(counted)
+		for d := range msgs {
+			atomic.AddInt64(&h.retries, 1)
+			counted <- d
+		}
+	}()
+
+	go backoff.Process(comm, counted, make(chan bool, 1), harnessBackoffQueue, h.config.BackoffUnit)
+	return nil
+}
+
+func harnessNode(name string) *corev1.Node {
+	resources := corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("8"),
+		corev1.ResourceMemory: resource.MustParse("32Gi"),
+		corev1.ResourcePods:   resource.MustParse("110"),
+	}
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, UID: types.UID(name), Labels: map[string]string{"kubernetes.io/hostname": name}},
+		Status: corev1.NodeStatus{
+			Capacity:    resources,
+			Allocatable: resources,
+			Conditions:  []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+		},
+	}
+}
+
+// Sizes of the pods fitting on the nodes, used in turn
+var harnessPodSizes = []struct{ cpu, memory string }{
+	{"100m", "128Mi"},
+	{"250m", "256Mi"},
+	{"500m", "512Mi"},
+	{"1", "1Gi"},
+}
+
+// Returns a pending pod of the custom scheduler with the fields set by the API server on creation.
+func harnessPod(name string, unschedulable bool, i int) *corev1.Pod {
+	size := harnessPodSizes[i%len(harnessPodSizes)]
+	if unschedulable {
+		size.cpu = "16"
+	}
+	var priority int32
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "default",
+			UID:               types.UID(name),
+			CreationTimestamp: metav1.Now(),
+		},
+		Spec: corev1.PodSpec{
+			SchedulerName: "custom",
+			Priority:      &priority,
+			Containers: []corev1.Container{{
+				Name:  "app",
+				Image: "app",
+				Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse(size.cpu),
+					corev1.ResourceMemory: resource.MustParse(size.memory),
+				}},
+			}},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodPending},
+	}
+}
+
+// bufferedWatcher forwards the events of a watcher through an unbounded buffer.
+type bufferedWatcher struct {
+	source watch.Interface
+	result chan watch.Event
+	stop   chan struct{}
+	once   sync.Once
+}
+
+func newBufferedWatcher(source watch.Interface) watch.Interface {
+	w := &bufferedWatcher{source: source, result: make(chan watch.Event), stop: make(chan struct{})}
+	go w.run()
+	return w
+}
+
+func (w *bufferedWatcher) run() {
+	defer close(w.result)
+
+	var pending []watch.Event
+	in := w.source.ResultChan()
+	for {
+		var out chan watch.Event
+		var next watch.Event
+		if len(pending) != 0 {
+			out, next = w.result, pending[0]
+		}
+
+		select {
+		case event, ok := <-in:
+			if !ok {
+				return
+			}
+			pending = append(pending, event)
+		case out <- next:
+			pending = pending[1:]
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+func (w *bufferedWatcher) Stop() {
+	w.once.Do(func() {
+		w.source.Stop()
+		close(w.stop)
+	})
+}
+
+func (w *bufferedWatcher) ResultChan() <-chan watch.Event {
+	return w.result
+}
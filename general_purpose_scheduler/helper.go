@@ -23,6 +23,8 @@ package main
 import (
   "os"
   "fmt"
+  "strings"
+  "strconv"
   "time"
   "context"
   "math/rand"
@@ -35,10 +37,20 @@ import (
 	log "github.com/sirupsen/logrus"
   corev1 "k8s.io/api/core/v1"
   metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+  "k8s.io/apimachinery/pkg/runtime"
   framework "github.com/alexnjh/epsilon/general_purpose_scheduler/framework/v1alpha1"
+  "github.com/alexnjh/epsilon/general_purpose_scheduler/framework/plugins/noderesources"
+  "github.com/alexnjh/epsilon/general_purpose_scheduler/framework/plugins/loadaware"
+  "github.com/alexnjh/epsilon/general_purpose_scheduler/scheduler/metrics"
+  sched "github.com/alexnjh/epsilon/general_purpose_scheduler/scheduler"
+  podutil "github.com/alexnjh/epsilon/general_purpose_scheduler/k8s.io/kubernetes/pkg/api/v1/pod"
+  schedconfig "github.com/alexnjh/epsilon/general_purpose_scheduler/scheduler/config"
   utilfeature "k8s.io/apiserver/pkg/util/feature"
   configparser "github.com/bigkevmcd/go-configparser"
   communication "github.com/alexnjh/epsilon/communication"
+  "go.opentelemetry.io/otel/attribute"
+  "go.opentelemetry.io/otel/codes"
+  "go.opentelemetry.io/otel/trace"
 )
 
 // Get scheduler config file from config path
@@ -52,6 +64,105 @@ func getConfig(path string) (*configparser.ConfigParser, error){
 }
 
 
+// Get the names of the resource score plugins, the format is plugin,plugin,...
+func getResourceScorers(value string) []string{
+
+  scorers := make([]string, 0)
+
+  for _, name := range(strings.Split(value, ",")){
+    name = strings.TrimSpace(name)
+    if len(name) != 0 {
+      scorers = append(scorers, name)
+    }
+  }
+
+  return scorers
+}
+
+// Create the args of the NodeResourcesLeastAllocated, NodeResourcesMostAllocated and
+// NodeResourcesBalancedAllocation plugins from the resource weights, the format is resource=weight,...
+func getResourceWeightsConfig(value string) ([]schedconfig.PluginConfig, error){
+
+  resources := make([]schedconfig.ResourceSpec, 0)
+
+  for _, pair := range(strings.Split(value, ",")){
+
+    pair = strings.TrimSpace(pair)
+    if len(pair) == 0 {
+      continue
+    }
+
+    kv := strings.SplitN(pair, "=", 2)
+    if len(kv) != 2 || len(strings.TrimSpace(kv[0])) == 0 {
+      return nil, fmt.Errorf("Invalid resource weight %q, expected resource=weight", pair)
+    }
+
+    weight, err := strconv.ParseInt(strings.TrimSpace(kv[1]), 10, 64)
+    if err != nil {
+      return nil, fmt.Errorf("Invalid resource weight %q: %v", pair, err)
+    }
+
+    resources = append(resources, schedconfig.ResourceSpec{
+      Name: strings.TrimSpace(kv[0]),
+      Weight: weight,
+    })
+  }
+
+  // Use the default weights of the plugins
+  if len(resources) == 0 {
+    return nil, nil
+  }
+
+  // The plugins decode their args from JSON
+  raw, err := json.Marshal(struct{ Resources []schedconfig.ResourceSpec }{resources})
+  if err != nil {
+    return nil, err
+  }
+
+  pluginConfig := make([]schedconfig.PluginConfig, 0)
+  for _, name := range([]string{noderesources.LeastAllocatedName, noderesources.MostAllocatedName, noderesources.BalancedAllocationName}){
+    pluginConfig = append(pluginConfig, schedconfig.PluginConfig{
+      Name: name,
+      Args: &runtime.Unknown{Raw: raw, ContentType: runtime.ContentTypeJSON},
+    })
+  }
+
+  return pluginConfig, nil
+}
+
+// Create the args of the LoadAware plugin from the metrics source, the Prometheus address and the
+// percentage of the score taken from the node usage
+func getLoadAwareConfig(source, prometheusURL, usageWeight string) ([]schedconfig.PluginConfig, error){
+
+  // Use the default args of the plugin
+  if len(source) == 0 && len(prometheusURL) == 0 && len(usageWeight) == 0 {
+    return nil, nil
+  }
+
+  args := schedconfig.LoadAwareArgs{
+    Source: source,
+    PrometheusURL: prometheusURL,
+  }
+
+  if len(usageWeight) != 0 {
+    weight, err := strconv.ParseInt(strings.TrimSpace(usageWeight), 10, 64)
+    if err != nil {
+      return nil, fmt.Errorf("Invalid load usage weight %q: %v", usageWeight, err)
+    }
+    args.UsageWeight = &weight
+  }
+
+  raw, err := json.Marshal(args)
+  if err != nil {
+    return nil, err
+  }
+
+  return []schedconfig.PluginConfig{{
+    Name: loadaware.Name,
+    Args: &runtime.Unknown{Raw: raw, ContentType: runtime.ContentTypeJSON},
+  }}, nil
+}
+
 // Retrieve the Kubernetes cluster client from outside of the cluster
 func getKubernetesClient() (kubernetes.Interface){
 	// construct the path to resolve to `~/.kube/config`
@@ -83,9 +194,9 @@ func getKubernetesClient() (kubernetes.Interface){
 }
 
 // Use to send a message to a message queue
-func SendToQueue(comm communication.Communication, message []byte, queue string){
+func SendToQueue(ctx context.Context, comm communication.Communication, message []byte, queue string){
   for {
-    if err := comm.Send(message,queue); err != nil {
+    if err := comm.SendWithContext(ctx,message,queue); err != nil {
 
       for{
         err = comm.Connect()
@@ -108,14 +219,25 @@ func AddPodEvent(
   typeOfError string){
 
   // Update API server to inform admin that the pod cannot be deployed and require manual intervention
+  createPodEvent(client, obj, "Error", message, typeOfError)
+}
+
+// Add a FailedScheduling event explaining why the pod could not be scheduled
+func AddFailedSchedulingEvent(client kubernetes.Interface, obj *corev1.Pod, message string){
+  createPodEvent(client, obj, FailedSchedulingReason, message, corev1.EventTypeWarning)
+}
+
+// Create an event of the pod with the given reason and type
+func createPodEvent(client kubernetes.Interface, obj *corev1.Pod, reason string, message string, eventType string){
+
   timestamp := time.Now().UTC()
   client.CoreV1().Events(obj.Namespace).Create(context.TODO(), &corev1.Event{
     Count:          1,
     Message:        message,
-    Reason:         "Error",
+    Reason:         reason,
     LastTimestamp:  metav1.NewTime(timestamp),
     FirstTimestamp: metav1.NewTime(timestamp),
-    Type:           typeOfError,
+    Type:           eventType,
     Source: corev1.EventSource{
       Component: "custom",
     },
@@ -131,6 +253,51 @@ func AddPodEvent(
   },metav1.CreateOptions{})
 }
 
+// Set the PodScheduled condition of the pod to false with the reason the pod could not be
+// scheduled. The reason is Unschedulable if no node fits the pod and SchedulerError otherwise.
+// Returns false if the condition is unchanged.
+func setUnschedulableCondition(status *corev1.PodStatus, err error) bool{
+
+  reason := sched.SchedulerError
+  if _, ok := err.(*sched.FitError); ok {
+    reason = corev1.PodReasonUnschedulable
+  }
+
+  return podutil.UpdatePodCondition(status, &corev1.PodCondition{
+    Type:    corev1.PodScheduled,
+    Status:  corev1.ConditionFalse,
+    Reason:  reason,
+    Message: err.Error(),
+  })
+}
+
+// Explain why the pod could not be scheduled in its PodScheduled condition and in a
+// FailedScheduling event
+func RecordSchedulingFailure(client kubernetes.Interface, pod *corev1.Pod, err error){
+
+  AddFailedSchedulingEvent(client, pod, err.Error())
+
+  // The latest version of the pod is updated as the status may have changed since the pod was fetched
+  retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+
+    current, getErr := client.CoreV1().Pods(pod.Namespace).Get(context.TODO(), pod.Name, metav1.GetOptions{})
+    if getErr != nil {
+      return getErr
+    }
+
+    if !setUnschedulableCondition(&current.Status, err) {
+      return nil
+    }
+
+    _, updateErr := client.CoreV1().Pods(pod.Namespace).UpdateStatus(context.TODO(), current, metav1.UpdateOptions{})
+    return updateErr
+  })
+
+  if retryErr != nil {
+    log.Errorf("Unable to update the PodScheduled condition of %s/%s: %s", pod.Namespace, pod.Name, retryErr.Error())
+  }
+}
+
 // Add a new status for a pod
 func AddPodStatus(
   client kubernetes.Interface,
@@ -250,7 +417,10 @@ func computePodResourceRequest(pod *corev1.Pod) *framework.Resource {
 }
 
 // Use to bind the pod to the selected node
-func bind(client kubernetes.Interface, p corev1.Pod, NodeName string, discoverTime time.Duration, schedTime time.Time) *framework.Status{
+func bind(ctx context.Context, client kubernetes.Interface, p corev1.Pod, NodeName string, discoverTime time.Duration, schedTime time.Time) *framework.Status{
+
+  ctx, span := communication.Tracer().Start(ctx, "Bind", trace.WithAttributes(attribute.String("node", NodeName)))
+  defer span.End()
 
   if p.Annotations != nil {
     p.Annotations["epsilon.discover.time"]=discoverTime.String()
@@ -272,9 +442,17 @@ func bind(client kubernetes.Interface, p corev1.Pod, NodeName string, discoverTi
 		Target:     corev1.ObjectReference{Kind: "Node", Name: NodeName},
 	}
 
-	err := client.CoreV1().Pods(binding.Namespace).Bind(context.TODO(), binding, metav1.CreateOptions{})
+	bindingStart := time.Now()
+	err := client.CoreV1().Pods(binding.Namespace).Bind(ctx, binding, metav1.CreateOptions{})
+	metrics.BindingLatency.Observe(metrics.SinceInSeconds(bindingStart))
 	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		metrics.PodScheduleErrors.Inc()
 		return framework.NewStatus(framework.Error, err.Error())
 	}
+
+	metrics.PodScheduleSuccesses.Inc()
+	metrics.E2eSchedulingLatency.Observe(metrics.SinceInSeconds(schedTime))
+	metrics.PodSchedulingDuration.Observe(metrics.SinceInSeconds(p.CreationTimestamp.Time))
 	return nil
 }
@@ -18,11 +18,14 @@ package main
 
 import (
   "time"
+  "context"
   "fmt"
   "os"
   "strconv"
   "math/rand"
+  "net/http"
   "github.com/alexnjh/epsilon/general_purpose_scheduler/k8s.io/kubernetes/pkg/controller/volume/scheduling"
+  "github.com/alexnjh/epsilon/general_purpose_scheduler/scheduler/metrics"
   corev1 "k8s.io/api/core/v1"
   log "github.com/sirupsen/logrus"
   sched "github.com/alexnjh/epsilon/general_purpose_scheduler/scheduler"
@@ -31,6 +34,7 @@ import (
   internalcache "github.com/alexnjh/epsilon/general_purpose_scheduler/internal/cache"
   configparser "github.com/bigkevmcd/go-configparser"
   communication "github.com/alexnjh/epsilon/communication"
+  tracing "github.com/alexnjh/epsilon/communication/tracing"
 )
 
 const (
@@ -40,7 +44,11 @@ const (
   QPS = 100
   Burst = 200
   PodBackoffExceeded corev1.PodPhase = "PodBackoffExceeded"
+  // Reason of the events explaining why a pod could not be scheduled
+  FailedSchedulingReason = "FailedScheduling"
   DefaultConfigPath = "/go/src/app/config.cfg"
+  // Port of the metrics server if none is configured
+  DefaultMetricsPort = "8080"
 
 )
 
@@ -65,7 +73,18 @@ starts the scheduling lifecycle.
 */
 func main() {
 
+  // Run the scheduling framework offline instead of serving the queue
+  if len(os.Args) > 1 && os.Args[1] == "simulate" {
+    if err := simulate(os.Args[2:]); err != nil {
+      log.Fatalf(err.Error())
+    }
+    return
+  }
+
   var mqHost, mqPort, mqUser, mqPass, receiveQueue, backoffQueue, hostname string
+  var resourceScorers, resourceWeights string
+  var loadMetricsSource, prometheusURL, loadUsageWeight string
+  var metricsPort string
   var maxBackOff = MaxBackOffTime
   var config *configparser.ConfigParser
   var err error
@@ -92,6 +111,12 @@ func main() {
     mqPass = os.Getenv("MQ_PASS")
     receiveQueue = os.Getenv("RECEIVE_QUEUE")
     backoffQueue = os.Getenv("RETRY_QUEUE")
+    resourceScorers = os.Getenv("RESOURCE_SCORERS")
+    resourceWeights = os.Getenv("RESOURCE_WEIGHTS")
+    loadMetricsSource = os.Getenv("LOAD_METRICS_SOURCE")
+    prometheusURL = os.Getenv("PROMETHEUS_URL")
+    loadUsageWeight = os.Getenv("LOAD_USAGE_WEIGHT")
+    metricsPort = os.Getenv("METRICS_PORT")
 
     if len(mqHost) == 0 ||
     len(mqPort) == 0 ||
@@ -144,8 +169,32 @@ func main() {
         log.Errorf(err.Error())
       }
     }
+    // Get the resource score plugins and resource weights if exist
+    resourceScorers, _ = config.Get("DEFAULTS", "resource_scorers")
+    resourceWeights, _ = config.Get("DEFAULTS", "resource_weights")
+    // Get the settings of the LoadAware plugin if exist
+    loadMetricsSource, _ = config.Get("DEFAULTS", "load_metrics_source")
+    prometheusURL, _ = config.Get("DEFAULTS", "prometheus_url")
+    loadUsageWeight, _ = config.Get("DEFAULTS", "load_usage_weight")
+    // Get the port of the metrics server if exist
+    metricsPort, _ = config.Get("DEFAULTS", "metrics_port")
+  }
+
+  if len(metricsPort) == 0 {
+    metricsPort = DefaultMetricsPort
   }
 
+  // Register the scheduler metrics and serve them labelled with the queue and the replica
+  metrics.Register()
+  go metricsServer(metricsPort, receiveQueue, hostname)
+
+  // Propagate the trace context of the pods and export the spans if a collector is configured
+  shutdown, err := tracing.Setup("general-purpose-scheduler")
+  if err != nil {
+    log.Fatalf(err.Error())
+  }
+  defer shutdown(context.Background())
+
   // Get the Kubernetes client for communicating with API server
 	client := getKubernetesClient()
 
@@ -197,8 +246,20 @@ func main() {
   // Create a cache for the scheduler
   schedulerCache := internalcache.New(30*time.Second, stopCh)
 
+  // Create the args of the resource score plugins
+  pluginConfig, err := getResourceWeightsConfig(resourceWeights)
+  if err != nil {
+    log.Fatalf(err.Error())
+  }
+
+  loadAwareConfig, err := getLoadAwareConfig(loadMetricsSource, prometheusURL, loadUsageWeight)
+  if err != nil {
+    log.Fatalf(err.Error())
+  }
+  pluginConfig = append(pluginConfig, loadAwareConfig...)
+
   // Create scheduler object
-  main_sched, err := sched.New(volumeBinder, client, schedulerCache, kubefactory, node_lister, pod_lister, false, 10.0)
+  main_sched, err := sched.New(volumeBinder, client, schedulerCache, kubefactory, node_lister, pod_lister, false, 10.0, pluginConfig, getResourceScorers(resourceScorers))
 
   // Scheduler initialization failed
   if err != nil {
@@ -245,3 +306,12 @@ func main() {
     }
   }
 }
+
+/*
+Creates a prometheus based metrics server exporting the scheduler metrics
+*/
+func metricsServer(port, queue, replica string){
+  // "/metrics" is the usual endpoint for that.
+  http.Handle("/metrics", metrics.Handler(queue, replica))
+  log.Fatal(http.ListenAndServe(":"+port, nil))
+}
@@ -32,9 +32,12 @@ import (
   corev1 "k8s.io/api/core/v1"
   log "github.com/sirupsen/logrus"
   sched "github.com/alexnjh/epsilon/general_purpose_scheduler/scheduler"
+  "github.com/alexnjh/epsilon/general_purpose_scheduler/scheduler/metrics"
   corelisters "k8s.io/client-go/listers/core/v1"
   metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
   communication "github.com/alexnjh/epsilon/communication"
+  "go.opentelemetry.io/otel/attribute"
+  "go.opentelemetry.io/otel/trace"
 )
 
 /*
@@ -66,6 +69,7 @@ func ScheduleProcess(
 
     // Record time of processing
     timestamp := time.Now()
+    metrics.SchedulerQueueIncomingPods.WithLabelValues(receiveQueue, "Received").Inc()
 
     // Convert json message to schedule request object
     var req communication.ScheduleRequest
@@ -78,6 +82,10 @@ func ScheduleProcess(
     // Extract the pod name and namespace from the request
     key := string(req.Key);
 
+    // Continue the trace of the pod started by the coordinator
+    ctx, span := communication.Tracer().Start(communication.ExtractContext(d, receiveQueue), "SchedulePod",
+      trace.WithAttributes(attribute.String("pod", key), attribute.String("replica", hostname)))
+
     // Convert the namespace/name string into a distinct namespace and name
     namespace, name, err := cache.SplitMetaNamespaceKey(key)
     if err != nil {
@@ -93,19 +101,28 @@ func ScheduleProcess(
       // log.Infof("Scheduling %s",obj.Name)
 
       // Start scheduling the pod
-      result, err := s.Schedule(context.TODO(), obj)
+      metrics.ActivePods().Inc()
+      algorithmStart := time.Now()
+      result, err := s.Schedule(ctx, obj)
+      metrics.SchedulingAlgorithmLatency.Observe(metrics.SinceInSeconds(algorithmStart))
+      metrics.ActivePods().Dec()
 
       if err != nil {
 
+        metrics.PodScheduleFailures.Inc()
+
         // Print the error in the event the scheduler is unable to schedule the pod
         log.Errorf("%s", err)
 
         // Check scheduling request last back off time and check if it exceeds the maximum backoff time
         if (req.NextBackOffTime > maxBackOff){
 
-          go AddPodEvent(client,obj,fmt.Sprintf("Scheduler will not retry scheduling; Reason: %s",err.Error()),"Fatal")
+          // A single FailedScheduling event tells that the pod will not be retried
+          go AddFailedSchedulingEvent(client,obj.DeepCopy(),fmt.Sprintf("Scheduler will not retry scheduling; Reason: %s",err.Error()))
 
+          // The condition is updated along with the phase
           obj.Status.Phase = PodBackoffExceeded
+          setUnschedulableCondition(&obj.Status, err)
 
           go AddPodStatus(client,obj,metav1.UpdateOptions{})
 
@@ -113,15 +130,17 @@ func ScheduleProcess(
 
           req.Message = err.Error()
 
+          // Explain the failure in the pod condition and a FailedScheduling event
+          go RecordSchedulingFailure(client,obj.DeepCopy(),err)
+
           respBytes, err := json.Marshal(communication.RetryRequest{req,receiveQueue})
           if err != nil {
             log.Fatalf("%s", err)
           }
 
-          go AddPodEvent(client,obj,fmt.Sprintf("Scheduler will retry in %d seconds; Reason: %s",req.NextBackOffTime,req.Message),"Warning")
-
           // Attempt to send message to retry service
-          go SendToQueue(comm,respBytes,backoffQueue)
+          metrics.SchedulerQueueIncomingPods.WithLabelValues(backoffQueue, "Retry").Inc()
+          go SendToQueue(ctx,comm,respBytes,backoffQueue)
         }
 
         d.Ack(true)
@@ -133,13 +152,15 @@ func ScheduleProcess(
 
         // Run premption process?
         if (result.NorminatedPod != nil){
-          PreemptionProcess(client,result.SuggestedHost,obj,result.NorminatedPod,int64(30),req.ProcessedTime,timestamp,result.NorminatedPod.Name)
+          metrics.PreemptionAttempts.Inc()
+          metrics.PreemptionVictims.Observe(1)
+          PreemptionProcess(ctx,client,result.SuggestedHost,obj,result.NorminatedPod,int64(30),req.ProcessedTime,timestamp,result.NorminatedPod.Name)
           // //Use for experiment only
           // go SendExperimentPayload(comm,obj,timestamp,time.Now(),"epsilon.experiment",result.SuggestedHost,hostname)
 
         }else{
           // log.Infof("Scheduling Pod %s to %s", name, result.SuggestedHost)
-          go bind(client,*obj,result.SuggestedHost,req.ProcessedTime,timestamp)
+          go bind(ctx,client,*obj,result.SuggestedHost,req.ProcessedTime,timestamp)
           // //Use for experiment only
           // go SendExperimentPayload(comm,obj,timestamp,time.Now(),"epsilon.experiment",result.SuggestedHost,hostname)
         }
@@ -149,6 +170,8 @@ func ScheduleProcess(
     }else{
       d.Ack(true)
     }
+
+    span.End()
   }
 
   closed <- true
@@ -167,6 +190,7 @@ The preemption process consist of the following steps:
 
 */
 func PreemptionProcess(
+  ctx context.Context,
   client kubernetes.Interface,
   suggestedHost string,
   preemptorpod *corev1.Pod,
@@ -178,6 +202,10 @@ func PreemptionProcess(
 
   log.Infof("Starting preemption logic")
 
+  ctx, span := communication.Tracer().Start(ctx, "Preemption",
+    trace.WithAttributes(attribute.String("node", suggestedHost), attribute.String("victim", nominatedpod.Namespace+"/"+nominatedpod.Name)))
+  defer span.End()
+
   // Add a resource reservation by updating node status
   AddNodeStatusofPreemption(client,suggestedHost,preemptorpod,nominatedpod);
 
@@ -196,7 +224,7 @@ func PreemptionProcess(
     _, err := client.CoreV1().Pods(nominatedpod.Namespace).Get(context.TODO(), nominatedpod.Name, metav1.GetOptions{})
 
     if err != nil && errors.IsNotFound(err) {
-      bind(client,*preemptorpod,suggestedHost,discoverTime,schedTime)
+      bind(ctx,client,*preemptorpod,suggestedHost,discoverTime,schedTime)
       RemovePreemptionInfo(client,suggestedHost,key)
       return
     }
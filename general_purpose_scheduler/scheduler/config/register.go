@@ -40,8 +40,12 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 		&KubeSchedulerConfiguration{},
 		&Policy{},
 		&InterPodAffinityArgs{},
+		&LoadAwareArgs{},
 		&NodeLabelArgs{},
+		&NodeResourcesBalancedAllocationArgs{},
 		&NodeResourcesFitArgs{},
+		&NodeResourcesLeastAllocatedArgs{},
+		&NodeResourcesMostAllocatedArgs{},
 		&PodTopologySpreadArgs{},
 		&RequestedToCapacityRatioArgs{},
 		&ServiceAffinityArgs{},
@@ -34,6 +34,30 @@ type InterPodAffinityArgs struct {
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
+// LoadAwareArgs holds arguments used to configure the LoadAware plugin.
+type LoadAwareArgs struct {
+	metav1.TypeMeta
+
+	// Source of the node usage, "metrics-api" (metrics.k8s.io) or "prometheus".
+	// Defaults to "metrics-api".
+	Source string
+	// PrometheusURL is the address of the Prometheus server, required by the "prometheus" source.
+	PrometheusURL string
+	// CPUQuery and MemoryQuery are the PromQL queries returning the CPU usage in cores and the
+	// memory usage in bytes of every node, with the node name in the "node" label.
+	CPUQuery    string
+	MemoryQuery string
+	// UsageWeight is the percentage of the score taken from the usage of the nodes, the rest is
+	// taken from the requests of the pods. Defaults to 50.
+	UsageWeight *int64
+	// RefreshIntervalSeconds is how often the usage is fetched. Defaults to 15.
+	RefreshIntervalSeconds int64
+	// MaxStalenessSeconds is the age after which the usage of a node is ignored. Defaults to 120.
+	MaxStalenessSeconds int64
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
 // NodeLabelArgs holds arguments used to configure the NodeLabel plugin.
 type NodeLabelArgs struct {
 	metav1.TypeMeta
@@ -50,6 +74,16 @@ type NodeLabelArgs struct {
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
+// NodeResourcesBalancedAllocationArgs holds arguments used to configure the NodeResourcesBalancedAllocation plugin.
+type NodeResourcesBalancedAllocationArgs struct {
+	metav1.TypeMeta
+
+	// Resources to be scored and their weights, CPU and memory with a weight of 1 by default.
+	Resources []ResourceSpec
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
 // NodeResourcesFitArgs holds arguments used to configure the NodeResourcesFit plugin.
 type NodeResourcesFitArgs struct {
 	metav1.TypeMeta
@@ -61,6 +95,26 @@ type NodeResourcesFitArgs struct {
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
+// NodeResourcesLeastAllocatedArgs holds arguments used to configure the NodeResourcesLeastAllocated plugin.
+type NodeResourcesLeastAllocatedArgs struct {
+	metav1.TypeMeta
+
+	// Resources to be scored and their weights, CPU and memory with a weight of 1 by default.
+	Resources []ResourceSpec
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// NodeResourcesMostAllocatedArgs holds arguments used to configure the NodeResourcesMostAllocated plugin.
+type NodeResourcesMostAllocatedArgs struct {
+	metav1.TypeMeta
+
+	// Resources to be scored and their weights, CPU and memory with a weight of 1 by default.
+	Resources []ResourceSpec
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
 // PodTopologySpreadArgs holds arguments used to configure the PodTopologySpread plugin.
 type PodTopologySpreadArgs struct {
 	metav1.TypeMeta
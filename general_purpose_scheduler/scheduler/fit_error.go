@@ -0,0 +1,99 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+Modifications copyright (C) 2020 Alex Neo
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+  "fmt"
+  "sort"
+  "strings"
+  "sync"
+
+  v1 "k8s.io/api/core/v1"
+  framework "github.com/alexnjh/epsilon/general_purpose_scheduler/framework/v1alpha1"
+)
+
+const (
+  // NoNodeAvailableMsg is used to format message when no nodes available.
+  NoNodeAvailableMsg = "0/%v nodes are available"
+)
+
+// FitError describes a fit error of a pod, the filter status of every node the pod does not fit
+// on is kept to explain the failure.
+type FitError struct {
+  Pod                   *v1.Pod
+  NumAllNodes           int
+  FilteredNodesStatuses framework.NodeToStatusMap
+
+  // Why preemption could not make room for the pod, empty if preemption was not attempted
+  PreemptionMessage string
+}
+
+// Error returns detailed information of why the pod failed to fit on each node, the nodes are
+// counted by reason (eg. 0/3 nodes are available: 1 Insufficient cpu, 2 node(s) didn't match node selector.)
+func (f *FitError) Error() string {
+  reasons := make(map[string]int)
+  for _, status := range f.FilteredNodesStatuses {
+    for _, reason := range status.Reasons() {
+      reasons[reason]++
+    }
+  }
+
+  reasonStrings := make([]string, 0, len(reasons))
+  for reason, count := range reasons {
+    reasonStrings = append(reasonStrings, fmt.Sprintf("%v %v", count, reason))
+  }
+  sort.Strings(reasonStrings)
+
+  msg := fmt.Sprintf(NoNodeAvailableMsg+": %v.", f.NumAllNodes, strings.Join(reasonStrings, ", "))
+  if len(reasonStrings) == 0 {
+    msg = fmt.Sprintf(NoNodeAvailableMsg+".", f.NumAllNodes)
+  }
+  if len(f.PreemptionMessage) != 0 {
+    msg += " preemption: " + f.PreemptionMessage + "."
+  }
+  return msg
+}
+
+// nodeStatuses collects the filter statuses of the nodes a pod does not fit on, the nodes are
+// filtered concurrently.
+type nodeStatuses struct {
+  mu       sync.Mutex
+  statuses framework.NodeToStatusMap
+}
+
+func newNodeStatuses() *nodeStatuses {
+  return &nodeStatuses{statuses: make(framework.NodeToStatusMap)}
+}
+
+// Record the status of a node the pod does not fit on
+func (n *nodeStatuses) add(nodeName string, status *framework.Status) {
+  n.mu.Lock()
+  defer n.mu.Unlock()
+  n.statuses[nodeName] = status
+}
+
+// Returns a copy of the statuses collected so far
+func (n *nodeStatuses) get() framework.NodeToStatusMap {
+  n.mu.Lock()
+  defer n.mu.Unlock()
+  statuses := make(framework.NodeToStatusMap, len(n.statuses))
+  for node, status := range n.statuses {
+    statuses[node] = status
+  }
+  return statuses
+}
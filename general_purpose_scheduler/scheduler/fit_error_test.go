@@ -0,0 +1,87 @@
+/*
+Copyright (C) 2020 Alex Neo
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	framework "github.com/alexnjh/epsilon/general_purpose_scheduler/framework/v1alpha1"
+)
+
+func TestFitErrorMessage(t *testing.T) {
+	memory := framework.NewStatus(framework.Unschedulable, "Insufficient memory")
+	taint := framework.NewStatus(framework.UnschedulableAndUnresolvable, "node(s) had taint {dedicated: gpu}, that the pod didn't tolerate")
+	both := framework.NewStatus(framework.Unschedulable, "Insufficient cpu", "Insufficient memory")
+
+	tests := []struct {
+		name string
+		err  *FitError
+		want string
+	}{
+		{
+			name: "reasons counted by node",
+			err: &FitError{NumAllNodes: 4, FilteredNodesStatuses: framework.NodeToStatusMap{
+				"node-1": memory, "node-2": memory, "node-3": taint, "node-4": both,
+			}},
+			want: "0/4 nodes are available: 1 Insufficient cpu, 1 node(s) had taint {dedicated: gpu}, that the pod didn't tolerate, 3 Insufficient memory.",
+		},
+		{
+			name: "no nodes",
+			err:  &FitError{},
+			want: "0/0 nodes are available.",
+		},
+		{
+			name: "preemption",
+			err: &FitError{NumAllNodes: 1, FilteredNodesStatuses: framework.NodeToStatusMap{"node-1": memory},
+				PreemptionMessage: "Pod priority too low to preempt other pods"},
+			want: "0/1 nodes are available: 1 Insufficient memory. preemption: Pod priority too low to preempt other pods.",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.err.Error(); got != test.want {
+				t.Errorf("expected %q, got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestNodeStatusesConcurrent(t *testing.T) {
+	statuses := newNodeStatuses()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			statuses.add(fmt.Sprintf("node-%d", i), framework.NewStatus(framework.Unschedulable, "Insufficient cpu"))
+		}(i)
+	}
+	wg.Wait()
+
+	got := statuses.get()
+	if len(got) != 50 {
+		t.Fatalf("expected 50 statuses, got %d", len(got))
+	}
+	statuses.add("node-50", nil)
+	if len(got) != 50 {
+		t.Errorf("expected a copy of the statuses")
+	}
+}
@@ -0,0 +1,81 @@
+/*
+Copyright (C) 2020 Alex Neo
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+const (
+	// QueueLabel is the label added to every series with the queue the scheduler receives the pods from.
+	QueueLabel = "queue_name"
+	// ReplicaLabel is the label added to every series with the name of the scheduler replica.
+	ReplicaLabel = "replica"
+)
+
+// Handler returns an HTTP handler serving the registered metrics. Every series is labelled with
+// the queue and the replica so that the series of the scheduler replicas of the different queues
+// can be told apart once aggregated.
+func Handler(queue, replica string) http.Handler {
+	return promhttp.HandlerFor(
+		NewLabelledGatherer(legacyregistry.DefaultGatherer, map[string]string{QueueLabel: queue, ReplicaLabel: replica}),
+		promhttp.HandlerOpts{},
+	)
+}
+
+// labelledGatherer adds constant labels to the metrics of a gatherer.
+type labelledGatherer struct {
+	gatherer metrics.Gatherer
+	labels   []*dto.LabelPair
+}
+
+// NewLabelledGatherer returns a gatherer adding the labels to every metric gathered by g. The
+// labels already set on a metric are kept.
+func NewLabelledGatherer(g metrics.Gatherer, labels map[string]string) metrics.Gatherer {
+	pairs := make([]*dto.LabelPair, 0, len(labels))
+	for name, value := range labels {
+		name, value := name, value
+		pairs = append(pairs, &dto.LabelPair{Name: &name, Value: &value})
+	}
+	return &labelledGatherer{gatherer: g, labels: pairs}
+}
+
+func (g *labelledGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.gatherer.Gather()
+	for _, family := range families {
+		for _, m := range family.Metric {
+			existing := make(map[string]bool, len(m.Label))
+			for _, l := range m.Label {
+				existing[l.GetName()] = true
+			}
+			for _, l := range g.labels {
+				if !existing[l.GetName()] {
+					m.Label = append(m.Label, l)
+				}
+			}
+			// The exposition format expects the labels sorted by name
+			sort.Slice(m.Label, func(i, j int) bool { return m.Label[i].GetName() < m.Label[j].GetName() })
+		}
+	}
+	return families, err
+}
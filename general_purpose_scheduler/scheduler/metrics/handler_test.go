@@ -0,0 +1,71 @@
+/*
+Copyright (C) 2020 Alex Neo
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"k8s.io/component-base/metrics"
+)
+
+func TestLabelledGatherer(t *testing.T) {
+	registry := metrics.NewKubeRegistry()
+	counter := metrics.NewCounterVec(&metrics.CounterOpts{Name: "test_total", Help: "Test counter"}, []string{"result", ReplicaLabel})
+	registry.MustRegister(counter)
+	counter.WithLabelValues("ok", "own").Inc()
+
+	families, err := NewLabelledGatherer(registry, map[string]string{QueueLabel: "epsilon.distributed", ReplicaLabel: "scheduler-0"}).Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(families) != 1 || len(families[0].Metric) != 1 {
+		t.Fatalf("expected one metric, got %v", families)
+	}
+
+	got := map[string]string{}
+	var names []string
+	for _, l := range families[0].Metric[0].Label {
+		got[l.GetName()] = l.GetValue()
+		names = append(names, l.GetName())
+	}
+	want := map[string]string{QueueLabel: "epsilon.distributed", ReplicaLabel: "own", "result": "ok"}
+	for name, value := range want {
+		if got[name] != value {
+			t.Errorf("expected label %s=%q, got %q", name, value, got[name])
+		}
+	}
+	if strings.Join(names, ",") != "queue_name,replica,result" {
+		t.Errorf("expected sorted labels, got %v", names)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	Register()
+	PodScheduleSuccesses.Inc()
+
+	rec := httptest.NewRecorder()
+	Handler("epsilon.distributed", "scheduler-0").ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	body, _ := ioutil.ReadAll(rec.Body)
+	want := `scheduler_schedule_attempts_total{queue_name="epsilon.distributed",replica="scheduler-0",result="scheduled"} 1`
+	if !strings.Contains(string(body), want) {
+		t.Errorf("expected %q in the metrics", want)
+	}
+}
@@ -32,9 +32,12 @@ import (
   framework "github.com/alexnjh/epsilon/general_purpose_scheduler/framework/v1alpha1"
   internalcache "github.com/alexnjh/epsilon/general_purpose_scheduler/internal/cache"
   clientset "k8s.io/client-go/kubernetes"
-  pcglib "github.com/MichaelTJones/pcg"
+  config "github.com/alexnjh/epsilon/general_purpose_scheduler/scheduler/config"
 
   "github.com/alexnjh/epsilon/general_purpose_scheduler/k8s.io/kubernetes/pkg/controller/volume/scheduling"
+  communication "github.com/alexnjh/epsilon/communication"
+  "go.opentelemetry.io/otel/attribute"
+  "go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -77,6 +80,10 @@ type Scheduler struct {
 
   //Percentage to node score
   percentageNodeScore int
+
+  // Source of the node sampling and of the tie-break, guarded by randLock
+  rand *rand.Rand
+  randLock sync.Mutex
 }
 
 // Invokes the scheduling routine
@@ -95,48 +102,69 @@ func (s *Scheduler) Schedule(con context.Context, pod *v1.Pod) (scheduleResult S
   viableNodes := make([]*v1.Node, 0)
   var lenOfArr float64 = float64(len(nodeList))
 
+  // Statuses of the nodes the pod does not fit on, used to explain why the pod cannot be scheduled
+  statuses := newNodeStatuses()
+  numAllNodes := len(nodeList)
 
-  // Check number of nodes and select a subset if length of node list exceed 50
-  if(lenOfArr > 50){
+  _, filterSpan := communication.Tracer().Start(con, "Filter", trace.WithAttributes(attribute.Int("nodes", len(nodeList))))
+  var filterErr error
 
-    // Generate random seed using current time
-    rand.Seed(time.Now().UnixNano())
+  // Sample the nodes unless all of them are scored
+  sample := lenOfArr > 50 && s.percentageNodeScore < 100
+
+  // Check number of nodes and select a subset if length of node list exceed 50
+  if(sample){
 
     // Fisher–Yates shuffle
     for i := len(nodeList) - 1; i > 0; i-- {
-        j := rand.Intn(i + 1)
+        j := int(s.randomIndex(uint64(i + 1)))
         nodeList[i], nodeList[j] = nodeList[j], nodeList[i]
     }
 
     lenOfArr = lenOfArr*(float64(s.percentageNodeScore)/100.0)+1
 
-    if(!s.processSubset(&nodeList,pod,&viableNodes,int(lenOfArr))){
-      return ScheduleResult{}, errors.New("Fail to schedule pod, Fail to select node from a subset of nodelist")
+    if(!s.processSubset(&nodeList,pod,&viableNodes,int(lenOfArr),statuses)){
+      filterErr = errors.New("Fail to schedule pod, Fail to select node from a subset of nodelist")
     }
 
   }else{
 
-    if(!s.processFullset(&nodeList,pod,&viableNodes)){
-      return ScheduleResult{}, errors.New("Fail to schedule pod, Fail to select node from nodelist")
+    if(!s.processFullset(&nodeList,pod,&viableNodes,statuses)){
+      filterErr = errors.New("Fail to schedule pod, Fail to select node from nodelist")
     }
 
   }
 
+  filterSpan.SetAttributes(attribute.Int("feasible_nodes", len(viableNodes)))
+  filterSpan.End()
+
+  if filterErr != nil {
+    return ScheduleResult{}, filterErr
+  }
+
   if(len(viableNodes) == 0){
 
+      fitErr := &FitError{Pod: pod, NumAllNodes: numAllNodes, FilteredNodesStatuses: statuses.get()}
+
       if *pod.Spec.Priority == 0 {
-        return ScheduleResult{}, errors.New("Pod priority too low to preempt other pods")
+        fitErr.PreemptionMessage = "Pod priority too low to preempt other pods"
+        return ScheduleResult{}, fitErr
       }
 
       if pod.Spec.PreemptionPolicy != nil && *pod.Spec.PreemptionPolicy == v1.PreemptNever {
-        return ScheduleResult{}, errors.New("Pod preeemption policy do not allow preemption")
+        fitErr.PreemptionMessage = "Pod preeemption policy do not allow preemption"
+        return ScheduleResult{}, fitErr
       }
 
+      // Every path below returns, so the span ends once the victim is selected
+      _, victimSpan := communication.Tracer().Start(con, "SelectVictim")
+      defer victimSpan.End()
+
       // Create array to store the nodes for selection
       viableNodes := make([]*framework.NodeInfo, 0)
 
       // Check number of nodes and select a subset if length of node list exceed 50
-      if(lenOfArr > 50){
+      if(sample){
         s.processSubsetPreemption(&nodeList,pod,&viableNodes,int(lenOfArr))
       }else{
         // Search for suitable nodes to select pods for premption
@@ -145,7 +173,8 @@ func (s *Scheduler) Schedule(con context.Context, pod *v1.Pod) (scheduleResult S
 
       // Check if a suitable node is found
       if (len(viableNodes) == 0){
-        return ScheduleResult{}, errors.New("Preemption not possible, no suitable node found")
+        fitErr.PreemptionMessage = "Preemption not possible, no suitable node found"
+        return ScheduleResult{}, fitErr
       }else{
 
         // Search all the pods in each viable node to select pod to preempt
@@ -164,7 +193,8 @@ func (s *Scheduler) Schedule(con context.Context, pod *v1.Pod) (scheduleResult S
           }
         }
 
-        return ScheduleResult{}, errors.New("Preemption not possible, No viable pod found to preempt")
+        fitErr.PreemptionMessage = "Preemption not possible, No viable pod found to preempt"
+        return ScheduleResult{}, fitErr
 
       }
 
@@ -173,69 +203,70 @@ func (s *Scheduler) Schedule(con context.Context, pod *v1.Pod) (scheduleResult S
 
   // Get each viable node's priority value
   cyclestate := framework.NewCycleState()
-  results, err := s.prioritizeNodes(context.TODO(), cyclestate, pod, viableNodes)
+  scoreCtx, scoreSpan := communication.Tracer().Start(con, "Score", trace.WithAttributes(attribute.Int("nodes", len(viableNodes))))
+  results, scores, err := s.prioritizeNodes(scoreCtx, cyclestate, pod, viableNodes)
+  scoreSpan.End()
 
   if err != nil {
     return ScheduleResult{}, err
   }
 
-  // Sort node list in descending order
+  // Sort node list in descending order, the nodes with the same score by name so that the
+  // tie-break only depends on the random source
   sort.Slice(results, func(i, j int) bool {
-    return results[i].Score > results[j].Score
+    if results[i].Score != results[j].Score {
+      return results[i].Score > results[j].Score
+    }
+    return results[i].Name < results[j].Name
   })
 
+  selectedNode := s.selectHost(results)
+  s.fw.IncreaseNodeUsageFactor(pod, selectedNode)
+
+  return ScheduleResult{
+    SuggestedHost: selectedNode,
+    NorminatedPod: nil,
+    Scores: scores,
+  }, nil
+}
+
+// Select the node with the highest score from the results sorted in descending order, the node
+// is selected randomly among the nodes with the highest score
+func (s *Scheduler) selectHost(results framework.NodeScoreList) string{
+
   if(len(results) == 1){
-    selectedNode := results[0].Name
-    go s.fw.IncreaseNodeUsageFactor(selectedNode)
-    return ScheduleResult{results[0].Name,nil},nil
+    return results[0].Name
   }
 
   if(results[0].Score != results[1].Score){
-    selectedNode := results[0].Name
-    go s.fw.IncreaseNodeUsageFactor(selectedNode)
-    return ScheduleResult{results[0].Name,nil},nil
+    return results[0].Name
   }
 
-  // Select node randomly based on PCG
-  pcg := pcglib.NewPCG64()
-
-  if results[0].Score != results[(len(results)-1)].Score {
-    for idx, value := range results[1:] {
-      if (results[0].Score != value.Score){
-
-        index := pcg.Bounded(uint64(idx))
-        selectedNode := results[index].Name
-        go s.fw.IncreaseNodeUsageFactor(selectedNode)
+  // Select node randomly among the nodes with the highest score
+  for idx, value := range results[1:] {
+    if (results[0].Score != value.Score){
 
+      // results[0] to results[idx] have the highest score
+      index := s.randomIndex(uint64(idx+1))
 
-        // selectedNode := s.selectNodeBasedOnRepeatScore(results[:idx+1])
+      // selectedNode := s.selectNodeBasedOnRepeatScore(results[:idx+1])
 
-        return ScheduleResult{
-          SuggestedHost: selectedNode,
-          NorminatedPod: nil,
-        }, nil
-      }
+      return results[index].Name
     }
   }
 
-  index := pcg.Bounded(uint64((len(results)-1)))
-  selectedNode := results[index].Name
-  go s.fw.IncreaseNodeUsageFactor(selectedNode)
-
-
-  return ScheduleResult{
-    SuggestedHost: selectedNode,
-    NorminatedPod: nil,
-  }, nil
+  index := s.randomIndex(uint64(len(results)))
+  return results[index].Name
 }
 
-// Function use to generate priority values for each node
+// Function use to generate priority values for each node, the scores given by each plugin are
+// returned along with the total score of each node
 func (s *Scheduler) prioritizeNodes(
   ctx context.Context,
 	state *framework.CycleState,
 	pod *v1.Pod,
 	nodes []*v1.Node,
-) (framework.NodeScoreList, error) {
+) (framework.NodeScoreList, framework.PluginToNodeScores, error) {
 
 
   status := s.fw.RunPreScorePlugins(context.TODO(), state, pod, nodes)
@@ -265,7 +296,7 @@ func (s *Scheduler) prioritizeNodes(
       })
     }
 
-    return scoreResults, nil
+    return scoreResults, ps, nil
 
 
   }else{
@@ -273,7 +304,7 @@ func (s *Scheduler) prioritizeNodes(
   }
 
 
-  return framework.NodeScoreList{}, nil
+  return framework.NodeScoreList{}, nil, nil
 
 }
 
@@ -287,11 +318,16 @@ func New(
   pod_lister  corelisters.PodLister,
   disablePreemption bool,
   percentageNodeScore int,
+  pluginConfig []config.PluginConfig,
+  resourceScorers []string,
   ) (*Scheduler, error){
 
 registry := plugins.NewInTreeRegistry()
 snapshot := internalcache.NewEmptySnapshot()
-fw, _ := framework.NewFramework(registry,client,snapshot,volumeBinder)
+fw, err := framework.NewFramework(registry,client,snapshot,volumeBinder,kubefactory,pluginConfig,resourceScorers)
+if err != nil {
+  return nil, err
+}
 
 return &Scheduler{
   client: client,
@@ -304,12 +340,34 @@ return &Scheduler{
   podLister: pod_lister,
   DisablePreemption: disablePreemption,
   percentageNodeScore: percentageNodeScore,
+  rand: rand.New(rand.NewSource(time.Now().UnixNano())),
 }, nil
 
 
 }
 
 
+// Seed the node sampling and the tie-break between the nodes with the same score, so that
+// the same cluster state gives the same placements (used by the simulator)
+func (s *Scheduler) SetSeed(seed int64){
+  s.randLock.Lock()
+  defer s.randLock.Unlock()
+  s.rand = rand.New(rand.NewSource(seed))
+}
+
+// Returns a random number in [0, bound)
+func (s *Scheduler) randomIndex(bound uint64) uint64{
+
+  if bound == 0 {
+    return 0
+  }
+
+  s.randLock.Lock()
+  defer s.randLock.Unlock()
+
+  return uint64(s.rand.Int63n(int64(bound)))
+}
+
 // Get a subset of nodes from nodelist
 func getSubset(a *[]*framework.NodeInfo, numSamples int) []*framework.NodeInfo {
 
@@ -338,20 +396,23 @@ func getSubset(a *[]*framework.NodeInfo, numSamples int) []*framework.NodeInfo {
 }
 
 // Run PreFilter and Filter plugins for a subset of nodes from nodelist
-func (s *Scheduler) processSubset(nodeList *[]*framework.NodeInfo, pod *v1.Pod, viableNodes *[]*v1.Node, numOfViable int) bool{
+func (s *Scheduler) processSubset(nodeList *[]*framework.NodeInfo, pod *v1.Pod, viableNodes *[]*v1.Node, numOfViable int, statuses *nodeStatuses) bool{
 
   if(len(*nodeList) == 0){
     return true
   }
 
-  var a = 0;
+  var wg sync.WaitGroup
   var mux sync.Mutex
   subset := getSubset(nodeList,numOfViable*2)
+  wg.Add(len(subset))
 
   for _ , n := range subset {
 
       go func(s *Scheduler, pod *v1.Pod, nodeInfo *framework.NodeInfo,viableNodes *[]*v1.Node, mux *sync.Mutex, numOfViable int) {
 
+        defer wg.Done()
+
         cyclestate := framework.NewCycleState()
 
         status := s.fw.RunPreFilterPlugins(context.TODO(), cyclestate, pod)
@@ -359,37 +420,35 @@ func (s *Scheduler) processSubset(nodeList *[]*framework.NodeInfo, pod *v1.Pod,
         if status.IsSuccess() {
           node := *nodeInfo.Node()
 
-          status := s.fw.RunFilterPlugins(context.TODO(), cyclestate, pod, nodeInfo)
+          status := s.fw.RunFilterPlugins(context.TODO(), cyclestate, pod, nodeInfo).Merge()
 
-          if status.Merge().IsSuccess() {
+          if status.IsSuccess() {
 
+            mux.Lock()
             if len(*viableNodes) <= numOfViable{
               *viableNodes = append(*viableNodes, &node)
             }
+            mux.Unlock()
+          }else{
+            statuses.add(node.Name, status)
           }
 
-          mux.Lock()
-          a++
-          mux.Unlock()
-
-
-
+        }else{
+          statuses.add(nodeInfo.Node().Name, status)
         }
 
       }(s,pod, n, viableNodes, &mux, numOfViable)
 
     }
 
-    for {
+    // Search the next subset if the nodes of this one are not enough
+    wg.Wait()
 
-          if len(*viableNodes) >= numOfViable  {
-            return true
-          }else if a == len(subset) && (len(*viableNodes) < numOfViable) {
-            s.processSubset(nodeList, pod, viableNodes, numOfViable)
-            return true
-          }
+    if len(*viableNodes) < numOfViable {
+      return s.processSubset(nodeList, pod, viableNodes, numOfViable, statuses)
     }
 
+    return true
 }
 
 
@@ -401,14 +460,17 @@ func (s *Scheduler) processSubsetPreemption(nodeList *[]*framework.NodeInfo, pod
     return true
   }
 
-  var a = 0;
+  var wg sync.WaitGroup
   var mux sync.Mutex
   subset := getSubset(nodeList,numOfViable*2)
+  wg.Add(len(subset))
 
   for _ , n := range subset {
 
       go func(s *Scheduler, pod *v1.Pod, nodeInfo *framework.NodeInfo,viableNodes *[]*framework.NodeInfo, mux *sync.Mutex, numOfViable int) {
 
+        defer wg.Done()
+
         cyclestate := framework.NewCycleState()
 
         status := s.fw.RunPreFilterPlugins(context.TODO(), cyclestate, pod)
@@ -418,36 +480,34 @@ func (s *Scheduler) processSubsetPreemption(nodeList *[]*framework.NodeInfo, pod
           status := s.fw.RunFilterPlugins(context.TODO(), cyclestate, pod, nodeInfo)
 
           if checkIfPreemptable(status) {
+            mux.Lock()
             *viableNodes = append(*viableNodes, nodeInfo)
+            mux.Unlock()
           }
 
-          mux.Lock()
-          a++
-          mux.Unlock()
-
         }
 
       }(s,pod, n, viableNodes, &mux, numOfViable)
 
     }
 
-    for {
+    // Search the next subset if the nodes of this one are not enough
+    wg.Wait()
 
-          if len(*viableNodes) >= numOfViable  {
-            return true
-          }else if a == len(subset) && (len(*viableNodes) < numOfViable) {
-            s.processSubsetPreemption(nodeList, pod, viableNodes, numOfViable)
-            return true
-          }
+    if len(*viableNodes) < numOfViable {
+      return s.processSubsetPreemption(nodeList, pod, viableNodes, numOfViable)
     }
 
+    return true
+
 }
 
 
 // Run PreFilter and Filter plugins for all nodes in the nodelist
-func (s *Scheduler) processFullset(nodeList *[]*framework.NodeInfo, pod *v1.Pod, viableNodes *[]*v1.Node) bool{
+func (s *Scheduler) processFullset(nodeList *[]*framework.NodeInfo, pod *v1.Pod, viableNodes *[]*v1.Node, statuses *nodeStatuses) bool{
 
 var wg sync.WaitGroup
+var mux sync.Mutex
 wg.Add(len(*nodeList))
 
 for _ , n := range *nodeList {
@@ -464,13 +524,19 @@ for _ , n := range *nodeList {
       if status.IsSuccess() {
         node := *nodeInfo.Node()
 
-        status := s.fw.RunFilterPlugins(context.TODO(), cyclestate, pod, nodeInfo)
+        status := s.fw.RunFilterPlugins(context.TODO(), cyclestate, pod, nodeInfo).Merge()
 
-        if status.Merge().IsSuccess() {
+        if status.IsSuccess() {
+          mux.Lock()
           *viableNodes = append(*viableNodes, &node)
+          mux.Unlock()
+        }else{
+          statuses.add(node.Name, status)
         }
 
 
+      }else{
+        statuses.add(nodeInfo.Node().Name, status)
       }
 
     }(s,pod, n, viableNodes)
@@ -487,6 +553,7 @@ for _ , n := range *nodeList {
 func (s *Scheduler) processFullsetPreemption(nodeList *[]*framework.NodeInfo, pod *v1.Pod, viableNodes *[]*framework.NodeInfo) bool{
 
 var wg sync.WaitGroup
+var mux sync.Mutex
 wg.Add(len(*nodeList))
 
 for _ , n := range *nodeList {
@@ -505,7 +572,9 @@ for _ , n := range *nodeList {
         status := s.fw.RunFilterPlugins(context.TODO(), cyclestate, pod, nodeInfo)
 
         if checkIfPreemptable(status) {
+          mux.Lock()
           *viableNodes = append(*viableNodes, nodeInfo)
+          mux.Unlock()
         }
       }
 
@@ -517,6 +586,16 @@ for _ , n := range *nodeList {
   return true
 }
 
+// Update the usage factors of the nodes with a pod seen in the pod informer
+func (s *Scheduler) ObservePod(pod *v1.Pod){
+  s.fw.ObservePodBinding(pod)
+}
+
+// Stop tracking a deleted pod in the usage factors of the nodes
+func (s *Scheduler) ForgetPod(pod *v1.Pod){
+  s.fw.ForgetPod(pod)
+}
+
 // Check if a node is capable to be used as a preemption node
 func checkIfPreemptable(status framework.PluginToStatus) bool{
 
@@ -0,0 +1,72 @@
+/*
+Copyright (C) 2020 Alex Neo
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"testing"
+
+	framework "github.com/alexnjh/epsilon/general_purpose_scheduler/framework/v1alpha1"
+)
+
+func TestSelectHostTieBreak(t *testing.T) {
+	tests := []struct {
+		name    string
+		results framework.NodeScoreList
+		want    []string
+	}{
+		{
+			name:    "highest score",
+			results: framework.NodeScoreList{{Name: "node-a", Score: 90}, {Name: "node-b", Score: 80}},
+			want:    []string{"node-a"},
+		},
+		{
+			name: "tied highest scores",
+			results: framework.NodeScoreList{
+				{Name: "node-a", Score: 90}, {Name: "node-b", Score: 90}, {Name: "node-c", Score: 90}, {Name: "node-d", Score: 80},
+			},
+			want: []string{"node-a", "node-b", "node-c"},
+		},
+		{
+			name: "all nodes tied",
+			results: framework.NodeScoreList{
+				{Name: "node-a", Score: 90}, {Name: "node-b", Score: 90}, {Name: "node-c", Score: 90},
+			},
+			want: []string{"node-a", "node-b", "node-c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scheduler{}
+			s.SetSeed(1)
+
+			selected := make(map[string]int)
+			for i := 0; i < 300; i++ {
+				selected[s.selectHost(tt.results)]++
+			}
+
+			if len(selected) != len(tt.want) {
+				t.Errorf("expected %v to be selected, got %v", tt.want, selected)
+			}
+			for _, name := range tt.want {
+				if selected[name] == 0 {
+					t.Errorf("expected %s to be selected, got %v", name, selected)
+				}
+			}
+		})
+	}
+}
@@ -18,6 +18,7 @@ package scheduler
 
 import (
   v1 "k8s.io/api/core/v1"
+  framework "github.com/alexnjh/epsilon/general_purpose_scheduler/framework/v1alpha1"
 )
 
 
@@ -45,4 +46,7 @@ type ScheduleResult struct {
 
   // Pod to terminate (Only used in preemption)
   NorminatedPod *v1.Pod
+
+  // Weighted score given by every score plugin to the feasible nodes (Not used in preemption)
+  Scores framework.PluginToNodeScores
 }
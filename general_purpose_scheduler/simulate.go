@@ -0,0 +1,200 @@
+/*
+
+Copyright (C) 2020 Alex Neo
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package main
+
+import(
+  stdjson "encoding/json"
+  "flag"
+  "fmt"
+  "io"
+  "os"
+  "sort"
+  "strings"
+  "text/tabwriter"
+
+  corev1 "k8s.io/api/core/v1"
+  "github.com/alexnjh/epsilon/general_purpose_scheduler/framework/plugins/loadaware"
+  "github.com/alexnjh/epsilon/general_purpose_scheduler/simulator"
+)
+
+/*
+
+The simulate command runs the scheduling framework offline against the nodes and pods of
+manifests or of a cluster dump (kubectl cluster-info dump --output-directory) and prints where
+every pending pod would be placed, the score given by every score plugin and why the pods that
+do not fit are unschedulable. Nothing is bound and no message queue is needed.
+
+  general_purpose_scheduler simulate -cluster dump/ [-pending pods.yaml]
+
+*/
+func simulate(args []string) error{
+
+  flags := flag.NewFlagSet("simulate", flag.ContinueOnError)
+  cluster := flags.String("cluster", "", "Comma separated manifest files or directories with the nodes and pods of the cluster")
+  pending := flags.String("pending", "", "Comma separated manifest files or directories with the pods to schedule, the unbound pods of the cluster by default")
+  resourceScorers := flags.String("resource-scorers", "ResourcePriority", "Comma separated plugins scoring the resources of the nodes")
+  resourceWeights := flags.String("resource-weights", "", "Weights of the resources scored by the NodeResources plugins, resource=weight,...")
+  prometheusURL := flags.String("prometheus-url", "", "Prometheus server the LoadAware plugin reads the usage of the nodes from")
+  loadUsageWeight := flags.String("load-usage-weight", "", "Percentage of the LoadAware score taken from the usage of the nodes")
+  output := flags.String("output", "text", "Output format, text or json")
+  top := flags.Int("top", 5, "Number of the highest scoring nodes shown for every pod in the text output, 0 for all")
+  seed := flags.Int64("seed", 1, "Seed of the tie-break between the nodes with the same score")
+
+  if err := flags.Parse(args); err != nil {
+    return err
+  }
+
+  if len(*cluster) == 0 {
+    return fmt.Errorf("The -cluster flag is required")
+  }
+
+  if *output != "text" && *output != "json" {
+    return fmt.Errorf("Unknown output format %q, expected text or json", *output)
+  }
+
+  scorers := getResourceScorers(*resourceScorers)
+
+  // The metrics API of the cluster is not available offline
+  for _, name := range(scorers){
+    if name == loadaware.Name && len(*prometheusURL) == 0 {
+      return fmt.Errorf("The %s plugin needs -prometheus-url when simulating", loadaware.Name)
+    }
+  }
+
+  pluginConfig, err := getResourceWeightsConfig(*resourceWeights)
+  if err != nil {
+    return err
+  }
+
+  if len(*prometheusURL) != 0 {
+    loadAwareConfig, err := getLoadAwareConfig(loadaware.SourcePrometheus, *prometheusURL, *loadUsageWeight)
+    if err != nil {
+      return err
+    }
+    pluginConfig = append(pluginConfig, loadAwareConfig...)
+  }
+
+  objects, err := simulator.LoadFiles(splitPaths(*cluster)...)
+  if err != nil {
+    return err
+  }
+
+  var pods []*corev1.Pod
+  if len(*pending) == 0 {
+    pods = simulator.PendingPods(objects)
+  }else{
+    pendingObjects, err := simulator.LoadFiles(splitPaths(*pending)...)
+    if err != nil {
+      return err
+    }
+    pods = simulator.PendingPods(pendingObjects)
+  }
+
+  sim, err := simulator.New(objects, pluginConfig, scorers, *seed)
+  if err != nil {
+    return err
+  }
+  defer sim.Close()
+
+  placements := sim.Run(pods)
+
+  if *output == "json" {
+    encoder := stdjson.NewEncoder(os.Stdout)
+    encoder.SetIndent("", "  ")
+    return encoder.Encode(placements)
+  }
+
+  return printPlacements(os.Stdout, placements, *top)
+}
+
+// Split a comma separated list of paths
+func splitPaths(value string) []string{
+
+  paths := make([]string, 0)
+
+  for _, path := range(strings.Split(value, ",")){
+    path = strings.TrimSpace(path)
+    if len(path) != 0 {
+      paths = append(paths, path)
+    }
+  }
+
+  return paths
+}
+
+// Print the placement of every pod followed by the scores of the highest scoring nodes
+func printPlacements(out io.Writer, placements []simulator.Placement, top int) error{
+
+  w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+
+  fmt.Fprintln(w, "POD\tNODE\tPREEMPTED\tREASON")
+  for _, p := range(placements){
+    fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", p.Pod, orNone(p.Node), orNone(p.Victim), orNone(p.Reason))
+  }
+
+  for _, p := range(placements){
+
+    if len(p.Scores) == 0 {
+      continue
+    }
+
+    plugins := make([]string, 0, len(p.Scores))
+    totals := make(map[string]int64)
+    for plugin, scores := range(p.Scores){
+      plugins = append(plugins, plugin)
+      for node, score := range(scores){
+        totals[node] += score
+      }
+    }
+    sort.Strings(plugins)
+
+    nodes := make([]string, 0, len(totals))
+    for node := range(totals){
+      nodes = append(nodes, node)
+    }
+    sort.Slice(nodes, func(i, j int) bool {
+      if totals[nodes[i]] != totals[nodes[j]] {
+        return totals[nodes[i]] > totals[nodes[j]]
+      }
+      return nodes[i] < nodes[j]
+    })
+    if top > 0 && len(nodes) > top {
+      nodes = nodes[:top]
+    }
+
+    fmt.Fprintf(w, "\nScores of %s\n", p.Pod)
+    fmt.Fprintf(w, "NODE\tTOTAL\t%s\n", strings.Join(plugins, "\t"))
+    for _, node := range(nodes){
+      fmt.Fprintf(w, "%s\t%d", node, totals[node])
+      for _, plugin := range(plugins){
+        fmt.Fprintf(w, "\t%d", p.Scores[plugin][node])
+      }
+      fmt.Fprintln(w)
+    }
+  }
+
+  return w.Flush()
+}
+
+func orNone(value string) string{
+  if len(value) == 0 {
+    return "<none>"
+  }
+  return value
+}
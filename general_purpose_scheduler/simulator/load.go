@@ -0,0 +1,128 @@
+/*
+Copyright (C) 2020 Alex Neo
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package simulator
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// Extensions of the manifest files read from a directory, the other files of a cluster dump
+// (e.g. the container logs) are skipped.
+var manifestExtensions = map[string]bool{".json": true, ".yaml": true, ".yml": true}
+
+// LoadFiles reads the objects of the manifests at the given paths. A directory, such as the
+// output directory of "kubectl cluster-info dump", is walked for JSON and YAML files.
+func LoadFiles(paths ...string) ([]runtime.Object, error) {
+	var objects []runtime.Object
+	for _, path := range paths {
+		err := filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || (file != path && !manifestExtensions[strings.ToLower(filepath.Ext(file))]) {
+				return nil
+			}
+
+			f, err := os.Open(file)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			loaded, err := Load(f)
+			if err != nil {
+				return fmt.Errorf("%s: %v", file, err)
+			}
+			objects = append(objects, loaded...)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return objects, nil
+}
+
+// Load decodes a stream of YAML documents or JSON objects. The items of lists are returned
+// instead of the lists and the kinds unknown to client-go (e.g. custom resources) are skipped.
+func Load(r io.Reader) ([]runtime.Object, error) {
+	var objects []runtime.Object
+	decoder := yaml.NewYAMLOrJSONDecoder(r, 4096)
+	for {
+		var raw runtime.RawExtension
+		if err := decoder.Decode(&raw); err == io.EOF {
+			return objects, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		decoded, err := decode(raw.Raw)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, decoded...)
+	}
+}
+
+func decode(raw []byte) ([]runtime.Object, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil, nil
+	}
+
+	obj, _, err := scheme.Codecs.UniversalDeserializer().Decode(raw, nil, nil)
+	if runtime.IsNotRegisteredError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !meta.IsListType(obj) {
+		return []runtime.Object{obj}, nil
+	}
+
+	items, err := meta.ExtractList(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []runtime.Object
+	for _, item := range items {
+		// The items of a v1.List are left encoded
+		if unknown, ok := item.(*runtime.Unknown); ok {
+			decoded, err := decode(unknown.Raw)
+			if err != nil {
+				return nil, err
+			}
+			objects = append(objects, decoded...)
+			continue
+		}
+		objects = append(objects, item)
+	}
+	return objects, nil
+}
@@ -0,0 +1,217 @@
+/*
+Copyright (C) 2020 Alex Neo
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package simulator runs the scheduling framework of the general purpose scheduler offline, on
+// the nodes and pods of manifests instead of a live cluster. The pods are placed in the cache of
+// the simulator only, nothing is bound.
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+
+	internalcache "github.com/alexnjh/epsilon/general_purpose_scheduler/internal/cache"
+	"github.com/alexnjh/epsilon/general_purpose_scheduler/k8s.io/kubernetes/pkg/controller/volume/scheduling"
+	sched "github.com/alexnjh/epsilon/general_purpose_scheduler/scheduler"
+	"github.com/alexnjh/epsilon/general_purpose_scheduler/scheduler/config"
+)
+
+// Placement is the result of scheduling a pod.
+type Placement struct {
+	// Namespace/name of the pod
+	Pod string `json:"pod"`
+	// Node selected for the pod, empty if the pod could not be scheduled
+	Node string `json:"node,omitempty"`
+	// Namespace/name of the pod preempted to make room for the pod
+	Victim string `json:"victim,omitempty"`
+	// Score given by every score plugin to the feasible nodes, by plugin then node
+	Scores map[string]map[string]int64 `json:"scores,omitempty"`
+	// Why the pod could not be scheduled
+	Reason string `json:"reason,omitempty"`
+}
+
+// Simulator schedules pods against a snapshot of a cluster.
+type Simulator struct {
+	scheduler *sched.Scheduler
+	cache     internalcache.Cache
+	stopCh    chan struct{}
+}
+
+// New creates a simulator of the cluster made of the given objects. The nodes and the pods bound
+// to them are added to the cache, the other objects (e.g. services, volumes and claims) are only
+// served to the plugins by a fake clientset. Every node is evaluated and the nodes with the same
+// score are told apart with the seed, so the same objects and seed give the same placements.
+func New(objects []runtime.Object, pluginConfig []config.PluginConfig, resourceScorers []string, seed int64) (*Simulator, error) {
+	var nodes []*v1.Node
+	var pods []*v1.Pod
+	objects = append([]runtime.Object(nil), objects...)
+	for i, obj := range objects {
+		switch o := obj.(type) {
+		case *v1.Node:
+			nodes = append(nodes, o)
+		case *v1.Pod:
+			pod := normalizePod(o.DeepCopy())
+			objects[i] = pod
+			if pod.Spec.NodeName != "" {
+				pods = append(pods, pod)
+			}
+		}
+	}
+
+	client := fake.NewSimpleClientset(objects...)
+	factory := informers.NewSharedInformerFactory(client, 0)
+
+	volumeBinder := scheduling.NewVolumeBinder(
+		client,
+		factory.Core().V1().Nodes(),
+		factory.Storage().V1().CSINodes(),
+		factory.Core().V1().PersistentVolumeClaims(),
+		factory.Core().V1().PersistentVolumes(),
+		factory.Storage().V1().StorageClasses(),
+		10*time.Second,
+	)
+
+	stopCh := make(chan struct{})
+	cache := internalcache.New(30*time.Second, stopCh)
+
+	// The plugins request their informers when created, so the factory is started afterwards
+	s, err := sched.New(volumeBinder, client, cache, factory, factory.Core().V1().Nodes().Lister(),
+		factory.Core().V1().Pods().Lister(), false, 100, pluginConfig, resourceScorers)
+	if err != nil {
+		close(stopCh)
+		return nil, err
+	}
+	s.SetSeed(seed)
+	factory.Start(stopCh)
+	factory.WaitForCacheSync(stopCh)
+
+	for _, node := range nodes {
+		if err := cache.AddNode(node); err != nil {
+			close(stopCh)
+			return nil, err
+		}
+	}
+	for _, pod := range pods {
+		if err := cache.AddPod(pod); err != nil {
+			close(stopCh)
+			return nil, err
+		}
+		s.ObservePod(pod)
+	}
+
+	return &Simulator{scheduler: s, cache: cache, stopCh: stopCh}, nil
+}
+
+// Close stops the informers and the cache of the simulator.
+func (s *Simulator) Close() {
+	close(s.stopCh)
+}
+
+// Schedule selects a node for the pod. The pod is placed on the node in the cache, after removing
+// the preempted pod if any, so that the next pods are scheduled around it.
+func (s *Simulator) Schedule(pod *v1.Pod) Placement {
+	pod = normalizePod(pod.DeepCopy())
+	placement := Placement{Pod: key(pod)}
+
+	result, err := s.scheduler.Schedule(context.Background(), pod)
+	if err != nil {
+		placement.Reason = err.Error()
+		return placement
+	}
+
+	if result.NorminatedPod != nil {
+		if err := s.cache.RemovePod(result.NorminatedPod); err != nil {
+			placement.Reason = fmt.Sprintf("Failed to preempt %s: %v", key(result.NorminatedPod), err)
+			return placement
+		}
+		placement.Victim = key(result.NorminatedPod)
+	}
+
+	pod.Spec.NodeName = result.SuggestedHost
+	if err := s.cache.AddPod(pod); err != nil {
+		placement.Reason = fmt.Sprintf("Failed to place the pod on %s: %v", result.SuggestedHost, err)
+		return placement
+	}
+	s.scheduler.ObservePod(pod)
+
+	placement.Node = result.SuggestedHost
+	if len(result.Scores) != 0 {
+		placement.Scores = make(map[string]map[string]int64, len(result.Scores))
+		for plugin, scores := range result.Scores {
+			placement.Scores[plugin] = make(map[string]int64, len(scores))
+			for _, score := range scores {
+				placement.Scores[plugin][score.Name] = score.Score
+			}
+		}
+	}
+	return placement
+}
+
+// Run schedules the pods one after the other, highest priority first like the scheduling queue.
+func (s *Simulator) Run(pods []*v1.Pod) []Placement {
+	pods = append([]*v1.Pod(nil), pods...)
+	for i := range pods {
+		pods[i] = normalizePod(pods[i].DeepCopy())
+	}
+	sort.SliceStable(pods, func(i, j int) bool {
+		return *pods[i].Spec.Priority > *pods[j].Spec.Priority
+	})
+
+	placements := make([]Placement, 0, len(pods))
+	for _, pod := range pods {
+		placements = append(placements, s.Schedule(pod))
+	}
+	return placements
+}
+
+// PendingPods returns the pods of the objects not bound to a node.
+func PendingPods(objects []runtime.Object) []*v1.Pod {
+	var pods []*v1.Pod
+	for _, obj := range objects {
+		if pod, ok := obj.(*v1.Pod); ok && pod.Spec.NodeName == "" &&
+			pod.Status.Phase != v1.PodSucceeded && pod.Status.Phase != v1.PodFailed {
+			pods = append(pods, pod)
+		}
+	}
+	return pods
+}
+
+// Fills the fields set by the API server that the scheduler relies on
+func normalizePod(pod *v1.Pod) *v1.Pod {
+	if pod.Namespace == "" {
+		pod.Namespace = "default"
+	}
+	if pod.UID == "" {
+		pod.UID = types.UID(key(pod))
+	}
+	if pod.Spec.Priority == nil {
+		var priority int32
+		pod.Spec.Priority = &priority
+	}
+	return pod
+}
+
+func key(pod *v1.Pod) string {
+	return pod.Namespace + "/" + pod.Name
+}
@@ -0,0 +1,184 @@
+/*
+Copyright (C) 2020 Alex Neo
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package simulator
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const cluster = `
+apiVersion: v1
+kind: Node
+metadata:
+  name: small
+status:
+  allocatable: {cpu: "1", memory: 1Gi, pods: "10"}
+  capacity: {cpu: "1", memory: 1Gi, pods: "10"}
+  conditions: [{type: Ready, status: "True"}]
+---
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: Node
+  metadata:
+    name: large
+  status:
+    allocatable: {cpu: "4", memory: 8Gi, pods: "10"}
+    capacity: {cpu: "4", memory: 8Gi, pods: "10"}
+    conditions: [{type: Ready, status: "True"}]
+- apiVersion: v1
+  kind: Pod
+  metadata:
+    name: running
+  spec:
+    nodeName: large
+    containers:
+    - name: app
+      image: app
+      resources:
+        requests: {cpu: "3", memory: 1Gi}
+---
+apiVersion: example.com/v1
+kind: Unknown
+metadata:
+  name: skipped
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: fits
+spec:
+  containers:
+  - name: app
+    image: app
+    resources:
+      requests: {cpu: 500m, memory: 512Mi}
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: too-large
+spec:
+  containers:
+  - name: app
+    image: app
+    resources:
+      requests: {cpu: "8", memory: 256Mi}
+`
+
+func TestLoad(t *testing.T) {
+	objects, err := Load(strings.NewReader(cluster))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 5 {
+		t.Fatalf("expected 5 objects, got %d", len(objects))
+	}
+	if pods := PendingPods(objects); len(pods) != 2 || pods[0].Name != "fits" || pods[1].Name != "too-large" {
+		t.Errorf("expected the pending pods fits and too-large, got %v", pods)
+	}
+}
+
+func TestRun(t *testing.T) {
+	objects, err := Load(strings.NewReader(cluster))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := New(objects, nil, []string{"NodeResourcesLeastAllocated"}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	placements := s.Run(PendingPods(objects))
+	if len(placements) != 2 {
+		t.Fatalf("expected 2 placements, got %d", len(placements))
+	}
+
+	// The large node has less free cpu left than the small one
+	fits := placements[0]
+	if fits.Pod != "default/fits" || fits.Node != "small" || fits.Reason != "" {
+		t.Errorf("expected default/fits on small, got %+v", fits)
+	}
+	if scores := fits.Scores["NodeResourcesLeastAllocated"]; len(scores) != 2 || scores["small"] <= scores["large"] {
+		t.Errorf("expected small to score higher than large, got %v", fits.Scores)
+	}
+
+	tooLarge := placements[1]
+	if tooLarge.Node != "" || !strings.Contains(tooLarge.Reason, "0/2 nodes are available: 2 Insufficient cpu.") {
+		t.Errorf("expected default/too-large to be unschedulable, got %+v", tooLarge)
+	}
+}
+
+func TestRunReproducible(t *testing.T) {
+	// More than 50 identical nodes, the nodes are all evaluated and tie on every score
+	resources := v1.ResourceList{
+		v1.ResourceCPU:    resource.MustParse("4"),
+		v1.ResourceMemory: resource.MustParse("8Gi"),
+		v1.ResourcePods:   resource.MustParse("110"),
+	}
+	var objects []runtime.Object
+	for i := 0; i < 60; i++ {
+		objects = append(objects, &v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("node-%02d", i)},
+			Status: v1.NodeStatus{
+				Capacity:    resources,
+				Allocatable: resources,
+				Conditions:  []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}},
+			},
+		})
+	}
+	var pods []*v1.Pod
+	for i := 0; i < 20; i++ {
+		pods = append(pods, &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("pod-%02d", i)},
+			Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "app", Image: "app"}}},
+		})
+	}
+
+	run := func(seed int64) []Placement {
+		s, err := New(objects, nil, []string{"NodeResourcesLeastAllocated"}, seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+		return s.Run(pods)
+	}
+
+	first, second := run(1), run(1)
+	for _, p := range first {
+		if p.Node == "" {
+			t.Fatalf("expected every pod to be placed, got %+v", p)
+		}
+		if len(p.Scores["NodeResourcesLeastAllocated"]) != 60 {
+			t.Fatalf("expected the 60 nodes to be scored, got %d", len(p.Scores["NodeResourcesLeastAllocated"]))
+		}
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("expected the same placements with the same seed, got\n%+v\n%+v", first, second)
+	}
+}
@@ -0,0 +1,76 @@
+/*
+
+Copyright (C) 2020 Alex Neo
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+// Package backoff sends the pods that failed scheduling back to their queue after a backoff period.
+// It is the process of the retry microservice, shared with the benchmark harness of the general
+// purpose scheduler.
+package backoff
+
+import (
+  "time"
+  "context"
+  "encoding/json"
+  "github.com/streadway/amqp"
+  log "github.com/sirupsen/logrus"
+  communication "github.com/alexnjh/epsilon/communication"
+)
+
+// Executes the retry process and should be executed in a goroutine. The pods wait
+// NextBackOffTime times backoffUnit, the retry microservice uses a backoff unit of one second.
+func Process(comm communication.Communication, msgs <-chan amqp.Delivery, closed chan<- bool, receiveQueue string, backoffUnit time.Duration){
+
+  // Loop through all the messages in the queue
+  for d := range msgs {
+    // Convert json message to schedule request object
+    var req communication.RetryRequest
+    if err := json.Unmarshal(d.Body, &req); err != nil {
+        panic(err)
+    }
+
+    // Keep the trace of the pod across the backoff
+    ctx := communication.ExtractContext(d, receiveQueue)
+
+    go WaitAndSend(ctx, comm, req,time.Duration(req.Req.NextBackOffTime)*backoffUnit)
+    d.Ack(true)
+  }
+
+  closed <- true
+
+}
+
+// WaitAndSend delays the rescheduling of a pod based of backoff period
+func WaitAndSend(ctx context.Context, comm communication.Communication, obj communication.RetryRequest, duration time.Duration){
+
+  _, span := communication.Tracer().Start(ctx, "Backoff")
+  time.Sleep(duration)
+  span.End()
+
+  obj.Req.NextBackOffTime = obj.Req.NextBackOffTime*obj.Req.NextBackOffTime
+
+  respBytes, err := json.Marshal(obj.Req)
+  if err != nil {
+    log.Errorf(err.Error())
+  }
+
+  err = comm.SendWithContext(ctx, respBytes,obj.Queue)
+
+  if err != nil{
+    log.Errorf(err.Error())
+  }
+
+}
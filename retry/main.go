@@ -22,12 +22,13 @@ import (
   "os"
   "fmt"
   "time"
+  "context"
   "math/rand"
-  "github.com/streadway/amqp"
   log "github.com/sirupsen/logrus"
-  jsoniter "github.com/json-iterator/go"
   configparser "github.com/bigkevmcd/go-configparser"
   communication "github.com/alexnjh/epsilon/communication"
+  tracing "github.com/alexnjh/epsilon/communication/tracing"
+  "github.com/alexnjh/epsilon/retry/backoff"
 )
 
 const (
@@ -35,10 +36,6 @@ const (
     DefaultConfigPath = "/go/src/app/config.cfg"
 )
 
-
-// Initialize json encoder
-var json = jsoniter.ConfigCompatibleWithStandardLibrary
-
 /*
 
 The main routing of the retry microservice.
@@ -111,6 +108,13 @@ func main() {
     }
   }
 
+  // Propagate the trace context of the pods and export the spans if a collector is configured
+  shutdown, err := tracing.Setup("retry")
+  if err != nil {
+    log.Fatalf(err.Error())
+  }
+  defer shutdown(context.Background())
+
   // Attempt to connect to the rabbitMQ server
   comm, err := communication.NewCommunicationClient(fmt.Sprintf("amqp://%s:%s@%s:%s/",mqUser, mqPass, mqHost, mqPort))
   if err != nil {
@@ -128,7 +132,7 @@ func main() {
   retryCh := make(chan bool)
   defer close(retryCh)
 
-  go RetryProcess(&comm, msgs, retryCh)
+  go backoff.Process(&comm, msgs, retryCh, receiveQueue, time.Second)
 
   log.Printf(" [*] Waiting for messages. To exit press CTRL+C")
 
@@ -151,7 +155,7 @@ func main() {
               log.Errorf(err.Error())
             }else{
               // Start go routine to start consuming messages
-              go RetryProcess(&comm, msgs, retryCh)
+              go backoff.Process(&comm, msgs, retryCh, receiveQueue, time.Second)
               log.Infof("Reconnected to message server")
               break
             }
@@ -165,42 +169,3 @@ func main() {
 
 
 }
-
-// Executes the retry process and should be executed in a goroutine
-func RetryProcess(comm communication.Communication, msgs <-chan amqp.Delivery, closed chan<- bool){
-
-  // Loop through all the messages in the queue
-  for d := range msgs {
-    // Convert json message to schedule request object
-    var req communication.RetryRequest
-    if err := json.Unmarshal(d.Body, &req); err != nil {
-        panic(err)
-    }
-
-    go WaitAndSend(comm, req,time.Duration(req.Req.NextBackOffTime)*time.Second)
-    d.Ack(true)
-  }
-
-  closed <- true
-
-}
-
-// WaitAndSend delays the rescheduling of a pod based of backoff period
-func WaitAndSend(comm communication.Communication, obj communication.RetryRequest, duration time.Duration){
-
-  time.Sleep(duration)
-
-  obj.Req.NextBackOffTime = obj.Req.NextBackOffTime*obj.Req.NextBackOffTime
-
-  respBytes, err := json.Marshal(obj.Req)
-  if err != nil {
-    log.Errorf(err.Error())
-  }
-
-  err = comm.Send(respBytes,obj.Queue)
-
-  if err != nil{
-    log.Errorf(err.Error())
-  }
-
-}
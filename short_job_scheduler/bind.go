@@ -0,0 +1,88 @@
+/*
+
+Copyright (C) 2020 Alex Neo
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package main
+
+import(
+  "context"
+  "k8s.io/client-go/kubernetes"
+  corev1 "k8s.io/api/core/v1"
+  apierrors "k8s.io/apimachinery/pkg/api/errors"
+  metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// BindFailure is the reason binding a pod to a node failed
+type BindFailure int
+
+const (
+  // The pod was deleted or replaced by a pod with the same name
+  BindFailedPodGone BindFailure = iota
+  // The pod is already assigned to a node
+  BindFailedAlreadyBound
+  // The selected node was removed from the cluster
+  BindFailedNodeGone
+  // Any other error returned by the API server
+  BindFailedAPIError
+)
+
+func (f BindFailure) String() string{
+  switch f {
+  case BindFailedPodGone:
+    return "pod gone"
+  case BindFailedAlreadyBound:
+    return "already bound"
+  case BindFailedNodeGone:
+    return "node gone"
+  default:
+    return "API error"
+  }
+}
+
+// Check if the pod should be scheduled again. Pods that are gone or already bound are dropped.
+func (f BindFailure) Retry() bool{
+  return f == BindFailedNodeGone || f == BindFailedAPIError
+}
+
+// Find out why binding the pod to the node failed by looking at the current state of the pod and
+// the node in the API server
+func classifyBindError(client kubernetes.Interface, pod *corev1.Pod, nodeName string, err error) BindFailure{
+
+  // The binding returns not found or conflict for several reasons, so the pod is fetched again
+  current, getErr := client.CoreV1().Pods(pod.Namespace).Get(context.TODO(), pod.Name, metav1.GetOptions{})
+
+  switch {
+  case apierrors.IsNotFound(getErr):
+    return BindFailedPodGone
+  case getErr != nil:
+    // The binding is rejected with a conflict when the pod is already assigned to a node
+    if apierrors.IsConflict(err) {
+      return BindFailedAlreadyBound
+    }
+    return BindFailedAPIError
+  case current.UID != pod.UID:
+    return BindFailedPodGone
+  case len(current.Spec.NodeName) != 0:
+    return BindFailedAlreadyBound
+  }
+
+  if _, getErr = client.CoreV1().Nodes().Get(context.TODO(), nodeName, metav1.GetOptions{}); apierrors.IsNotFound(getErr) {
+    return BindFailedNodeGone
+  }
+
+  return BindFailedAPIError
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+  "time"
+  "context"
+  "errors"
+  "testing"
+  "github.com/streadway/amqp"
+  "k8s.io/client-go/kubernetes/fake"
+  corev1 "k8s.io/api/core/v1"
+  metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+  apierrors "k8s.io/apimachinery/pkg/api/errors"
+  "k8s.io/apimachinery/pkg/runtime/schema"
+  communication "github.com/alexnjh/epsilon/communication"
+)
+
+type fakeComm struct{
+  sent chan []byte
+}
+
+func (c *fakeComm) Send(message []byte, queue string) error {
+  c.sent <- message
+  return nil
+}
+
+func (c *fakeComm) SendWithContext(ctx context.Context, message []byte, queue string) error {
+  return c.Send(message, queue)
+}
+
+func (c *fakeComm) Receive(queue string) (<-chan amqp.Delivery, error) {
+  return nil, nil
+}
+
+func (c *fakeComm) Connect() error {
+  return nil
+}
+
+func TestClassifyBindError(t *testing.T) {
+
+  pod := testPod("p", "")
+  pod.UID = "uid-1"
+
+  recreated := testPod("p", "")
+  recreated.UID = "uid-2"
+
+  bound := testPod("p", "node-1")
+  bound.UID = "uid-1"
+
+  node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}
+
+  notFound := apierrors.NewNotFound(schema.GroupResource{Resource: "pods"}, "p")
+  conflict := apierrors.NewConflict(schema.GroupResource{Resource: "pods/binding"}, "p", errors.New("already assigned"))
+
+  tests := []struct{
+    name string
+    client *fake.Clientset
+    err error
+    want BindFailure
+  }{
+    {"pod deleted", fake.NewSimpleClientset(node), notFound, BindFailedPodGone},
+    {"pod recreated", fake.NewSimpleClientset(recreated, node), conflict, BindFailedPodGone},
+    {"already bound", fake.NewSimpleClientset(bound, node), conflict, BindFailedAlreadyBound},
+    {"node deleted", fake.NewSimpleClientset(pod), notFound, BindFailedNodeGone},
+    {"api error", fake.NewSimpleClientset(pod, node), apierrors.NewTimeoutError("timeout", 1), BindFailedAPIError},
+  }
+
+  for _, tt := range tests {
+    if got := classifyBindError(tt.client, pod, "node-1", tt.err); got != tt.want {
+      t.Errorf("%s: classifyBindError() = %s, want %s", tt.name, got, tt.want)
+    }
+  }
+
+  if BindFailedPodGone.Retry() || BindFailedAlreadyBound.Retry() || !BindFailedNodeGone.Retry() || !BindFailedAPIError.Retry() {
+    t.Errorf("only node gone and API errors should be retried")
+  }
+}
+
+func TestRequeue(t *testing.T) {
+
+  pod := testPod("p", "")
+  client := fake.NewSimpleClientset(pod)
+  comm := &fakeComm{sent: make(chan []byte, 1)}
+  m := NewSchedulerMetrics("epsilon.shortjob", "sjsched-0")
+
+  // Requests without a backoff time start at 2 seconds
+  requeue(context.Background(), comm, m, client, pod, communication.ScheduleRequest{Key: "default/p"}, "node gone", "epsilon.shortjob", "epsilon.backoff", 8)
+
+  select {
+  case msg := <-comm.sent:
+    var retry communication.RetryRequest
+    if err := json.Unmarshal(msg, &retry); err != nil {
+      t.Fatal(err)
+    }
+    if retry.Queue != "epsilon.shortjob" || retry.Req.NextBackOffTime != 2 || retry.Req.Message != "node gone" {
+      t.Errorf("retry request = %+v", retry)
+    }
+  case <-time.After(time.Second):
+    t.Fatalf("request was not sent to the retry queue")
+  }
+
+  // The scheduler gives up once the maximum backoff time is reached
+  requeue(context.Background(), comm, m, client, pod, communication.ScheduleRequest{Key: "default/p", NextBackOffTime: 8}, "node gone", "epsilon.shortjob", "epsilon.backoff", 8)
+
+  select {
+  case <-comm.sent:
+    t.Errorf("request exceeding the maximum backoff time was sent to the retry queue")
+  case <-time.After(100*time.Millisecond):
+  }
+}
@@ -0,0 +1,137 @@
+/*
+
+Copyright (C) 2020 Alex Neo
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package main
+
+import(
+  "fmt"
+  "errors"
+  "k8s.io/apimachinery/pkg/labels"
+  corev1 "k8s.io/api/core/v1"
+  corev1helpers "k8s.io/component-helpers/scheduling/corev1"
+)
+
+// The filters below are lightweight versions of the nodestatus, nodeunschedulable, tainttoleration
+// and nodeaffinity filter plugins of the general purpose scheduler and return the same reasons.
+const (
+  ErrReasonNotReady = "node(s) not ready to accept pods"
+  ErrReasonUnschedulable = "node(s) were unschedulable"
+  ErrReasonNodeSelector = "node(s) didn't match node selector"
+)
+
+// FilterFunc checks if a pod can be deployed on a node, returns the reason if it cannot
+type FilterFunc func(pod *corev1.Pod, node *corev1.Node) error
+
+// Filters run in order on every node before checking the resources of the node
+var DefaultFilters = []FilterFunc{
+  nodeStatus,
+  nodeUnschedulable,
+  taintToleration,
+  nodeAffinity,
+}
+
+// Run the filters in order and return the reason of the first filter that fails
+func runFilters(filters []FilterFunc, pod *corev1.Pod, node *corev1.Node) error{
+  for _, filter := range(filters){
+    if err := filter(pod, node); err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+// Check if the ready condition of the node is true
+func nodeStatus(pod *corev1.Pod, node *corev1.Node) error{
+  for _, cond := range(node.Status.Conditions){
+    if cond.Type == corev1.NodeReady {
+      if cond.Status == corev1.ConditionTrue {
+        return nil
+      }
+      break
+    }
+  }
+  return errors.New(ErrReasonNotReady)
+}
+
+// Check if the node is cordoned. Pods tolerating the unschedulable taint also tolerate cordoned nodes.
+func nodeUnschedulable(pod *corev1.Pod, node *corev1.Node) error{
+
+  if !node.Spec.Unschedulable {
+    return nil
+  }
+
+  if tolerationsTolerateTaint(pod.Spec.Tolerations, &corev1.Taint{
+    Key: corev1.TaintNodeUnschedulable,
+    Effect: corev1.TaintEffectNoSchedule,
+  }) {
+    return nil
+  }
+
+  return errors.New(ErrReasonUnschedulable)
+}
+
+// Check if the pod tolerates every NoSchedule and NoExecute taint of the node.
+// PreferNoSchedule taints do not prevent a pod from being deployed.
+func taintToleration(pod *corev1.Pod, node *corev1.Node) error{
+
+  for i := range(node.Spec.Taints){
+    taint := &node.Spec.Taints[i]
+    if taint.Effect != corev1.TaintEffectNoSchedule && taint.Effect != corev1.TaintEffectNoExecute {
+      continue
+    }
+    if !tolerationsTolerateTaint(pod.Spec.Tolerations, taint) {
+      return fmt.Errorf("node(s) had taint {%s: %s}, that the pod didn't tolerate", taint.Key, taint.Value)
+    }
+  }
+
+  return nil
+}
+
+// Check if the taint is tolerated by any of the tolerations, the same as v1helper.TolerationsTolerateTaint
+// of k8s.io/kubernetes which is not meant to be imported
+func tolerationsTolerateTaint(tolerations []corev1.Toleration, taint *corev1.Taint) bool{
+  for i := range(tolerations){
+    if tolerations[i].ToleratesTaint(taint) {
+      return true
+    }
+  }
+  return false
+}
+
+// Check if the node labels match the node selector and the required node affinity of the pod
+func nodeAffinity(pod *corev1.Pod, node *corev1.Node) error{
+
+  if len(pod.Spec.NodeSelector) > 0 {
+    if !labels.SelectorFromSet(pod.Spec.NodeSelector).Matches(labels.Set(node.Labels)) {
+      return errors.New(ErrReasonNodeSelector)
+    }
+  }
+
+  affinity := pod.Spec.Affinity
+  if affinity == nil || affinity.NodeAffinity == nil || affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+    return nil
+  }
+
+  // Terms that cannot be parsed match no nodes
+  matches, _ := corev1helpers.MatchNodeSelectorTerms(node, affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution)
+  if !matches {
+    return errors.New(ErrReasonNodeSelector)
+  }
+
+  return nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+  "strings"
+  "testing"
+  "k8s.io/client-go/tools/cache"
+  corev1 "k8s.io/api/core/v1"
+  corelisters "k8s.io/client-go/listers/core/v1"
+)
+
+func TestNodeStatus(t *testing.T) {
+
+  node := testNode("node-1", nil)
+  if err := nodeStatus(testPod("p", ""), node); err != nil {
+    t.Errorf("ready node: nodeStatus() = %v", err)
+  }
+
+  node.Status.Conditions[0].Status = corev1.ConditionUnknown
+  if err := nodeStatus(testPod("p", ""), node); err == nil || err.Error() != ErrReasonNotReady {
+    t.Errorf("unknown node: nodeStatus() = %v", err)
+  }
+
+  node.Status.Conditions = nil
+  if err := nodeStatus(testPod("p", ""), node); err == nil {
+    t.Errorf("node without conditions: nodeStatus() = nil")
+  }
+}
+
+func TestNodeUnschedulable(t *testing.T) {
+
+  node := testNode("node-1", nil)
+  node.Spec.Unschedulable = true
+
+  if err := nodeUnschedulable(testPod("p", ""), node); err == nil || err.Error() != ErrReasonUnschedulable {
+    t.Errorf("cordoned node: nodeUnschedulable() = %v", err)
+  }
+
+  pod := testPod("p", "")
+  pod.Spec.Tolerations = []corev1.Toleration{{Key: corev1.TaintNodeUnschedulable, Operator: corev1.TolerationOpExists}}
+  if err := nodeUnschedulable(pod, node); err != nil {
+    t.Errorf("tolerating pod: nodeUnschedulable() = %v", err)
+  }
+}
+
+func TestTaintToleration(t *testing.T) {
+
+  taints := []corev1.Taint{
+    {Key: "dedicated", Value: "batch", Effect: corev1.TaintEffectNoSchedule},
+    {Key: "maintenance", Effect: corev1.TaintEffectNoExecute},
+    {Key: "spot", Value: "true", Effect: corev1.TaintEffectPreferNoSchedule},
+  }
+
+  tests := []struct{
+    name string
+    tolerations []corev1.Toleration
+    want string
+  }{
+    {"no tolerations", nil, "node(s) had taint {dedicated: batch}, that the pod didn't tolerate"},
+    {"missing NoExecute", []corev1.Toleration{
+      {Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "batch"},
+    }, "node(s) had taint {maintenance: }, that the pod didn't tolerate"},
+    {"wrong value", []corev1.Toleration{
+      {Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "web"},
+      {Key: "maintenance", Operator: corev1.TolerationOpExists},
+    }, "node(s) had taint {dedicated: batch}, that the pod didn't tolerate"},
+    {"exists and equal", []corev1.Toleration{
+      {Key: "dedicated", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+      {Key: "maintenance", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoExecute},
+    }, ""},
+    {"tolerate everything", []corev1.Toleration{{Operator: corev1.TolerationOpExists}}, ""},
+  }
+
+  node := testNode("node-1", nil)
+  node.Spec.Taints = taints
+
+  for _, tt := range tests {
+    pod := testPod("p", "")
+    pod.Spec.Tolerations = tt.tolerations
+    err := taintToleration(pod, node)
+    if len(tt.want) == 0 && err != nil || len(tt.want) != 0 && (err == nil || err.Error() != tt.want) {
+      t.Errorf("%s: taintToleration() = %v, want %q", tt.name, err, tt.want)
+    }
+  }
+}
+
+func TestNodeAffinity(t *testing.T) {
+
+  node := testNode("node-1", nil)
+  node.Labels = map[string]string{"zone": "a", "disk": "ssd", "cores": "8"}
+
+  required := func(terms ...corev1.NodeSelectorTerm) *corev1.Affinity {
+    return &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{
+      RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{NodeSelectorTerms: terms},
+    }}
+  }
+
+  tests := []struct{
+    name string
+    nodeSelector map[string]string
+    affinity *corev1.Affinity
+    fits bool
+  }{
+    {"no constraints", nil, nil, true},
+    {"node selector matches", map[string]string{"zone": "a"}, nil, true},
+    {"node selector does not match", map[string]string{"zone": "b"}, nil, false},
+    {"affinity matches", nil, required(corev1.NodeSelectorTerm{MatchExpressions: []corev1.NodeSelectorRequirement{
+      {Key: "disk", Operator: corev1.NodeSelectorOpIn, Values: []string{"ssd", "nvme"}},
+      {Key: "cores", Operator: corev1.NodeSelectorOpGt, Values: []string{"4"}},
+    }}), true},
+    {"affinity terms are ORed", nil, required(
+      corev1.NodeSelectorTerm{MatchExpressions: []corev1.NodeSelectorRequirement{{Key: "gpu", Operator: corev1.NodeSelectorOpExists}}},
+      corev1.NodeSelectorTerm{MatchFields: []corev1.NodeSelectorRequirement{{Key: "metadata.name", Operator: corev1.NodeSelectorOpIn, Values: []string{"node-1"}}}},
+    ), true},
+    {"affinity does not match", nil, required(corev1.NodeSelectorTerm{MatchExpressions: []corev1.NodeSelectorRequirement{
+      {Key: "disk", Operator: corev1.NodeSelectorOpNotIn, Values: []string{"ssd"}},
+    }}), false},
+    {"empty term matches nothing", nil, required(corev1.NodeSelectorTerm{}), false},
+  }
+
+  for _, tt := range tests {
+    pod := testPod("p", "")
+    pod.Spec.NodeSelector = tt.nodeSelector
+    pod.Spec.Affinity = tt.affinity
+    err := nodeAffinity(pod, node)
+    if tt.fits != (err == nil) {
+      t.Errorf("%s: nodeAffinity() = %v, want fits %v", tt.name, err, tt.fits)
+    }
+  }
+}
+
+func TestScheduleFilters(t *testing.T) {
+
+  nodeIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+  podIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{NodeNameIndex: nodeNameIndexFunc})
+
+  allocatable := resourceList("2", "4Gi", "", map[corev1.ResourceName]string{corev1.ResourcePods: "110"})
+
+  cordoned := testNode("node-1", allocatable)
+  cordoned.Spec.Unschedulable = true
+  notReady := testNode("node-2", allocatable)
+  notReady.Status.Conditions[0].Status = corev1.ConditionFalse
+  tainted := testNode("node-3", allocatable)
+  tainted.Spec.Taints = []corev1.Taint{{Key: "dedicated", Value: "gpu", Effect: corev1.TaintEffectNoSchedule}}
+  ready := testNode("node-4", allocatable)
+
+  for _, node := range []*corev1.Node{cordoned, notReady, tainted, ready} {
+    nodeIndexer.Add(node)
+  }
+
+  s := NewShortJobScheduler(nil, nil, podIndexer, corelisters.NewNodeLister(nodeIndexer), NewRoundRobin(), nil)
+
+  for i := 0; i < 4; i++ {
+    name, err := s.Schedule(testPod("p", ""))
+    if err != nil || name != "node-4" {
+      t.Fatalf("Schedule() = %q, %v, want node-4", name, err)
+    }
+  }
+
+  pod := testPod("p", "")
+  pod.Spec.NodeSelector = map[string]string{"zone": "a"}
+
+  _, err := s.Schedule(pod)
+  want := "0/4 nodes are available: 1 node(s) didn't match node selector, 1 node(s) had taint {dedicated: gpu}, that the pod didn't tolerate, 1 node(s) not ready to accept pods, 1 node(s) were unschedulable."
+  if err == nil || !strings.Contains(err.Error(), want) {
+    t.Errorf("Schedule() error = %v", err)
+  }
+}
@@ -0,0 +1,213 @@
+/*
+
+Copyright (C) 2020 Alex Neo
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package main
+
+import(
+  "fmt"
+  "strings"
+  "k8s.io/client-go/tools/cache"
+  corev1 "k8s.io/api/core/v1"
+)
+
+const (
+  // Name of the pod informer index containing the pods assigned to each node
+  NodeNameIndex = "spec.nodeName"
+)
+
+// Resource is the amount of compute resources requested by pods or allocatable on a node
+type Resource struct{
+  MilliCPU int64
+  Memory int64
+  EphemeralStorage int64
+  // Number of pods
+  Pods int64
+  // Extended resources, hugepages and other resources
+  ScalarResources map[corev1.ResourceName]int64
+}
+
+// Add a resource list to the resource
+func (r *Resource) Add(rl corev1.ResourceList){
+  for name, quantity := range(rl){
+    switch name {
+    case corev1.ResourceCPU:
+      r.MilliCPU += quantity.MilliValue()
+    case corev1.ResourceMemory:
+      r.Memory += quantity.Value()
+    case corev1.ResourceEphemeralStorage:
+      r.EphemeralStorage += quantity.Value()
+    case corev1.ResourcePods:
+      r.Pods += quantity.Value()
+    default:
+      if r.ScalarResources == nil {
+        r.ScalarResources = make(map[corev1.ResourceName]int64)
+      }
+      r.ScalarResources[name] += quantity.Value()
+    }
+  }
+}
+
+// Add the amount of another resource to the resource
+func (r *Resource) AddResource(other *Resource){
+  r.MilliCPU += other.MilliCPU
+  r.Memory += other.Memory
+  r.EphemeralStorage += other.EphemeralStorage
+  r.Pods += other.Pods
+  for name, v := range(other.ScalarResources){
+    if r.ScalarResources == nil {
+      r.ScalarResources = make(map[corev1.ResourceName]int64)
+    }
+    r.ScalarResources[name] += v
+  }
+}
+
+// Set each resource to the larger of the resource and the resource list
+func (r *Resource) SetMax(rl corev1.ResourceList){
+  other := &Resource{}
+  other.Add(rl)
+
+  if other.MilliCPU > r.MilliCPU {
+    r.MilliCPU = other.MilliCPU
+  }
+  if other.Memory > r.Memory {
+    r.Memory = other.Memory
+  }
+  if other.EphemeralStorage > r.EphemeralStorage {
+    r.EphemeralStorage = other.EphemeralStorage
+  }
+  for name, v := range(other.ScalarResources){
+    if r.ScalarResources == nil {
+      r.ScalarResources = make(map[corev1.ResourceName]int64)
+    }
+    if v > r.ScalarResources[name] {
+      r.ScalarResources[name] = v
+    }
+  }
+}
+
+// Compute the resources requested by a pod. Init containers run one after another before the
+// containers so the pod requests the larger of the sum of its containers and each init container.
+// The pod overhead is added on top.
+func computePodResourceRequest(pod *corev1.Pod) *Resource{
+
+  result := &Resource{}
+
+  for _, container := range(pod.Spec.Containers){
+    result.Add(container.Resources.Requests)
+  }
+
+  for _, container := range(pod.Spec.InitContainers){
+    result.SetMax(container.Resources.Requests)
+  }
+
+  if pod.Spec.Overhead != nil {
+    result.Add(pod.Spec.Overhead)
+  }
+
+  return result
+}
+
+// Compute the resources requested by the pods assigned to a node, pods that completed are ignored
+func computeNodeRequested(pods []*corev1.Pod) *Resource{
+
+  result := &Resource{}
+
+  for _, pod := range(pods){
+    if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+      continue
+    }
+    result.AddResource(computePodResourceRequest(pod))
+    result.Pods++
+  }
+
+  return result
+}
+
+// FitError contains the reasons a pod does not fit on a node
+type FitError struct{
+  Reasons []string
+}
+
+func (e *FitError) Error() string{
+  return strings.Join(e.Reasons, ", ")
+}
+
+// Check if a pod fits on a node given the resources requested by the pods already on the node.
+// Returns an error naming every insufficient resource.
+func fitsResources(pod *corev1.Pod, node *corev1.Node, requested *Resource) error{
+
+  allocatable := &Resource{}
+  allocatable.Add(node.Status.Allocatable)
+
+  request := computePodResourceRequest(pod)
+
+  var insufficient []string
+
+  if requested.Pods+1 > allocatable.Pods {
+    insufficient = append(insufficient, "Too many pods")
+  }
+
+  if request.MilliCPU > 0 && request.MilliCPU > allocatable.MilliCPU-requested.MilliCPU {
+    insufficient = append(insufficient, "Insufficient cpu")
+  }
+  if request.Memory > 0 && request.Memory > allocatable.Memory-requested.Memory {
+    insufficient = append(insufficient, "Insufficient memory")
+  }
+  if request.EphemeralStorage > 0 && request.EphemeralStorage > allocatable.EphemeralStorage-requested.EphemeralStorage {
+    insufficient = append(insufficient, "Insufficient ephemeral-storage")
+  }
+
+  for name, v := range(request.ScalarResources){
+    if v > 0 && v > allocatable.ScalarResources[name]-requested.ScalarResources[name] {
+      insufficient = append(insufficient, fmt.Sprintf("Insufficient %s", name))
+    }
+  }
+
+  if len(insufficient) != 0 {
+    return &FitError{Reasons: insufficient}
+  }
+
+  return nil
+}
+
+// Index pods by the node they are assigned to
+func nodeNameIndexFunc(obj interface{}) ([]string, error){
+  pod, ok := obj.(*corev1.Pod)
+  if !ok || len(pod.Spec.NodeName) == 0 {
+    return []string{}, nil
+  }
+  return []string{pod.Spec.NodeName}, nil
+}
+
+// Get the pods assigned to a node from the pod informer index
+func podsOnNode(indexer cache.Indexer, nodeName string) ([]*corev1.Pod, error){
+
+  objs, err := indexer.ByIndex(NodeNameIndex, nodeName)
+  if err != nil {
+    return nil, err
+  }
+
+  pods := make([]*corev1.Pod, 0, len(objs))
+  for _, obj := range(objs){
+    if pod, ok := obj.(*corev1.Pod); ok {
+      pods = append(pods, pod)
+    }
+  }
+
+  return pods, nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+  "strings"
+  "testing"
+  "k8s.io/client-go/tools/cache"
+  corev1 "k8s.io/api/core/v1"
+  "k8s.io/apimachinery/pkg/api/resource"
+  metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+  corelisters "k8s.io/client-go/listers/core/v1"
+)
+
+func resourceList(cpu, memory, storage string, extra map[corev1.ResourceName]string) corev1.ResourceList {
+  rl := corev1.ResourceList{}
+  if len(cpu) != 0 {
+    rl[corev1.ResourceCPU] = resource.MustParse(cpu)
+  }
+  if len(memory) != 0 {
+    rl[corev1.ResourceMemory] = resource.MustParse(memory)
+  }
+  if len(storage) != 0 {
+    rl[corev1.ResourceEphemeralStorage] = resource.MustParse(storage)
+  }
+  for name, v := range extra {
+    rl[name] = resource.MustParse(v)
+  }
+  return rl
+}
+
+func testPod(name, nodeName string, requests ...corev1.ResourceList) *corev1.Pod {
+  pod := &corev1.Pod{
+    ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+    Spec: corev1.PodSpec{NodeName: nodeName},
+  }
+  for _, r := range requests {
+    pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Resources: corev1.ResourceRequirements{Requests: r}})
+  }
+  return pod
+}
+
+func testNode(name string, allocatable corev1.ResourceList) *corev1.Node {
+  return &corev1.Node{
+    ObjectMeta: metav1.ObjectMeta{Name: name},
+    Status: corev1.NodeStatus{
+      Allocatable: allocatable,
+      Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+    },
+  }
+}
+
+func TestComputePodResourceRequest(t *testing.T) {
+
+  pod := testPod("p", "", resourceList("500m", "1Gi", "", nil), resourceList("250m", "1Gi", "1Gi", nil))
+  pod.Spec.InitContainers = []corev1.Container{
+    {Resources: corev1.ResourceRequirements{Requests: resourceList("2", "512Mi", "", nil)}},
+  }
+  pod.Spec.Overhead = resourceList("100m", "", "", nil)
+
+  r := computePodResourceRequest(pod)
+
+  if r.MilliCPU != 2100 || r.Memory != 2*1024*1024*1024 || r.EphemeralStorage != 1024*1024*1024 {
+    t.Errorf("computePodResourceRequest() = %+v", r)
+  }
+}
+
+func TestFitsResources(t *testing.T) {
+
+  gpu := corev1.ResourceName("nvidia.com/gpu")
+  node := testNode("node-1", resourceList("4", "8Gi", "10Gi", map[corev1.ResourceName]string{corev1.ResourcePods: "3", gpu: "1"}))
+
+  running := testPod("running", "node-1", resourceList("3", "4Gi", "", nil))
+  completed := testPod("completed", "node-1", resourceList("4", "8Gi", "", nil))
+  completed.Status.Phase = corev1.PodSucceeded
+
+  requested := computeNodeRequested([]*corev1.Pod{running, completed})
+
+  tests := []struct{
+    name string
+    pod *corev1.Pod
+    requested *Resource
+    want []string
+  }{
+    {"fits", testPod("p", "", resourceList("1", "4Gi", "1Gi", map[corev1.ResourceName]string{gpu: "1"})), requested, nil},
+    {"no requests", testPod("p", ""), requested, nil},
+    {"insufficient cpu", testPod("p", "", resourceList("1500m", "", "", nil)), requested, []string{"Insufficient cpu"}},
+    {"insufficient memory and storage", testPod("p", "", resourceList("", "5Gi", "11Gi", nil)), requested, []string{"Insufficient memory", "Insufficient ephemeral-storage"}},
+    {"insufficient extended resource", testPod("p", "", resourceList("", "", "", map[corev1.ResourceName]string{gpu: "2"})), requested, []string{"Insufficient nvidia.com/gpu"}},
+    {"too many pods", testPod("p", ""), &Resource{Pods: 3}, []string{"Too many pods"}},
+  }
+
+  for _, tt := range tests {
+    err := fitsResources(tt.pod, node, tt.requested)
+    if tt.want == nil {
+      if err != nil {
+        t.Errorf("%s: fitsResources() = %v, want nil", tt.name, err)
+      }
+      continue
+    }
+    fitErr, ok := err.(*FitError)
+    if !ok || strings.Join(fitErr.Reasons, ",") != strings.Join(tt.want, ",") {
+      t.Errorf("%s: fitsResources() = %v, want %v", tt.name, err, tt.want)
+    }
+  }
+}
+
+func TestScheduleSkipsFullNodes(t *testing.T) {
+
+  nodeIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+  podIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{NodeNameIndex: nodeNameIndexFunc})
+
+  allocatable := resourceList("2", "4Gi", "", map[corev1.ResourceName]string{corev1.ResourcePods: "110"})
+  for _, name := range []string{"node-1", "node-2", "node-3"} {
+    nodeIndexer.Add(testNode(name, allocatable))
+  }
+
+  podIndexer.Add(testPod("a", "node-1", resourceList("2", "", "", nil)))
+  podIndexer.Add(testPod("b", "node-3", resourceList("", "4Gi", "", nil)))
+
+  s := NewShortJobScheduler(nil, nil, podIndexer, corelisters.NewNodeLister(nodeIndexer), NewRoundRobin(), nil)
+
+  pod := testPod("p", "", resourceList("1", "1Gi", "", nil))
+
+  for i := 0; i < 3; i++ {
+    name, err := s.Schedule(pod)
+    if err != nil || name != "node-2" {
+      t.Fatalf("Schedule() = %q, %v, want node-2", name, err)
+    }
+  }
+
+  _, err := s.Schedule(testPod("p", "", resourceList("3", "", "", nil)))
+  if err == nil || !strings.Contains(err.Error(), "0/3 nodes are available: 3 Insufficient cpu") {
+    t.Errorf("Schedule() error = %v", err)
+  }
+}
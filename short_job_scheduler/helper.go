@@ -17,6 +17,9 @@ import (
   metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
   configparser "github.com/bigkevmcd/go-configparser"
   communication "github.com/alexnjh/epsilon/communication"
+  "go.opentelemetry.io/otel/attribute"
+  "go.opentelemetry.io/otel/codes"
+  "go.opentelemetry.io/otel/trace"
 )
 
 // Get microservice config file
@@ -61,9 +64,9 @@ func getKubernetesClient() (kubernetes.Interface){
 }
 
 // Send a message to a queue
-func SendToQueue(comm communication.Communication, message []byte, queue string){
+func SendToQueue(ctx context.Context, comm communication.Communication, message []byte, queue string){
   for {
-    if err := comm.Send(message,queue); err != nil {
+    if err := comm.SendWithContext(ctx,message,queue); err != nil {
 
       for{
         err = comm.Connect()
@@ -131,7 +134,10 @@ func AddPodStatus(
 }
 
 // Bind the pod to the node
-func bind(client kubernetes.Interface, p corev1.Pod, NodeName string, discoverTime time.Duration, schedTime time.Time) error{
+func bind(ctx context.Context, client kubernetes.Interface, p corev1.Pod, NodeName string, discoverTime time.Duration, schedTime time.Time) error{
+
+  ctx, span := communication.Tracer().Start(ctx, "Bind", trace.WithAttributes(attribute.String("node", NodeName)))
+  defer span.End()
 
   if p.Annotations != nil {
     p.Annotations["epsilon.discover.time"]=discoverTime.String()
@@ -154,8 +160,9 @@ func bind(client kubernetes.Interface, p corev1.Pod, NodeName string, discoverTi
 		Target:     corev1.ObjectReference{Kind: "Node", Name: NodeName},
 	}
 
-	err := client.CoreV1().Pods(binding.Namespace).Bind(context.TODO(), binding, metav1.CreateOptions{})
+	err := client.CoreV1().Pods(binding.Namespace).Bind(ctx, binding, metav1.CreateOptions{})
 	if err != nil {
+    span.SetStatus(codes.Error, err.Error())
     //key, _ := cache.MetaNamespaceKeyFunc(p)
 
 
@@ -24,6 +24,7 @@ import (
   "fmt"
   "os"
   "strconv"
+  "sync"
   "math/rand"
 
 	"github.com/streadway/amqp"
@@ -34,16 +35,23 @@ import (
   log "github.com/sirupsen/logrus"
   kubeinformers "k8s.io/client-go/informers"
   jsoniter "github.com/json-iterator/go"
+  "github.com/prometheus/client_golang/prometheus"
   corelisters "k8s.io/client-go/listers/core/v1"
   metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
   configparser "github.com/bigkevmcd/go-configparser"
   communication "github.com/alexnjh/epsilon/communication"
+  tracing "github.com/alexnjh/epsilon/communication/tracing"
+  "go.opentelemetry.io/otel/attribute"
+  "go.opentelemetry.io/otel/codes"
+  "go.opentelemetry.io/otel/trace"
 )
 
 const (
 
   // Maximum backoff time before the scheduler stop trying to schedule the pod
   MaxBackOffTime = 256
+  // Default number of messages scheduled concurrently
+  DefaultWorkers = 1
   PodBackoffExceeded corev1.PodPhase = "PodBackoffExceeded"
   DefaultConfigPath = "/go/src/app/config.cfg"
 
@@ -86,6 +94,9 @@ func main() {
 
   var mqHost, mqPort, mqUser, mqPass, receiveQueue, backoffQueue, hostname string
   var maxBackOff = MaxBackOffTime
+  var placementStrategy, queueStrategies string
+  var workers = DefaultWorkers
+  var metricsPort string
 
   if err != nil {
 
@@ -98,6 +109,18 @@ func main() {
     mqPass = os.Getenv("MQ_PASS")
     receiveQueue = os.Getenv("RECEIVE_QUEUE")
     backoffQueue = os.Getenv("RETRY_QUEUE")
+    placementStrategy = os.Getenv("PLACEMENT_STRATEGY")
+    queueStrategies = os.Getenv("PLACEMENT_STRATEGIES")
+    metricsPort = os.Getenv("METRICS_PORT")
+
+    if p := os.Getenv("WORKERS"); len(p) != 0 {
+      val, err := strconv.Atoi(p)
+      if err == nil {
+        workers = val
+      }else{
+        log.Errorf(err.Error())
+      }
+    }
 
     if len(mqHost) == 0 ||
     len(mqPort) == 0 ||
@@ -150,8 +173,59 @@ func main() {
         log.Errorf(err.Error())
       }
     }
+    // Get the placement strategies if exist
+    placementStrategy, _ = config.Get("DEFAULTS", "placement_strategy")
+    queueStrategies, _ = config.Get("DEFAULTS", "placement_strategies")
+    // Get the port of the metrics server if exist
+    metricsPort, _ = config.Get("DEFAULTS", "metrics_port")
+    // Get number of workers if exist
+    p, err = config.Get("DEFAULTS", "workers")
+    if err == nil {
+      val, err := strconv.Atoi(p)
+      if err == nil {
+        workers = val
+      }else{
+        log.Errorf(err.Error())
+      }
+    }
+  }
+
+  if workers < 1 {
+    workers = DefaultWorkers
+  }
+
+  if len(placementStrategy) == 0 {
+    placementStrategy = DefaultPlacementStrategy
+  }
+
+  strategy, err := NewPlacementStrategy(placementStrategy)
+  if err != nil {
+    log.Fatalf(err.Error())
   }
 
+  strategies, err := getQueueStrategies(queueStrategies)
+  if err != nil {
+    log.Fatalf(err.Error())
+  }
+
+  if len(metricsPort) == 0 {
+    metricsPort = DefaultMetricsPort
+  }
+
+  // Register the scheduler metrics labelled with the queue and the replica and serve them
+  schedMetrics := NewSchedulerMetrics(receiveQueue, hostname)
+  err = schedMetrics.Register(prometheus.DefaultRegisterer)
+  if err != nil {
+    log.Fatalf(err.Error())
+  }
+  go metricsServer(metricsPort)
+
+  // Propagate the trace context of the pods and export the spans if a collector is configured
+  shutdown, err := tracing.Setup("short-job-scheduler")
+  if err != nil {
+    log.Fatalf(err.Error())
+  }
+  defer shutdown(context.Background())
 
   // Get the Kubernetes client for communicating with API server
 	client := getKubernetesClient()
@@ -160,6 +234,13 @@ func main() {
   kubefactory := kubeinformers.NewSharedInformerFactory(client, time.Second*30)
   node_lister := kubefactory.Core().V1().Nodes().Lister()
   pod_lister := kubefactory.Core().V1().Pods().Lister()
+  pod_informer := kubefactory.Core().V1().Pods().Informer()
+
+  // Index the pods by node to compute the resources used on each node
+  err = pod_informer.AddIndexers(cache.Indexers{NodeNameIndex: nodeNameIndexFunc})
+  if err != nil {
+    log.Fatalf(err.Error())
+  }
 
 
   // Attempt to connect to the rabbitMQ server
@@ -173,6 +254,12 @@ func main() {
     log.Fatalf(err.Error())
   }
 
+  // Only deliver as many messages as there are workers to process them
+  err = comm.Qos(workers)
+  if err != nil {
+    log.Fatalf(err.Error())
+  }
+
   msgs, err := comm.Receive(receiveQueue)
 
   // Use a channel if goroutine closes
@@ -189,7 +276,10 @@ func main() {
   kubefactory.WaitForCacheSync(stopCh)
 
   // Create scheduler object
-  main_sched := NewShortJobScheduler(client, pod_lister, node_lister)
+  main_sched := NewShortJobScheduler(client, pod_lister, pod_informer.GetIndexer(), node_lister, strategy, strategies)
+
+  // Release the reserved resources once the pods are seen on their nodes
+  pod_informer.AddEventHandler(main_sched.PodHandlers())
 
   // Scheduler initialization failed
   if err != nil {
@@ -197,7 +287,7 @@ func main() {
   }
 
   // Start go routine to start consuming messages
-	go ScheduleProcess(&comm, main_sched, client, pod_lister, msgs, retryCh, receiveQueue, backoffQueue, hostname, maxBackOff)
+	go ScheduleProcess(&comm, main_sched, schedMetrics, client, pod_lister, msgs, retryCh, receiveQueue, backoffQueue, hostname, maxBackOff, workers)
 
 	log.Printf(" [*] Waiting for messages. To exit press CTRL+C")
 
@@ -213,6 +303,9 @@ func main() {
         }else{
 
           err = comm.QueueDeclare(receiveQueue)
+          if err == nil {
+            err = comm.Qos(workers)
+          }
           if err != nil {
             log.Errorf(err.Error())
           }else{
@@ -221,7 +314,7 @@ func main() {
               log.Errorf(err.Error())
             }else{
               // Start go routine to start consuming messages
-              go ScheduleProcess(&comm, main_sched, client, pod_lister, msgs, retryCh, receiveQueue, backoffQueue, hostname, maxBackOff)
+              go ScheduleProcess(&comm, main_sched, schedMetrics, client, pod_lister, msgs, retryCh, receiveQueue, backoffQueue, hostname, maxBackOff, workers)
               log.Infof("Reconnected to message server")
               break
             }
@@ -234,10 +327,12 @@ func main() {
   }
 }
 
-// Does scheduling operations and should be executed in a goroutine
+// Does scheduling operations and should be executed in a goroutine. The messages are processed by
+// the given number of concurrent workers.
 func ScheduleProcess(
   comm communication.Communication,
   s *ShortJobScheduler,
+  m *SchedulerMetrics,
   client kubernetes.Interface,
   podLister corelisters.PodLister,
   msgs <-chan amqp.Delivery,
@@ -245,92 +340,170 @@ func ScheduleProcess(
   receiveQueue string,
   backoffQueue string,
   hostname string,
+  maxBackOff int,
+  workers int,){
+
+  var wg sync.WaitGroup
+
+  for i := 0; i < workers; i++ {
+    wg.Add(1)
+    go func(){
+      defer wg.Done()
+      // Loop through all the messages in the queue
+      for d := range msgs {
+        processMessage(comm, s, m, client, d, receiveQueue, backoffQueue, hostname, maxBackOff)
+      }
+    }()
+  }
+
+  // Every worker stops once the channel is closed
+  wg.Wait()
+
+  closed <- true
+
+}
+
+// Schedule the pod of a single message and acknowledge the message
+func processMessage(
+  comm communication.Communication,
+  s *ShortJobScheduler,
+  m *SchedulerMetrics,
+  client kubernetes.Interface,
+  d amqp.Delivery,
+  receiveQueue string,
+  backoffQueue string,
+  hostname string,
   maxBackOff int,){
 
-  // Loop through all the messages in the queue
-  for d := range msgs {
+  // Record time of processing
+  timestamp := time.Now()
+  m.Received(receiveQueue)
 
-    // Record time of processing
-    timestamp := time.Now()
+  // Convert json message to schedule request object
+  var req communication.ScheduleRequest
 
-    // Convert json message to schedule request object
-    var req communication.ScheduleRequest
+  if err := json.Unmarshal(d.Body, &req); err != nil {
+      panic(err)
+  }
 
-    if err := json.Unmarshal(d.Body, &req); err != nil {
-        panic(err)
-    }
+  // Extract the pod name and namespace from the request
+  key := string(req.Key);
 
-    // Extract the pod name and namespace from the request
-    key := string(req.Key);
+  // Continue the trace of the pod started by the coordinator
+  ctx, span := communication.Tracer().Start(communication.ExtractContext(d, receiveQueue), "SchedulePod",
+    trace.WithAttributes(attribute.String("pod", key), attribute.String("replica", hostname)))
+  defer span.End()
 
-    // Convert the namespace/name string into a distinct namespace and name
-    namespace, name, err := cache.SplitMetaNamespaceKey(key)
-    if err != nil {
-      log.Errorf("%s", err)
-    }
+  // Convert the namespace/name string into a distinct namespace and name
+  namespace, name, err := cache.SplitMetaNamespaceKey(key)
+  if err != nil {
+    log.Errorf("%s", err)
+  }
+
+  obj, err := client.CoreV1().Pods(namespace).Get(context.TODO(), name, metav1.GetOptions{})
 
-    obj, err := client.CoreV1().Pods(namespace).Get(context.TODO(), name, metav1.GetOptions{})
 
+  // Check if pod still exist in the kube-api server if not ignore it
+  if err == nil && obj != nil{
 
-    // Check if pod still exist in the kube-api server if not ignore it
-    if err == nil && obj != nil{
+    log.Infof("Scheduling %s",obj.Name)
 
-      log.Infof("Scheduling %s",obj.Name)
+    // Start scheduling the pod
+    m.activePods.Inc()
+    defer m.activePods.Dec()
+    algorithmStart := time.Now()
+    _, filterSpan := communication.Tracer().Start(ctx, "Filter")
+    result, err := s.Schedule(obj)
+    if err != nil {
+      filterSpan.SetStatus(codes.Error, err.Error())
+    }
+    filterSpan.End()
+    m.ObserveAlgorithm(algorithmStart)
 
-      // Start scheduling the pod
-      result, err := s.Schedule(obj)
+    if err != nil {
 
-      if err != nil {
+      // Print the error in the event the scheduler is unable to schedule the pod
+      log.Errorf("%s", err)
 
-        // Print the error in the event the scheduler is unable to schedule the pod
-        log.Errorf("%s", err)
+      m.Unschedulable()
+      requeue(ctx, comm, m, client, obj, req, err.Error(), receiveQueue, backoffQueue, maxBackOff)
 
-        // Check scheduling request last back off time and check if it exceeds the maximum backoff time
-        if (req.NextBackOffTime >= maxBackOff){
+    }else if len(result) != 0{
 
-          go AddPodEvent(client,obj,fmt.Sprintf("Scheduler will not retry scheduling; Reason: %s",err.Error()),"Fatal")
+      log.Infof("Scheduling Pod %s to %s", name, result)
+      bindStart := time.Now()
+      err := bind(ctx,client,*obj,result,req.ProcessedTime,timestamp)
+      m.ObserveBinding(bindStart, timestamp, err)
+      if err != nil {
 
-          obj.Status.Phase = PodBackoffExceeded
+        s.Unreserve(obj)
 
-          go AddPodStatus(client,obj,metav1.UpdateOptions{})
+        failure := classifyBindError(client, obj, result, err)
 
+        if failure.Retry() {
+          log.Errorf("Unable to bind %s to %s (%s): %s", key, result, failure, err.Error())
+          requeue(ctx, comm, m, client, obj, req, fmt.Sprintf("Unable to bind to %s (%s): %s", result, failure, err.Error()), receiveQueue, backoffQueue, maxBackOff)
         }else{
+          log.Infof("Dropping %s as binding to %s failed (%s): %s", key, result, failure, err.Error())
+        }
+      }
+      //Use for experiment only
+      //go SendExperimentPayload(comm,obj,timestamp,time.Now(),"epsilon.experiment",result,hostname)
 
-          // If backoff time not exceeded, multiply the last backoff time by 2 and send it to backoff queue
-          req.NextBackOffTime = req.NextBackOffTime*2
-          req.Message = err.Error()
+    }else{
+      m.Unschedulable()
+      requeue(ctx, comm, m, client, obj, req, "No node selected", receiveQueue, backoffQueue, maxBackOff)
+    }
+  }
 
-          respBytes, err := json.Marshal(communication.RetryRequest{req,receiveQueue})
-          if err != nil {
-            log.Fatalf("%s", err)
-          }
+  // Only acknowledge this message as the other workers are still processing theirs
+  d.Ack(false)
+}
+
+// Send the schedule request to the retry service until the backoff time exceeds the maximum backoff
+// time, after which the scheduler gives up on the pod
+func requeue(
+  ctx context.Context,
+  comm communication.Communication,
+  m *SchedulerMetrics,
+  client kubernetes.Interface,
+  obj *corev1.Pod,
+  req communication.ScheduleRequest,
+  reason string,
+  receiveQueue string,
+  backoffQueue string,
+  maxBackOff int,){
 
-          go AddPodEvent(client,obj,fmt.Sprintf("Scheduler will retry in %d seconds; Reason: %s",req.NextBackOffTime,req.Message),"Warning")
+  // Check scheduling request last back off time and check if it exceeds the maximum backoff time
+  if (req.NextBackOffTime >= maxBackOff){
 
-          // Attempt to send message to retry service
-          go SendToQueue(comm,respBytes,backoffQueue)
-        }
+    go AddPodEvent(client,obj,fmt.Sprintf("Scheduler will not retry scheduling; Reason: %s",reason),"Fatal")
 
-        d.Ack(true)
+    obj.Status.Phase = PodBackoffExceeded
 
-      }else if len(result) != 0{
+    go AddPodStatus(client,obj,metav1.UpdateOptions{})
 
-        log.Infof("Scheduling Pod %s to %s", name, result)
-        bind(client,*obj,result,req.ProcessedTime,timestamp)
-        //Use for experiment only
-        //go SendExperimentPayload(comm,obj,timestamp,time.Now(),"epsilon.experiment",result,hostname)
-        d.Ack(true)
+    return
+  }
 
-      }else{
-        d.Nack(true, true)
-      }
-    }else{
-      d.Ack(true)
-    }
+  // If backoff time not exceeded, multiply the last backoff time by 2 and send it to backoff queue.
+  // A request without a backoff time would otherwise be retried forever.
+  if req.NextBackOffTime < 1 {
+    req.NextBackOffTime = 1
   }
+  req.NextBackOffTime = req.NextBackOffTime*2
+  req.Message = reason
 
-  closed <- true
+  respBytes, err := json.Marshal(communication.RetryRequest{Req: req, Queue: receiveQueue})
+  if err != nil {
+    log.Fatalf("%s", err)
+  }
+
+  go AddPodEvent(client,obj,fmt.Sprintf("Scheduler will retry in %d seconds; Reason: %s",req.NextBackOffTime,req.Message),"Warning")
 
+  // Attempt to send message to retry service
+  m.Retried(backoffQueue)
+  go SendToQueue(ctx,comm,respBytes,backoffQueue)
 }
 
 func SendExperimentPayload(comm communication.Communication, obj *corev1.Pod, in time.Time, out time.Time, queueName string, suggestedHost string, hostname string){
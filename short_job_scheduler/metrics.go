@@ -0,0 +1,153 @@
+/*
+
+Copyright (C) 2020 Alex Neo
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package main
+
+import(
+  "time"
+  "net/http"
+
+  log "github.com/sirupsen/logrus"
+  "github.com/prometheus/client_golang/prometheus"
+  "github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+  // Port of the metrics server if none is configured
+  DefaultMetricsPort = "8080"
+
+  // Subsystem of the metrics, the same as the general purpose scheduler
+  SchedulerSubsystem = "scheduler"
+)
+
+// SchedulerMetrics are the metrics recorded by the scheduler, every series is labelled with the
+// queue the pods are received from and the replica name
+type SchedulerMetrics struct{
+  // Number of attempts to schedule pods, by the result
+  scheduleAttempts *prometheus.CounterVec
+  // Time from receiving the message to binding the pod
+  e2eLatency prometheus.Histogram
+  // Time taken to select a node
+  algorithmLatency prometheus.Histogram
+  // Time taken to bind the pod
+  bindingLatency prometheus.Histogram
+  // Number of pods being scheduled by the workers
+  activePods prometheus.Gauge
+  // Number of pods received and sent to the retry service, by queue and event
+  incomingPods *prometheus.CounterVec
+}
+
+// Creates the metrics of the replica of a queue
+func NewSchedulerMetrics(queue, replica string) *SchedulerMetrics{
+
+  labels := prometheus.Labels{"queue_name": queue, "replica": replica}
+  buckets := prometheus.ExponentialBuckets(0.001, 2, 15)
+
+  return &SchedulerMetrics{
+    scheduleAttempts: prometheus.NewCounterVec(prometheus.CounterOpts{
+      Subsystem: SchedulerSubsystem,
+      Name: "schedule_attempts_total",
+      Help: "Number of attempts to schedule pods, by the result. 'unschedulable' means a pod could not be scheduled, while 'error' means an internal scheduler problem.",
+      ConstLabels: labels,
+    }, []string{"result"}),
+    e2eLatency: prometheus.NewHistogram(prometheus.HistogramOpts{
+      Subsystem: SchedulerSubsystem,
+      Name: "e2e_scheduling_duration_seconds",
+      Help: "E2e scheduling latency in seconds (scheduling algorithm + binding)",
+      Buckets: buckets,
+      ConstLabels: labels,
+    }),
+    algorithmLatency: prometheus.NewHistogram(prometheus.HistogramOpts{
+      Subsystem: SchedulerSubsystem,
+      Name: "scheduling_algorithm_duration_seconds",
+      Help: "Scheduling algorithm latency in seconds",
+      Buckets: buckets,
+      ConstLabels: labels,
+    }),
+    bindingLatency: prometheus.NewHistogram(prometheus.HistogramOpts{
+      Subsystem: SchedulerSubsystem,
+      Name: "binding_duration_seconds",
+      Help: "Binding latency in seconds",
+      Buckets: buckets,
+      ConstLabels: labels,
+    }),
+    activePods: prometheus.NewGauge(prometheus.GaugeOpts{
+      Subsystem: SchedulerSubsystem,
+      Name: "pending_pods",
+      Help: "Number of pods being scheduled by the workers of the replica.",
+      ConstLabels: prometheus.Labels{"queue_name": queue, "replica": replica, "queue": "active"},
+    }),
+    incomingPods: prometheus.NewCounterVec(prometheus.CounterOpts{
+      Subsystem: SchedulerSubsystem,
+      Name: "queue_incoming_pods_total",
+      Help: "Number of pods received from the queue and sent to the retry service, by queue and event.",
+      ConstLabels: labels,
+    }, []string{"queue", "event"}),
+  }
+}
+
+// Register the metrics
+func (m *SchedulerMetrics) Register(r prometheus.Registerer) error{
+  for _, c := range([]prometheus.Collector{m.scheduleAttempts, m.e2eLatency, m.algorithmLatency, m.bindingLatency, m.activePods, m.incomingPods}){
+    if err := r.Register(c); err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+// Record a pod received from the queue
+func (m *SchedulerMetrics) Received(queue string){
+  m.incomingPods.WithLabelValues(queue, "Received").Inc()
+}
+
+// Record a pod sent to the retry service
+func (m *SchedulerMetrics) Retried(queue string){
+  m.incomingPods.WithLabelValues(queue, "Retry").Inc()
+}
+
+// Record the time taken to select a node, starting at the given time
+func (m *SchedulerMetrics) ObserveAlgorithm(start time.Time){
+  m.algorithmLatency.Observe(time.Since(start).Seconds())
+}
+
+// Record the result of binding a pod, the binding started at bindStart and the scheduling at schedStart
+func (m *SchedulerMetrics) ObserveBinding(bindStart time.Time, schedStart time.Time, err error){
+  m.bindingLatency.Observe(time.Since(bindStart).Seconds())
+  if err != nil {
+    m.scheduleAttempts.WithLabelValues("error").Inc()
+    return
+  }
+  m.scheduleAttempts.WithLabelValues("scheduled").Inc()
+  m.e2eLatency.Observe(time.Since(schedStart).Seconds())
+}
+
+// Record a pod that could not be scheduled
+func (m *SchedulerMetrics) Unschedulable(){
+  m.scheduleAttempts.WithLabelValues("unschedulable").Inc()
+}
+
+/*
+Creates a prometheus based metrics server exporting the scheduler metrics
+*/
+func metricsServer(port string){
+  // The Handler function provides a default handler to expose metrics
+  // via an HTTP server. "/metrics" is the usual endpoint for that.
+  http.Handle("/metrics", promhttp.Handler())
+  log.Fatal(http.ListenAndServe(":"+port, nil))
+}
@@ -0,0 +1,75 @@
+/*
+
+Copyright (C) 2020 Alex Neo
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package main
+
+import (
+  "errors"
+  "strings"
+  "testing"
+  "time"
+
+  "github.com/prometheus/client_golang/prometheus"
+  "github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSchedulerMetrics(t *testing.T) {
+
+  m := NewSchedulerMetrics("epsilon.shortjob", "sjsched-0")
+  registry := prometheus.NewRegistry()
+  if err := m.Register(registry); err != nil {
+    t.Fatal(err)
+  }
+
+  m.Received("epsilon.shortjob")
+  m.ObserveBinding(time.Now(), time.Now(), nil)
+  m.ObserveBinding(time.Now(), time.Now(), errors.New("conflict"))
+  m.Unschedulable()
+  m.Retried("epsilon.backoff")
+
+  expected := `
+# HELP scheduler_schedule_attempts_total Number of attempts to schedule pods, by the result. 'unschedulable' means a pod could not be scheduled, while 'error' means an internal scheduler problem.
+# TYPE scheduler_schedule_attempts_total counter
+scheduler_schedule_attempts_total{queue_name="epsilon.shortjob",replica="sjsched-0",result="error"} 1
+scheduler_schedule_attempts_total{queue_name="epsilon.shortjob",replica="sjsched-0",result="scheduled"} 1
+scheduler_schedule_attempts_total{queue_name="epsilon.shortjob",replica="sjsched-0",result="unschedulable"} 1
+# HELP scheduler_queue_incoming_pods_total Number of pods received from the queue and sent to the retry service, by queue and event.
+# TYPE scheduler_queue_incoming_pods_total counter
+scheduler_queue_incoming_pods_total{event="Received",queue="epsilon.shortjob",queue_name="epsilon.shortjob",replica="sjsched-0"} 1
+scheduler_queue_incoming_pods_total{event="Retry",queue="epsilon.backoff",queue_name="epsilon.shortjob",replica="sjsched-0"} 1
+`
+  if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "scheduler_schedule_attempts_total", "scheduler_queue_incoming_pods_total"); err != nil {
+    t.Error(err)
+  }
+
+  // Only the successful binding is counted in the e2e latency
+  families, err := registry.Gather()
+  if err != nil {
+    t.Fatal(err)
+  }
+  for _, family := range(families) {
+    if family.GetName() == "scheduler_e2e_scheduling_duration_seconds" {
+      if n := family.Metric[0].GetHistogram().GetSampleCount(); n != 1 {
+        t.Errorf("expected one e2e latency sample, got %d", n)
+      }
+    }
+  }
+  if err := m.Register(registry); err == nil {
+    t.Errorf("expected registering the metrics twice to fail")
+  }
+}
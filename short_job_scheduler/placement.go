@@ -0,0 +1,262 @@
+/*
+
+Copyright (C) 2020 Alex Neo
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package main
+
+import(
+  "fmt"
+  "sort"
+  "strings"
+  "time"
+  "hash/fnv"
+  pcglib "github.com/MichaelTJones/pcg"
+  corev1 "k8s.io/api/core/v1"
+)
+
+// Names of the placement strategies
+const (
+  RoundRobinStrategy = "roundrobin"
+  PowerOfTwoStrategy = "poweroftwo"
+  LeastRecentlyUsedStrategy = "lru"
+  HashStrategy = "hash"
+
+  DefaultPlacementStrategy = RoundRobinStrategy
+
+  // Label of the pod containing the queue the pod was sent to
+  QueueLabel = "epsilon.queue"
+  // Label added to the pods created by a job
+  JobNameLabel = "job-name"
+)
+
+// NodeChecker checks the nodes for a pod during a single scheduling attempt
+type NodeChecker interface{
+  // Check if the pod can be deployed on the node
+  Fits(node *corev1.Node) bool
+  // Fraction of the allocatable cpu and memory of the node left after deploying the pod
+  Free(node *corev1.Node) float64
+}
+
+// PlacementStrategy selects the node a pod is deployed on. The scheduler never calls Select
+// concurrently so strategies can keep state without locking.
+type PlacementStrategy interface{
+  // Select a node that fits the pod from the nodes sorted by name, returns nil if no node fits
+  Select(pod *corev1.Pod, nodes []*corev1.Node, checker NodeChecker) *corev1.Node
+}
+
+// Create a placement strategy from its name
+func NewPlacementStrategy(name string) (PlacementStrategy, error){
+  switch strings.ToLower(strings.TrimSpace(name)) {
+  case RoundRobinStrategy:
+    return NewRoundRobin(), nil
+  case PowerOfTwoStrategy:
+    return NewPowerOfTwo(), nil
+  case LeastRecentlyUsedStrategy:
+    return NewLeastRecentlyUsed(), nil
+  case HashStrategy:
+    return NewHash(), nil
+  default:
+    return nil, fmt.Errorf("Unknown placement strategy %q", name)
+  }
+}
+
+// Parse the placement strategy of each queue from a comma separated list of queue=strategy pairs
+// (eg. epsilon.shortjob=lru,epsilon.batch=hash)
+func getQueueStrategies(value string) (map[string]PlacementStrategy, error){
+
+  strategies := make(map[string]PlacementStrategy)
+
+  for _, pair := range(strings.Split(value, ",")){
+
+    pair = strings.TrimSpace(pair)
+    if len(pair) == 0 {
+      continue
+    }
+
+    kv := strings.SplitN(pair, "=", 2)
+    if len(kv) != 2 || len(strings.TrimSpace(kv[0])) == 0 {
+      return nil, fmt.Errorf("Invalid placement strategy %q, expected queue=strategy", pair)
+    }
+
+    strategy, err := NewPlacementStrategy(kv[1])
+    if err != nil {
+      return nil, err
+    }
+    strategies[strings.TrimSpace(kv[0])] = strategy
+  }
+
+  return strategies, nil
+}
+
+// RoundRobin tries the nodes one after another starting after the node selected last
+type RoundRobin struct{
+  previous int
+  started bool
+}
+
+func NewRoundRobin() *RoundRobin{
+  return &RoundRobin{}
+}
+
+func (r *RoundRobin) Select(pod *corev1.Pod, nodes []*corev1.Node, checker NodeChecker) *corev1.Node{
+
+  if len(nodes) == 0 {
+    return nil
+  }
+
+  // Start at a random node so replicas of the scheduler do not all start at the same node
+  if !r.started {
+    r.previous = int(newPCG().Bounded(uint64(len(nodes))))
+    r.started = true
+  }
+
+  for i := 1; i <= len(nodes); i++ {
+    index := (r.previous+i) % len(nodes)
+    if checker.Fits(nodes[index]) {
+      r.previous = index
+      return nodes[index]
+    }
+  }
+
+  return nil
+}
+
+// PowerOfTwo picks two random nodes that fit the pod and selects the one with the most free resources
+type PowerOfTwo struct{
+  pcg *pcglib.PCG64
+}
+
+func NewPowerOfTwo() *PowerOfTwo{
+  return &PowerOfTwo{pcg: newPCG()}
+}
+
+func (p *PowerOfTwo) Select(pod *corev1.Pod, nodes []*corev1.Node, checker NodeChecker) *corev1.Node{
+
+  // Visit the nodes in a random order until two fit
+  order := make([]int, len(nodes))
+  for i := range(order){
+    order[i] = i
+  }
+
+  var choices []*corev1.Node
+
+  for i := 0; i < len(order) && len(choices) < 2; i++ {
+    j := i + int(p.pcg.Bounded(uint64(len(order)-i)))
+    order[i], order[j] = order[j], order[i]
+    if checker.Fits(nodes[order[i]]) {
+      choices = append(choices, nodes[order[i]])
+    }
+  }
+
+  switch len(choices) {
+  case 0:
+    return nil
+  case 1:
+    return choices[0]
+  }
+
+  if checker.Free(choices[1]) > checker.Free(choices[0]) {
+    return choices[1]
+  }
+  return choices[0]
+}
+
+// LeastRecentlyUsed selects the node that fits the pod and was selected the longest time ago
+type LeastRecentlyUsed struct{
+  // Sequence number of the last selection of each node
+  lastUsed map[string]uint64
+  sequence uint64
+}
+
+func NewLeastRecentlyUsed() *LeastRecentlyUsed{
+  return &LeastRecentlyUsed{lastUsed: make(map[string]uint64)}
+}
+
+func (l *LeastRecentlyUsed) Select(pod *corev1.Pod, nodes []*corev1.Node, checker NodeChecker) *corev1.Node{
+
+  // Nodes never selected come first, ties keep the order of the names
+  order := make([]*corev1.Node, len(nodes))
+  copy(order, nodes)
+  sort.SliceStable(order, func(i, j int) bool {
+    return l.lastUsed[order[i].Name] < l.lastUsed[order[j].Name]
+  })
+
+  for _, node := range(order){
+    if checker.Fits(node) {
+      l.sequence++
+      l.lastUsed[node.Name] = l.sequence
+      return node
+    }
+  }
+
+  return nil
+}
+
+// Hash sends the pods of the same job to the same node while it fits, otherwise the next node
+// that fits is selected
+type Hash struct{}
+
+func NewHash() *Hash{
+  return &Hash{}
+}
+
+func (h *Hash) Select(pod *corev1.Pod, nodes []*corev1.Node, checker NodeChecker) *corev1.Node{
+
+  if len(nodes) == 0 {
+    return nil
+  }
+
+  f := fnv.New32a()
+  f.Write([]byte(pod.Namespace + "/" + jobName(pod)))
+  start := int(f.Sum32() % uint32(len(nodes)))
+
+  for i := 0; i < len(nodes); i++ {
+    index := (start+i) % len(nodes)
+    if checker.Fits(nodes[index]) {
+      return nodes[index]
+    }
+  }
+
+  return nil
+}
+
+// Get the name of the job that created the pod. Pods not created by a job are grouped by the name
+// of their controller or generate name.
+func jobName(pod *corev1.Pod) string{
+
+  if name := pod.Labels[JobNameLabel]; len(name) != 0 {
+    return name
+  }
+
+  for _, ref := range(pod.OwnerReferences){
+    if ref.Controller != nil && *ref.Controller {
+      return ref.Name
+    }
+  }
+
+  if len(pod.GenerateName) != 0 {
+    return pod.GenerateName
+  }
+
+  return pod.Name
+}
+
+// Create a random number generator seeded with the current time
+func newPCG() *pcglib.PCG64{
+  seed := uint64(time.Now().UnixNano())
+  return pcglib.NewPCG64().Seed(seed, seed>>1, 1, 2)
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+  "testing"
+  "k8s.io/client-go/tools/cache"
+  corev1 "k8s.io/api/core/v1"
+  corelisters "k8s.io/client-go/listers/core/v1"
+)
+
+type fakeChecker struct{
+  full map[string]bool
+  free map[string]float64
+}
+
+func (c fakeChecker) Fits(node *corev1.Node) bool {
+  return !c.full[node.Name]
+}
+
+func (c fakeChecker) Free(node *corev1.Node) float64 {
+  return c.free[node.Name]
+}
+
+func testNodes(names ...string) []*corev1.Node {
+  nodes := make([]*corev1.Node, len(names))
+  for i, name := range names {
+    nodes[i] = testNode(name, nil)
+  }
+  return nodes
+}
+
+func selectNames(strategy PlacementStrategy, pod *corev1.Pod, nodes []*corev1.Node, checker NodeChecker, n int) []string {
+  var names []string
+  for i := 0; i < n; i++ {
+    if node := strategy.Select(pod, nodes, checker); node != nil {
+      names = append(names, node.Name)
+    }else{
+      names = append(names, "")
+    }
+  }
+  return names
+}
+
+func TestNewPlacementStrategy(t *testing.T) {
+
+  for _, name := range []string{"roundrobin", "PowerOfTwo", " lru ", "hash"} {
+    if _, err := NewPlacementStrategy(name); err != nil {
+      t.Errorf("NewPlacementStrategy(%q) = %v", name, err)
+    }
+  }
+
+  if _, err := NewPlacementStrategy("random"); err == nil {
+    t.Errorf("NewPlacementStrategy(random) = nil error")
+  }
+
+  strategies, err := getQueueStrategies("epsilon.shortjob=lru, epsilon.batch=hash,")
+  if err != nil || len(strategies) != 2 {
+    t.Fatalf("getQueueStrategies() = %v, %v", strategies, err)
+  }
+  if _, ok := strategies["epsilon.batch"].(*Hash); !ok {
+    t.Errorf("epsilon.batch strategy = %T, want *Hash", strategies["epsilon.batch"])
+  }
+
+  for _, value := range []string{"epsilon.shortjob", "=lru", "epsilon.shortjob=random"} {
+    if _, err := getQueueStrategies(value); err == nil {
+      t.Errorf("getQueueStrategies(%q) = nil error", value)
+    }
+  }
+}
+
+func TestRoundRobin(t *testing.T) {
+
+  nodes := testNodes("a", "b", "c", "d")
+  r := &RoundRobin{previous: 3, started: true}
+
+  got := selectNames(r, testPod("p", ""), nodes, fakeChecker{full: map[string]bool{"c": true}}, 4)
+  if want := []string{"a", "b", "d", "a"}; !equalNames(got, want) {
+    t.Errorf("RoundRobin = %v, want %v", got, want)
+  }
+
+  if node := r.Select(testPod("p", ""), nodes, fakeChecker{full: map[string]bool{"a": true, "b": true, "c": true, "d": true}}); node != nil {
+    t.Errorf("RoundRobin with full nodes = %s, want nil", node.Name)
+  }
+}
+
+func TestPowerOfTwo(t *testing.T) {
+
+  nodes := testNodes("a", "b", "c")
+  checker := fakeChecker{
+    full: map[string]bool{"c": true},
+    free: map[string]float64{"a": 0.2, "b": 0.7},
+  }
+
+  p := NewPowerOfTwo()
+  for _, name := range selectNames(p, testPod("p", ""), nodes, checker, 20) {
+    if name != "b" {
+      t.Fatalf("PowerOfTwo = %s, want b", name)
+    }
+  }
+
+  checker.full["b"] = true
+  if node := p.Select(testPod("p", ""), nodes, checker); node == nil || node.Name != "a" {
+    t.Errorf("PowerOfTwo with one node left = %v, want a", node)
+  }
+}
+
+func TestLeastRecentlyUsed(t *testing.T) {
+
+  nodes := testNodes("a", "b", "c")
+  l := NewLeastRecentlyUsed()
+
+  got := selectNames(l, testPod("p", ""), nodes, fakeChecker{}, 4)
+  if want := []string{"a", "b", "c", "a"}; !equalNames(got, want) {
+    t.Errorf("LeastRecentlyUsed = %v, want %v", got, want)
+  }
+
+  // b is the least recently used node but it is full
+  got = selectNames(l, testPod("p", ""), nodes, fakeChecker{full: map[string]bool{"b": true}}, 2)
+  if want := []string{"c", "a"}; !equalNames(got, want) {
+    t.Errorf("LeastRecentlyUsed = %v, want %v", got, want)
+  }
+}
+
+func TestHash(t *testing.T) {
+
+  nodes := testNodes("a", "b", "c", "d", "e")
+  h := NewHash()
+
+  first := testPod("job-1-abcde", "")
+  first.Labels = map[string]string{JobNameLabel: "job-1"}
+  second := testPod("job-1-fghij", "")
+  second.Labels = map[string]string{JobNameLabel: "job-1"}
+
+  node := h.Select(first, nodes, fakeChecker{})
+  if node == nil {
+    t.Fatalf("Hash = nil")
+  }
+  if other := h.Select(second, nodes, fakeChecker{}); other == nil || other.Name != node.Name {
+    t.Errorf("pods of the same job were placed on %s and %v", node.Name, other)
+  }
+
+  // The next node is used when the node of the job is full
+  other := h.Select(second, nodes, fakeChecker{full: map[string]bool{node.Name: true}})
+  if other == nil || other.Name == node.Name {
+    t.Errorf("Hash with full node = %v, want another node", other)
+  }
+}
+
+func TestScheduleQueueStrategy(t *testing.T) {
+
+  nodeIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+  podIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{NodeNameIndex: nodeNameIndexFunc})
+
+  allocatable := resourceList("4", "8Gi", "", map[corev1.ResourceName]string{corev1.ResourcePods: "110"})
+  for _, name := range []string{"node-1", "node-2", "node-3"} {
+    nodeIndexer.Add(testNode(name, allocatable))
+  }
+  podIndexer.Add(testPod("a", "node-1", resourceList("3", "6Gi", "", nil)))
+  podIndexer.Add(testPod("b", "node-3", resourceList("1", "1Gi", "", nil)))
+
+  s := NewShortJobScheduler(nil, nil, podIndexer, corelisters.NewNodeLister(nodeIndexer), &RoundRobin{previous: 2, started: true}, map[string]PlacementStrategy{
+    "epsilon.balanced": NewPowerOfTwo(),
+  })
+
+  // node-2 has the most free resources
+  pod := testPod("p", "", resourceList("500m", "1Gi", "", nil))
+  pod.Labels = map[string]string{QueueLabel: "epsilon.balanced"}
+  for i := 0; i < 10; i++ {
+    if name, err := s.Schedule(pod); err != nil || name == "node-1" {
+      t.Fatalf("Schedule() = %q, %v", name, err)
+    }
+  }
+
+  // Pods of other queues use the default round robin strategy
+  if name, err := s.Schedule(testPod("p", "", resourceList("500m", "1Gi", "", nil))); err != nil || name != "node-1" {
+    t.Errorf("Schedule() = %q, %v, want node-1", name, err)
+  }
+}
+
+func equalNames(a, b []string) bool {
+  if len(a) != len(b) {
+    return false
+  }
+  for i := range a {
+    if a[i] != b[i] {
+      return false
+    }
+  }
+  return true
+}
@@ -0,0 +1,127 @@
+/*
+
+Copyright (C) 2020 Alex Neo
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package main
+
+import(
+  "sync"
+  "k8s.io/client-go/tools/cache"
+  corev1 "k8s.io/api/core/v1"
+)
+
+type reservation struct{
+  node string
+  request *Resource
+}
+
+// Reservations holds the resources of the pods selected for a node until the pod informer sees the
+// pod on the node, so concurrent workers do not place pods on resources that are already taken
+type Reservations struct{
+  mu sync.Mutex
+  // Reservation of each pod key (namespace/name)
+  pods map[string]reservation
+}
+
+// Creates a new Reservations
+func NewReservations() *Reservations{
+  return &Reservations{
+    pods: make(map[string]reservation),
+  }
+}
+
+// Reserve the resources requested by the pod on the node
+func (r *Reservations) Reserve(pod *corev1.Pod, node string){
+
+  request := computePodResourceRequest(pod)
+  request.Pods = 1
+
+  r.mu.Lock()
+  defer r.mu.Unlock()
+
+  r.pods[podKey(pod)] = reservation{node: node, request: request}
+}
+
+// Release the resources reserved for the pod
+func (r *Reservations) Forget(pod *corev1.Pod){
+
+  r.mu.Lock()
+  defer r.mu.Unlock()
+
+  delete(r.pods, podKey(pod))
+}
+
+// Get the resources reserved on a node, pods already assigned to the node are skipped
+func (r *Reservations) OnNode(node string, assigned []*corev1.Pod) *Resource{
+
+  skip := make(map[string]bool, len(assigned))
+  for _, pod := range(assigned){
+    skip[podKey(pod)] = true
+  }
+
+  result := &Resource{}
+
+  r.mu.Lock()
+  defer r.mu.Unlock()
+
+  for key, res := range(r.pods){
+    if res.node == node && !skip[key] {
+      result.AddResource(res.request)
+    }
+  }
+
+  return result
+}
+
+// Number of pods with reserved resources
+func (r *Reservations) Len() int{
+
+  r.mu.Lock()
+  defer r.mu.Unlock()
+
+  return len(r.pods)
+}
+
+// Event handlers to be added to the pod informer, reservations are released once the informer sees
+// the pod assigned to a node or the pod is deleted
+func (r *Reservations) Handlers() cache.ResourceEventHandlerFuncs{
+  return cache.ResourceEventHandlerFuncs{
+    AddFunc: func(obj interface{}){
+      if pod, ok := obj.(*corev1.Pod); ok && len(pod.Spec.NodeName) != 0 {
+        r.Forget(pod)
+      }
+    },
+    UpdateFunc: func(oldObj, newObj interface{}){
+      if pod, ok := newObj.(*corev1.Pod); ok && len(pod.Spec.NodeName) != 0 {
+        r.Forget(pod)
+      }
+    },
+    DeleteFunc: func(obj interface{}){
+      if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+        obj = tombstone.Obj
+      }
+      if pod, ok := obj.(*corev1.Pod); ok {
+        r.Forget(pod)
+      }
+    },
+  }
+}
+
+// Get the namespace/name key of a pod
+func podKey(pod *corev1.Pod) string{
+  return pod.Namespace + "/" + pod.Name
+}
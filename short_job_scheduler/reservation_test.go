@@ -0,0 +1,96 @@
+package main
+
+import (
+  "sync"
+  "testing"
+  "k8s.io/client-go/tools/cache"
+  corev1 "k8s.io/api/core/v1"
+  corelisters "k8s.io/client-go/listers/core/v1"
+)
+
+func TestReservations(t *testing.T) {
+
+  r := NewReservations()
+
+  a := testPod("a", "", resourceList("1", "1Gi", "", nil))
+  b := testPod("b", "", resourceList("500m", "", "", nil))
+
+  r.Reserve(a, "node-1")
+  r.Reserve(b, "node-1")
+
+  if res := r.OnNode("node-1", nil); res.MilliCPU != 1500 || res.Memory != 1024*1024*1024 || res.Pods != 2 {
+    t.Errorf("OnNode() = %+v", res)
+  }
+
+  // Pods already seen on the node by the informer are not counted twice
+  if res := r.OnNode("node-1", []*corev1.Pod{testPod("a", "node-1")}); res.MilliCPU != 500 || res.Pods != 1 {
+    t.Errorf("OnNode() with assigned pod = %+v", res)
+  }
+
+  if res := r.OnNode("node-2", nil); res.Pods != 0 {
+    t.Errorf("OnNode(node-2) = %+v", res)
+  }
+
+  handlers := r.Handlers()
+
+  // Pods not assigned to a node keep their reservation
+  handlers.OnUpdate(a, a)
+  if r.Len() != 2 {
+    t.Fatalf("Len() = %d, want 2", r.Len())
+  }
+
+  handlers.OnUpdate(a, testPod("a", "node-1"))
+  handlers.OnDelete(cache.DeletedFinalStateUnknown{Key: "default/b", Obj: b})
+
+  if r.Len() != 0 {
+    t.Errorf("Len() = %d, want 0", r.Len())
+  }
+}
+
+func TestScheduleConcurrent(t *testing.T) {
+
+  nodeIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+  podIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{NodeNameIndex: nodeNameIndexFunc})
+
+  allocatable := resourceList("5", "8Gi", "", map[corev1.ResourceName]string{corev1.ResourcePods: "110"})
+  for _, name := range []string{"node-1", "node-2"} {
+    nodeIndexer.Add(testNode(name, allocatable))
+  }
+
+  s := NewShortJobScheduler(nil, nil, podIndexer, corelisters.NewNodeLister(nodeIndexer), NewPowerOfTwo(), nil)
+
+  var mu sync.Mutex
+  placed := make(map[string]int)
+
+  var wg sync.WaitGroup
+  for i := 0; i < 10; i++ {
+    wg.Add(1)
+    go func(i int){
+      defer wg.Done()
+      name, err := s.Schedule(testPod(string(rune('a'+i)), "", resourceList("1", "", "", nil)))
+      if err != nil {
+        t.Errorf("Schedule() = %v", err)
+        return
+      }
+      mu.Lock()
+      placed[name]++
+      mu.Unlock()
+    }(i)
+  }
+  wg.Wait()
+
+  if placed["node-1"] != 5 || placed["node-2"] != 5 {
+    t.Errorf("placed = %v, want 5 pods on each node", placed)
+  }
+
+  // Every cpu is reserved until the informer sees the pods
+  pod := testPod("k", "", resourceList("1", "", "", nil))
+  if _, err := s.Schedule(pod); err == nil {
+    t.Fatalf("Schedule() on full nodes = nil error")
+  }
+
+  s.Unreserve(testPod("a", ""))
+  if _, err := s.Schedule(pod); err != nil {
+    t.Errorf("Schedule() after Unreserve = %v", err)
+  }
+}
@@ -21,11 +21,13 @@ package main
 import(
   "fmt"
   "sort"
+  "strings"
   // "context"
+  "sync"
   "errors"
   "k8s.io/client-go/kubernetes"
   "k8s.io/apimachinery/pkg/labels"
-  pcglib "github.com/MichaelTJones/pcg"
+  "k8s.io/client-go/tools/cache"
   // metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
   corev1 "k8s.io/api/core/v1"
   corelisters "k8s.io/client-go/listers/core/v1"
@@ -41,39 +43,53 @@ const (
 
 // ShortJobScheduler structure
 type ShortJobScheduler struct{
-    previousNodeIndex   int
+    // Serializes the node selection of the workers, binding happens outside the lock
+    mu                  sync.Mutex
     clientset           kubernetes.Interface
     podlister           corelisters.PodLister
+    // Pod informer indexer with the pods indexed by node name (NodeNameIndex)
+    podIndexer          cache.Indexer
     nodelister          corelisters.NodeLister
+    // Filters checked on every node before its resources
+    filters             []FilterFunc
+    // Placement strategy of pods from queues without their own strategy
+    strategy            PlacementStrategy
+    // Placement strategy of each queue (QueueLabel of the pod)
+    queueStrategies     map[string]PlacementStrategy
+    // Resources of the pods selected for a node that the pod informer has not seen on the node yet
+    reservations        *Reservations
 }
 
 // Create new instance of a ShortJobScheduler
-func NewShortJobScheduler (clientset kubernetes.Interface, podlister corelisters.PodLister, nodelister corelisters.NodeLister) *ShortJobScheduler{
-
-  // Get the pod resource with this namespace/name
-  list, err := nodelister.List(labels.NewSelector())
-  if err != nil {
+func NewShortJobScheduler (clientset kubernetes.Interface, podlister corelisters.PodLister, podIndexer cache.Indexer, nodelister corelisters.NodeLister, strategy PlacementStrategy, queueStrategies map[string]PlacementStrategy) *ShortJobScheduler{
 
+  if queueStrategies == nil {
+    queueStrategies = make(map[string]PlacementStrategy)
   }
 
-  pcg := pcglib.NewPCG64()
-  index := pcg.Bounded(uint64(len(list)))
-
   return &ShortJobScheduler{
-    previousNodeIndex:  int(index),
     clientset:          clientset,
     podlister:          podlister,
+    podIndexer:         podIndexer,
     nodelister:         nodelister,
+    filters:            DefaultFilters,
+    strategy:           strategy,
+    queueStrategies:    queueStrategies,
+    reservations:       NewReservations(),
   }
 }
 
-// ObjectDeleted is called when an object is deleted
+// Select a node for the pod using the placement strategy of the pod's queue. The resources of the pod
+// are reserved on the node until the pod informer sees the pod on the node or Unreserve is called.
+// Safe to be called by concurrent workers.
 func (s *ShortJobScheduler) Schedule(pod *corev1.Pod) (name string, err error){
 
-  list, err := s.nodelister.List(labels.NewSelector())
+  s.mu.Lock()
+  defer s.mu.Unlock()
 
+  list, err := s.nodelister.List(labels.NewSelector())
   if err != nil {
-
+    return "", err
   }
 
   // Sort slice to ensure correct order
@@ -81,70 +97,134 @@ func (s *ShortJobScheduler) Schedule(pod *corev1.Pod) (name string, err error){
       return list[i].Name < list[j].Name
   })
 
-  for _ = range(list){
+  checker := &nodeChecker{scheduler: s, pod: pod, errs: make(map[string]error), requested: make(map[string]*Resource)}
+
+  if node := s.strategyOf(pod).Select(pod, list, checker); node != nil {
+    s.reservations.Reserve(pod, node.Name)
+    return node.Name, nil
+  }
+
+  // Number of nodes that failed for each reason
+  reasons := make(map[string]int)
 
-      fmt.Println(list[s.previousNodeIndex].Name)
+  for _, node := range(list){
 
-      index := (s.previousNodeIndex+1) % len(list)
+      err := checker.check(node)
+      if err == nil {
+        continue
+      }
 
-      if s.checkNode(pod, list[index]){
-        s.previousNodeIndex = index
-        return list[index].Name, nil
+      if fitErr, ok := err.(*FitError); ok {
+        for _, reason := range(fitErr.Reasons){
+          reasons[reason]++
+        }
+      }else{
+        reasons[err.Error()]++
       }
+  }
 
-      s.previousNodeIndex = index
+  return "", newNoNodeError(len(list), reasons)
+}
+
+// Release the resources reserved for a pod that could not be bound to its node
+func (s *ShortJobScheduler) Unreserve(pod *corev1.Pod){
+  s.reservations.Forget(pod)
+}
 
+// Event handlers to be added to the pod informer to release the reservations
+func (s *ShortJobScheduler) PodHandlers() cache.ResourceEventHandlerFuncs{
+  return s.reservations.Handlers()
+}
+
+// Get the placement strategy of the queue the pod was sent to
+func (s *ShortJobScheduler) strategyOf(pod *corev1.Pod) PlacementStrategy{
+  if strategy, ok := s.queueStrategies[pod.Labels[QueueLabel]]; ok {
+    return strategy
   }
-  return "", errors.New("Unable to find a suitable node to schedule")
+  return s.strategy
 }
 
-// Checks if the node is suitable for deploying the pod.
-func (s *ShortJobScheduler) checkNode(pod *corev1.Pod, node *corev1.Node) bool{
+// nodeChecker checks the nodes for a pod and remembers the result of each node
+type nodeChecker struct{
+  scheduler *ShortJobScheduler
+  pod *corev1.Pod
+  errs map[string]error
+  // Resources requested by the pods on each node that was checked
+  requested map[string]*Resource
+}
 
+func (c *nodeChecker) check(node *corev1.Node) error{
 
-  if checkTaints(pod,node) {
+  if err, ok := c.errs[node.Name]; ok {
+    return err
+  }
 
-    var totalMem =  int64(0)
-    capacity, _ :=   node.Status.Capacity.Memory().AsInt64()
+  err := c.scheduler.checkNode(c.pod, node, c.requested)
+  c.errs[node.Name] = err
 
-    for _, p := range(pod.Spec.Containers){
-      mem, _ :=   p.Resources.Requests.Memory().AsInt64()
-      totalMem += mem
-    }
+  return err
+}
 
-    if totalMem > capacity {
-      return false
-    }
+func (c *nodeChecker) Fits(node *corev1.Node) bool{
+  return c.check(node) == nil
+}
 
-    return true
+func (c *nodeChecker) Free(node *corev1.Node) float64{
 
+  requested, ok := c.requested[node.Name]
+  if !ok {
+    return 0
   }
 
-  return false
+  allocatable := &Resource{}
+  allocatable.Add(node.Status.Allocatable)
 
+  request := computePodResourceRequest(c.pod)
 
+  return (freeFraction(allocatable.MilliCPU, requested.MilliCPU+request.MilliCPU) +
+    freeFraction(allocatable.Memory, requested.Memory+request.Memory)) / 2
+}
 
+// Fraction of the allocatable amount that is not requested
+func freeFraction(allocatable, requested int64) float64{
+  if allocatable <= 0 {
+    return 0
+  }
+  return float64(allocatable-requested) / float64(allocatable)
 }
 
-// Check taints of a node and ensure pod tolerates the taint.
-func checkTaints(pod *corev1.Pod, node *corev1.Node) bool{
+// Checks if the node is suitable for deploying the pod, returns the reason if it is not.
+// The resources requested on the node are saved in requested.
+func (s *ShortJobScheduler) checkNode(pod *corev1.Pod, node *corev1.Node, requested map[string]*Resource) error{
 
-  var passTaints = 0
+  if err := runFilters(s.filters, pod, node); err != nil {
+    return err
+  }
 
-  for _, taint := range(node.Spec.Taints){
-    if taint.Effect == corev1.TaintEffectNoSchedule{
-        for _, tol := range(pod.Spec.Tolerations){
-          if tol.Key == taint.Key && tol.Value == taint.Value && (tol.Effect == taint.Effect || len(tol.Effect) == 0){
-            passTaints++
-          }
-        }
-    }
+  pods, err := podsOnNode(s.podIndexer, node.Name)
+  if err != nil {
+    return err
   }
 
-  if passTaints != len(node.Spec.Taints) {
-    return false
+  requested[node.Name] = computeNodeRequested(pods)
+  requested[node.Name].AddResource(s.reservations.OnNode(node.Name, pods))
+
+  return fitsResources(pod, node, requested[node.Name])
+}
+
+// Create an error describing why no node is able to run a pod
+// (eg. 0/3 nodes are available: 2 Insufficient cpu, 1 Too many pods.)
+func newNoNodeError(nodes int, reasons map[string]int) error{
+
+  if nodes == 0 {
+    return errors.New("Unable to find a suitable node to schedule: no nodes available")
   }
 
-  return true
+  s := make([]string, 0, len(reasons))
+  for reason, count := range(reasons){
+    s = append(s, fmt.Sprintf("%d %s", count, reason))
+  }
+  sort.Strings(s)
 
+  return fmt.Errorf("Unable to find a suitable node to schedule: 0/%d nodes are available: %s.", nodes, strings.Join(s, ", "))
 }